@@ -37,11 +37,12 @@ function toggleSQLQuery() {
 
 function addLabel() {
     let label = document.getElementById('add-label-name').value;
+    let prefix = document.getElementById('add-label-prefix').value;
     if (!label) {
         return
     }
     let url = new URL(window.location.href);
-    url.searchParams.append("col", "label_" + label)
+    url.searchParams.append("col", prefix + label)
     refreshContent(url.toString())
 }
 
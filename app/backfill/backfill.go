@@ -0,0 +1,239 @@
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/r2k1/pgkube/app/queries"
+)
+
+// the same container filter as the kubelet scraper, pod-level cgroup and pause container series are excluded
+const (
+	cpuQuery    = `sum by (namespace, pod) (rate(container_cpu_usage_seconds_total{container!="", container!="POD"}[5m]))`
+	memoryQuery = `sum by (namespace, pod) (container_memory_working_set_bytes{container!="", container!="POD"})`
+)
+
+type Options struct {
+	// Step is the resolution of range queries, it should match the scraping interval so averages are comparable
+	Step time.Duration
+	// Chunk is the time range requested from prometheus in a single query
+	Chunk time.Duration
+}
+
+// Backfiller imports historical pod usage from prometheus into pod_usage_hourly
+type Backfiller struct {
+	prometheus *PrometheusClient
+	queries    *queries.Queries
+	opts       Options
+}
+
+func NewBackfiller(prometheus *PrometheusClient, queries *queries.Queries, opts Options) *Backfiller {
+	if opts.Step <= 0 {
+		opts.Step = time.Minute
+	}
+	if opts.Chunk < time.Hour {
+		opts.Chunk = 24 * time.Hour
+	}
+	opts.Chunk = opts.Chunk.Truncate(time.Hour)
+	return &Backfiller{
+		prometheus: prometheus,
+		queries:    queries,
+		opts:       opts,
+	}
+}
+
+// DefaultEnd returns the first hour collected by the scraper, so backfilled data doesn't overlap with it
+func (b *Backfiller) DefaultEnd(ctx context.Context) (time.Time, error) {
+	earliest, err := b.queries.EarliestPodUsageHour(ctx)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("getting earliest pod usage: %w", err)
+	}
+	if !earliest.Valid {
+		return truncateToHour(time.Now()), nil
+	}
+	return earliest.Time, nil
+}
+
+// Run imports usage in [start, end), both are truncated to the hour
+// hours which already have usage of the pod are skipped, running it again for the same range changes nothing
+func (b *Backfiller) Run(ctx context.Context, start, end time.Time) error {
+	start, end = truncateToHour(start), truncateToHour(end)
+	if !start.Before(end) {
+		return fmt.Errorf("backfill start %s must be before end %s", start, end)
+	}
+	pods, err := b.queries.ListPodLifetimes(ctx)
+	if err != nil {
+		return fmt.Errorf("listing pods: %w", err)
+	}
+	resolver := newPodResolver(pods)
+	slog.Info("starting backfill", "start", start, "end", end, "pods", len(pods))
+	for chunkStart := start; chunkStart.Before(end); chunkStart = chunkStart.Add(b.opts.Chunk) {
+		chunkEnd := chunkStart.Add(b.opts.Chunk)
+		if chunkEnd.After(end) {
+			chunkEnd = end
+		}
+		if err := b.runChunk(ctx, resolver, chunkStart, chunkEnd); err != nil {
+			return err
+		}
+	}
+	slog.Info("backfill finished")
+	return nil
+}
+
+func (b *Backfiller) runChunk(ctx context.Context, resolver *podResolver, start, end time.Time) error {
+	cpuSeries, err := b.prometheus.QueryRange(ctx, cpuQuery, start, end, b.opts.Step)
+	if err != nil {
+		return fmt.Errorf("querying cpu usage: %w", err)
+	}
+	memorySeries, err := b.prometheus.QueryRange(ctx, memoryQuery, start, end, b.opts.Step)
+	if err != nil {
+		return fmt.Errorf("querying memory usage: %w", err)
+	}
+	agg := newAggregator(resolver, start, end)
+	agg.addCPU(cpuSeries)
+	agg.addMemory(memorySeries)
+	data := agg.result()
+	if err := b.queries.InsertPodUsageHourly(ctx, data); err != nil {
+		return fmt.Errorf("inserting pod usage: %w", err)
+	}
+	slog.Info("backfilled", "start", start, "end", end, "rows", len(data), "unknownSamples", agg.unknown)
+	return nil
+}
+
+type podName struct {
+	Namespace string
+	Name      string
+}
+
+// podResolver maps namespace/name of a series to a pod UID, pods with the same name are told apart by their lifetime
+type podResolver struct {
+	pods map[podName][]queries.PodLifetime
+}
+
+func newPodResolver(pods []queries.PodLifetime) *podResolver {
+	result := &podResolver{pods: make(map[podName][]queries.PodLifetime, len(pods))}
+	for _, pod := range pods {
+		key := podName{Namespace: pod.Namespace, Name: pod.Name}
+		result.pods[key] = append(result.pods[key], pod)
+	}
+	return result
+}
+
+func (r *podResolver) Resolve(namespace, name string, t time.Time) (pgtype.UUID, bool) {
+	for _, pod := range r.pods[podName{Namespace: namespace, Name: name}] {
+		if pod.CreatedAt.Valid && t.Before(pod.CreatedAt.Time) {
+			continue
+		}
+		if pod.DeletedAt.Valid && !t.Before(pod.DeletedAt.Time) {
+			continue
+		}
+		return pod.Uid, true
+	}
+	return pgtype.UUID{}, false
+}
+
+type hourKey struct {
+	PodUid [16]byte
+	Hour   int64
+}
+
+// aggregator folds samples into hourly rows with the same semantics as UpsertPodUsedCPU/UpsertPodUsedMemory
+type aggregator struct {
+	resolver *podResolver
+	start    time.Time
+	end      time.Time
+	rows     map[hourKey]*queries.InsertPodUsageHourlyParams
+	// number of samples which couldn't be matched to a stored pod
+	unknown int
+}
+
+func newAggregator(resolver *podResolver, start, end time.Time) *aggregator {
+	return &aggregator{
+		resolver: resolver,
+		start:    start,
+		end:      end,
+		rows:     make(map[hourKey]*queries.InsertPodUsageHourlyParams),
+	}
+}
+
+func (a *aggregator) addCPU(series []Series) {
+	a.add(series, func(row *queries.InsertPodUsageHourlyParams, value float64) {
+		row.CpuCoresMin = minReading(row.CpuCoresMin, value)
+		if value > row.CpuCoresMax {
+			row.CpuCoresMax = value
+		}
+		row.CpuCoresTotal += value
+		row.CpuCoresTotalReadings++
+	})
+}
+
+func (a *aggregator) addMemory(series []Series) {
+	a.add(series, func(row *queries.InsertPodUsageHourlyParams, value float64) {
+		row.MemoryBytesMin = minReading(row.MemoryBytesMin, value)
+		if value > row.MemoryBytesMax {
+			row.MemoryBytesMax = value
+		}
+		row.MemoryBytesTotal += value
+		row.MemoryBytesTotalReadings++
+	})
+}
+
+func (a *aggregator) add(series []Series, update func(row *queries.InsertPodUsageHourlyParams, value float64)) {
+	for _, s := range series {
+		namespace, name := s.Labels["namespace"], s.Labels["pod"]
+		for _, sample := range s.Samples {
+			// query_range includes both ends, the end sample belongs to the next chunk
+			if sample.Time.Before(a.start) || !sample.Time.Before(a.end) {
+				continue
+			}
+			uid, ok := a.resolver.Resolve(namespace, name, sample.Time)
+			if !ok {
+				a.unknown++
+				continue
+			}
+			hour := truncateToHour(sample.Time)
+			key := hourKey{PodUid: uid.Bytes, Hour: hour.Unix()}
+			row, ok := a.rows[key]
+			if !ok {
+				row = &queries.InsertPodUsageHourlyParams{
+					PodUid:    uid,
+					Timestamp: pgtype.Timestamptz{Time: hour, Valid: true},
+				}
+				a.rows[key] = row
+			}
+			update(row, sample.Value)
+		}
+	}
+}
+
+func (a *aggregator) result() []queries.InsertPodUsageHourlyParams {
+	result := make([]queries.InsertPodUsageHourlyParams, 0, len(a.rows))
+	for _, row := range a.rows {
+		result = append(result, *row)
+	}
+	// deterministic order keeps batches reproducible
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].Timestamp.Time.Equal(result[j].Timestamp.Time) {
+			return result[i].Timestamp.Time.Before(result[j].Timestamp.Time)
+		}
+		return string(result[i].PodUid.Bytes[:]) < string(result[j].PodUid.Bytes[:])
+	})
+	return result
+}
+
+// minReading mirrors the min update in pod_usage_hourly where 0 means no reading
+func minReading(current, value float64) float64 {
+	if current < value && current != 0 {
+		return current
+	}
+	return value
+}
+
+func truncateToHour(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Hour)
+}
@@ -0,0 +1,157 @@
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+
+	"github.com/r2k1/pgkube/app/queries"
+	"github.com/r2k1/pgkube/app/test"
+)
+
+func TestPodResolver(t *testing.T) {
+	created := time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)
+	oldPod := queries.PodLifetime{
+		Uid:       test.MustParsePGUUID("00000000-0000-0000-0000-000000000001"),
+		Namespace: "default",
+		Name:      "app",
+		CreatedAt: pgtype.Timestamptz{Time: created, Valid: true},
+		DeletedAt: pgtype.Timestamptz{Time: created.Add(time.Hour), Valid: true},
+	}
+	newPod := queries.PodLifetime{
+		Uid:       test.MustParsePGUUID("00000000-0000-0000-0000-000000000002"),
+		Namespace: "default",
+		Name:      "app",
+		CreatedAt: pgtype.Timestamptz{Time: created.Add(time.Hour), Valid: true},
+	}
+	resolver := newPodResolver([]queries.PodLifetime{oldPod, newPod})
+
+	uid, ok := resolver.Resolve("default", "app", created.Add(time.Minute))
+	require.True(t, ok)
+	assert.Equal(t, oldPod.Uid, uid)
+
+	uid, ok = resolver.Resolve("default", "app", created.Add(time.Hour))
+	require.True(t, ok)
+	assert.Equal(t, newPod.Uid, uid)
+
+	_, ok = resolver.Resolve("default", "app", created.Add(-time.Minute))
+	assert.False(t, ok)
+
+	_, ok = resolver.Resolve("other", "app", created.Add(time.Minute))
+	assert.False(t, ok)
+}
+
+func TestAggregator(t *testing.T) {
+	start := time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)
+	pod := queries.PodLifetime{
+		Uid:       test.MustParsePGUUID("00000000-0000-0000-0000-000000000001"),
+		Namespace: "default",
+		Name:      "app",
+	}
+	agg := newAggregator(newPodResolver([]queries.PodLifetime{pod}), start, start.Add(2*time.Hour))
+	labels := map[string]string{"namespace": "default", "pod": "app"}
+	agg.addCPU([]Series{{Labels: labels, Samples: []Sample{
+		{Time: start, Value: 1},
+		{Time: start.Add(30 * time.Minute), Value: 3},
+		{Time: start.Add(time.Hour), Value: 2},
+		{Time: start.Add(2 * time.Hour), Value: 100}, // outside of the range
+	}}})
+	agg.addMemory([]Series{{Labels: labels, Samples: []Sample{
+		{Time: start, Value: 100},
+	}}})
+	agg.addMemory([]Series{{Labels: map[string]string{"namespace": "default", "pod": "unknown"}, Samples: []Sample{
+		{Time: start, Value: 100},
+	}}})
+
+	assert.Equal(t, []queries.InsertPodUsageHourlyParams{
+		{
+			PodUid:                   pod.Uid,
+			Timestamp:                pgtype.Timestamptz{Time: start, Valid: true},
+			MemoryBytesMax:           100,
+			MemoryBytesMin:           100,
+			MemoryBytesTotal:         100,
+			MemoryBytesTotalReadings: 1,
+			CpuCoresMax:              3,
+			CpuCoresMin:              1,
+			CpuCoresTotal:            4,
+			CpuCoresTotalReadings:    2,
+		},
+		{
+			PodUid:                pod.Uid,
+			Timestamp:             pgtype.Timestamptz{Time: start.Add(time.Hour), Valid: true},
+			CpuCoresMax:           2,
+			CpuCoresMin:           2,
+			CpuCoresTotal:         2,
+			CpuCoresTotalReadings: 1,
+		},
+	}, agg.result())
+	assert.Equal(t, 1, agg.unknown)
+}
+
+func TestBackfiller_Run(t *testing.T) {
+	ctx := context.Background()
+	db := test.CreateTestDB(t, "../migrations")
+	q, err := queries.New(ctx, db, "test-cluster")
+	require.NoError(t, err)
+
+	start := time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)
+	podUID := uuid.NewUUID()
+	require.NoError(t, q.UpsertObject(ctx, "Pod", &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			UID:               podUID,
+			Namespace:         "default",
+			Name:              "app",
+			CreationTimestamp: metav1.Time{Time: start.Add(-time.Hour)},
+		},
+	}))
+
+	var queriesReceived []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		query := r.Form.Get("query")
+		queriesReceived = append(queriesReceived, query)
+		value := "0.5"
+		if strings.Contains(query, "memory") {
+			value = "1024"
+		}
+		ts := float64(start.Unix())
+		_, _ = fmt.Fprintf(w, `{"status":"success","data":{"resultType":"matrix","result":[
+			{"metric":{"namespace":"default","pod":"app"},"values":[[%f,"%s"],[%f,"%s"]]}
+		]}}`, ts, value, ts+60, value)
+	}))
+	t.Cleanup(srv.Close)
+
+	backfiller := NewBackfiller(NewPrometheusClient(srv.URL, nil), q, Options{Step: time.Minute, Chunk: time.Hour})
+	end, err := backfiller.DefaultEnd(ctx)
+	require.NoError(t, err)
+	assert.False(t, end.Before(start), "without scraped data the range ends now")
+
+	require.NoError(t, backfiller.Run(ctx, start, start.Add(time.Hour)))
+	assert.Equal(t, []string{cpuQuery, memoryQuery}, queriesReceived)
+	// hours imported before are skipped
+	require.NoError(t, backfiller.Run(ctx, start, start.Add(time.Hour)))
+
+	usage, err := q.ListPodUsageHourly(ctx)
+	require.NoError(t, err)
+	require.Len(t, usage, 1)
+	assert.Equal(t, test.MustParsePGUUID(string(podUID)), usage[0].PodUid)
+	assert.True(t, usage[0].Timestamp.Time.Equal(start))
+	assert.Equal(t, int32(2), usage[0].CpuCoresTotalReadings)
+	assert.InDelta(t, 0.5, usage[0].CpuCoresAvg, 0.0001)
+	assert.InDelta(t, 1024.0, usage[0].MemoryBytesAvg, 0.0001)
+
+	end, err = backfiller.DefaultEnd(ctx)
+	require.NoError(t, err)
+	assert.True(t, end.Equal(start))
+}
@@ -0,0 +1,131 @@
+package backfill
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PrometheusClient is a minimal client for the Prometheus HTTP API
+// it works with any compatible implementation (Thanos, Mimir, VictoriaMetrics, etc.)
+type PrometheusClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func NewPrometheusClient(baseURL string, httpClient *http.Client) *PrometheusClient {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &PrometheusClient{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: httpClient,
+	}
+}
+
+type Sample struct {
+	Time  time.Time
+	Value float64
+}
+
+type Series struct {
+	Labels  map[string]string
+	Samples []Sample
+}
+
+type queryRangeResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Values [][2]any          `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+// QueryRange evaluates an expression over a range of time, start and end are inclusive
+func (c *PrometheusClient) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) ([]Series, error) {
+	form := url.Values{}
+	form.Set("query", query)
+	form.Set("start", formatTime(start))
+	form.Set("end", formatTime(end))
+	form.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/v1/query_range", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("querying prometheus: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading prometheus response: %w", err)
+	}
+
+	var data queryRangeResponse
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("decoding prometheus response (status %d): %w", resp.StatusCode, err)
+	}
+	if data.Status != "success" {
+		return nil, fmt.Errorf("prometheus query failed (status %d): %s: %s", resp.StatusCode, data.ErrorType, data.Error)
+	}
+	if data.Data.ResultType != "matrix" {
+		return nil, fmt.Errorf("unexpected prometheus result type: %s", data.Data.ResultType)
+	}
+
+	result := make([]Series, 0, len(data.Data.Result))
+	for _, item := range data.Data.Result {
+		series := Series{
+			Labels:  item.Metric,
+			Samples: make([]Sample, 0, len(item.Values)),
+		}
+		for _, value := range item.Values {
+			sample, err := parseSample(value)
+			if err != nil {
+				return nil, err
+			}
+			series.Samples = append(series.Samples, sample)
+		}
+		result = append(result, series)
+	}
+	return result, nil
+}
+
+// parseSample parses a [<unix seconds>, "<value>"] pair
+func parseSample(value [2]any) (Sample, error) {
+	ts, ok := value[0].(float64)
+	if !ok {
+		return Sample{}, fmt.Errorf("unexpected sample timestamp: %v", value[0])
+	}
+	str, ok := value[1].(string)
+	if !ok {
+		return Sample{}, fmt.Errorf("unexpected sample value: %v", value[1])
+	}
+	v, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return Sample{}, fmt.Errorf("parsing sample value: %w", err)
+	}
+	return Sample{
+		Time:  time.UnixMilli(int64(ts * 1000)).UTC(),
+		Value: v,
+	}, nil
+}
+
+func formatTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixMilli())/1000, 'f', -1, 64)
+}
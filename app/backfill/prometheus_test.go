@@ -0,0 +1,61 @@
+package backfill
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrometheusClient_QueryRange(t *testing.T) {
+	t.Run("parses matrix response", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/prefix/api/v1/query_range", r.URL.Path)
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "up", r.Form.Get("query"))
+			assert.Equal(t, "1701388800", r.Form.Get("start"))
+			assert.Equal(t, "1701392400", r.Form.Get("end"))
+			assert.Equal(t, "60", r.Form.Get("step"))
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[
+				{"metric":{"namespace":"default","pod":"app"},"values":[[1701388800,"1.5"],[1701388860.5,"2"]]}
+			]}}`))
+		}))
+		t.Cleanup(srv.Close)
+
+		client := NewPrometheusClient(srv.URL+"/prefix/", nil)
+		start := time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)
+		series, err := client.QueryRange(context.Background(), "up", start, start.Add(time.Hour), time.Minute)
+		require.NoError(t, err)
+		require.Len(t, series, 1)
+		assert.Equal(t, map[string]string{"namespace": "default", "pod": "app"}, series[0].Labels)
+		assert.Equal(t, []Sample{
+			{Time: start, Value: 1.5},
+			{Time: start.Add(60500 * time.Millisecond), Value: 2},
+		}, series[0].Samples)
+	})
+
+	t.Run("returns prometheus error", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"status":"error","errorType":"bad_data","error":"parse error"}`))
+		}))
+		t.Cleanup(srv.Close)
+
+		_, err := NewPrometheusClient(srv.URL, nil).QueryRange(context.Background(), "up{", time.Now(), time.Now(), time.Minute)
+		require.ErrorContains(t, err, "parse error")
+	})
+
+	t.Run("rejects non matrix result", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+		}))
+		t.Cleanup(srv.Close)
+
+		_, err := NewPrometheusClient(srv.URL, nil).QueryRange(context.Background(), "up", time.Now(), time.Now(), time.Minute)
+		require.Error(t, err)
+	})
+}
@@ -3,7 +3,9 @@ package k8s
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/prometheus/common/expfmt"
 	"k8s.io/client-go/kubernetes"
@@ -16,17 +18,59 @@ type Client struct {
 }
 
 type NodeMetrics struct {
-	PodCPUUsageSecondsTotal  PodMetric
-	PodMemoryWorkingSetBytes PodMetric
+	PodCPUUsageSecondsTotal        PodMetric
+	PodMemoryWorkingSetBytes       PodMetric
+	ContainerCPUUsageSecondsTotal  ContainerMetric
+	ContainerMemoryWorkingSetBytes ContainerMetric
+	// CPU usage in cores, set instead of cumulative seconds by sources which report rates (metrics.k8s.io)
+	PodCPUUsageCores       PodMetric
+	ContainerCPUUsageCores ContainerMetric
+}
+
+// CadvisorMetrics are per-pod metrics aggregated from the kubelet /metrics/cadvisor endpoint
+type CadvisorMetrics struct {
+	PodNetworkReceiveBytesTotal    PodMetric
+	PodNetworkTransmitBytesTotal   PodMetric
+	PodFsUsageBytes                PodMetric
+	PodCPUCFSPeriodsTotal          PodMetric
+	PodCPUCFSThrottledPeriodsTotal PodMetric
 }
 
 type PodMetric map[PodKey]MetricValue
 
+// VolumeStats is disk usage reported by the kubelet stats summary
+type VolumeStats struct {
+	PVCs PVCMetric
+	// PodEphemeralStorageBytes is used by container writable layers, logs and emptyDir volumes of the pod
+	PodEphemeralStorageBytes PodMetric
+}
+
+// PVCMetric contains volume usage reported by the kubelet for persistent volume claims mounted on the node
+type PVCMetric map[PVCKey]VolumeUsage
+
+type PVCKey struct {
+	Name      string
+	Namespace string
+}
+
+type VolumeUsage struct {
+	UsedBytes     float64
+	CapacityBytes float64
+	TimestampMs   int64
+}
+
+type ContainerMetric map[ContainerKey]MetricValue
+
 type PodKey struct {
 	Name      string
 	Namespace string
 }
 
+type ContainerKey struct {
+	Pod       PodKey
+	Container string
+}
+
 type MetricValue struct {
 	Value       float64
 	TimestampMs int64
@@ -37,6 +81,8 @@ var _ ClientInterface = &Client{}
 //go:generate moq -out client_mock.go . ClientInterface:ClientMock
 type ClientInterface interface {
 	NodeMetrics(ctx context.Context, nodeName string) (NodeMetrics, error)
+	NodeCadvisorMetrics(ctx context.Context, nodeName string) (CadvisorMetrics, error)
+	NodeVolumeStats(ctx context.Context, nodeName string) (VolumeStats, error)
 }
 
 func NewClient(clientset *kubernetes.Clientset) *Client {
@@ -45,44 +91,197 @@ func NewClient(clientset *kubernetes.Clientset) *Client {
 }
 
 func (c *Client) NodeMetrics(ctx context.Context, nodeName string) (NodeMetrics, error) {
+	metrics, err := c.nodeMetricFamilies(ctx, nodeName, "metrics/resource")
+	if err != nil {
+		return NodeMetrics{}, err
+	}
+	return NodeMetrics{
+		PodCPUUsageSecondsTotal:        podMetric(metrics["pod_cpu_usage_seconds_total"], counterValue),
+		PodMemoryWorkingSetBytes:       podMetric(metrics["pod_memory_working_set_bytes"], gaugeValue),
+		ContainerCPUUsageSecondsTotal:  containerMetric(metrics["container_cpu_usage_seconds_total"], counterValue),
+		ContainerMemoryWorkingSetBytes: containerMetric(metrics["container_memory_working_set_bytes"], gaugeValue),
+	}, nil
+}
+
+// NodeCadvisorMetrics reads /metrics/cadvisor of the node and sums container series into pod totals
+func (c *Client) NodeCadvisorMetrics(ctx context.Context, nodeName string) (CadvisorMetrics, error) {
+	metrics, err := c.nodeMetricFamilies(ctx, nodeName, "metrics/cadvisor")
+	if err != nil {
+		return CadvisorMetrics{}, err
+	}
+	return CadvisorMetrics{
+		PodNetworkReceiveBytesTotal:    podNetworkMetric(metrics["container_network_receive_bytes_total"]),
+		PodNetworkTransmitBytesTotal:   podNetworkMetric(metrics["container_network_transmit_bytes_total"]),
+		PodFsUsageBytes:                podContainersSum(metrics["container_fs_usage_bytes"], gaugeValue),
+		PodCPUCFSPeriodsTotal:          podContainersSum(metrics["container_cpu_cfs_periods_total"], counterValue),
+		PodCPUCFSThrottledPeriodsTotal: podContainersSum(metrics["container_cpu_cfs_throttled_periods_total"], counterValue),
+	}, nil
+}
+
+// statsSummary is a subset of the kubelet /stats/summary response
+type statsSummary struct {
+	Pods []struct {
+		PodRef struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"podRef"`
+		EphemeralStorage *struct {
+			Time      time.Time `json:"time"`
+			UsedBytes *uint64   `json:"usedBytes"`
+		} `json:"ephemeral-storage"`
+		Volumes []struct {
+			Time          time.Time `json:"time"`
+			UsedBytes     *uint64   `json:"usedBytes"`
+			CapacityBytes *uint64   `json:"capacityBytes"`
+			PVCRef        *struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"pvcRef"`
+		} `json:"volume"`
+	} `json:"pods"`
+}
+
+// NodeVolumeStats reads /stats/summary of the node and returns usage of mounted persistent volume claims and ephemeral storage of pods
+func (c *Client) NodeVolumeStats(ctx context.Context, nodeName string) (VolumeStats, error) {
+	body, err := c.internal.CoreV1().RESTClient().Get().
+		Resource("nodes").Name(nodeName).SubResource("proxy").
+		Suffix("stats/summary").DoRaw(ctx)
+	if err != nil {
+		return VolumeStats{}, fmt.Errorf("getting node stats summary: %w", err)
+	}
+	var summary statsSummary
+	if err := json.Unmarshal(body, &summary); err != nil {
+		return VolumeStats{}, fmt.Errorf("parsing node stats summary: %w", err)
+	}
+	result := VolumeStats{
+		PVCs:                     make(PVCMetric),
+		PodEphemeralStorageBytes: make(PodMetric),
+	}
+	for _, pod := range summary.Pods {
+		if ephemeral := pod.EphemeralStorage; ephemeral != nil && ephemeral.UsedBytes != nil {
+			result.PodEphemeralStorageBytes[PodKey{Name: pod.PodRef.Name, Namespace: pod.PodRef.Namespace}] = MetricValue{
+				Value:       float64(*ephemeral.UsedBytes),
+				TimestampMs: ephemeral.Time.UnixMilli(),
+			}
+		}
+		for _, volume := range pod.Volumes {
+			if volume.PVCRef == nil || volume.UsedBytes == nil {
+				continue
+			}
+			usage := VolumeUsage{
+				UsedBytes:   float64(*volume.UsedBytes),
+				TimestampMs: volume.Time.UnixMilli(),
+			}
+			if volume.CapacityBytes != nil {
+				usage.CapacityBytes = float64(*volume.CapacityBytes)
+			}
+			// the same claim is reported once per pod using it
+			result.PVCs[PVCKey{Name: volume.PVCRef.Name, Namespace: volume.PVCRef.Namespace}] = usage
+		}
+	}
+	return result, nil
+}
+
+func (c *Client) nodeMetricFamilies(ctx context.Context, nodeName string, path string) (map[string]*dto.MetricFamily, error) {
 	body, err := c.internal.CoreV1().RESTClient().Get().
 		Resource("nodes").Name(nodeName).SubResource("proxy").
-		Suffix("metrics/resource").DoRaw(ctx)
+		Suffix(path).DoRaw(ctx)
 	if err != nil {
-		return NodeMetrics{}, fmt.Errorf("getting node metrics: %w", err)
+		return nil, fmt.Errorf("getting node %s: %w", path, err)
 	}
 	parser := &expfmt.TextParser{}
 	metrics, err := parser.TextToMetricFamilies(bytes.NewReader(body))
 	if err != nil {
-		return NodeMetrics{}, fmt.Errorf("parsing node metrics: %w", err)
-	}
-	result := NodeMetrics{
-		PodCPUUsageSecondsTotal:  make(PodMetric),
-		PodMemoryWorkingSetBytes: make(PodMetric),
-	}
-	if metric, ok := metrics["pod_cpu_usage_seconds_total"]; ok {
-		for _, m := range metric.GetMetric() {
-			result.PodCPUUsageSecondsTotal[PodKey{
-				Name:      getLabel(m.GetLabel(), "pod"),
-				Namespace: getLabel(m.GetLabel(), "namespace"),
-			}] = MetricValue{
-				Value:       m.GetCounter().GetValue(),
-				TimestampMs: m.GetTimestampMs(),
-			}
+		return nil, fmt.Errorf("parsing node %s: %w", path, err)
+	}
+	return metrics, nil
+}
+
+func counterValue(m *dto.Metric) float64 {
+	return m.GetCounter().GetValue()
+}
+
+func gaugeValue(m *dto.Metric) float64 {
+	return m.GetGauge().GetValue()
+}
+
+// podMetric converts a metric family with pod and namespace labels into a PodMetric
+// missing family results in an empty map
+func podMetric(family *dto.MetricFamily, value func(*dto.Metric) float64) PodMetric {
+	result := make(PodMetric)
+	for _, m := range family.GetMetric() {
+		result[podKey(m)] = MetricValue{
+			Value:       value(m),
+			TimestampMs: m.GetTimestampMs(),
 		}
 	}
-	if metric, ok := metrics["pod_memory_working_set_bytes"]; ok {
-		for _, m := range metric.GetMetric() {
-			result.PodMemoryWorkingSetBytes[PodKey{
-				Name:      getLabel(m.GetLabel(), "pod"),
-				Namespace: getLabel(m.GetLabel(), "namespace"),
-			}] = MetricValue{
-				Value:       m.GetGauge().GetValue(),
-				TimestampMs: m.GetTimestampMs(),
-			}
+	return result
+}
+
+// containerMetric converts a metric family with container, pod and namespace labels into a ContainerMetric
+// missing family results in an empty map
+func containerMetric(family *dto.MetricFamily, value func(*dto.Metric) float64) ContainerMetric {
+	result := make(ContainerMetric)
+	for _, m := range family.GetMetric() {
+		result[ContainerKey{
+			Pod:       podKey(m),
+			Container: getLabel(m.GetLabel(), "container"),
+		}] = MetricValue{
+			Value:       value(m),
+			TimestampMs: m.GetTimestampMs(),
 		}
 	}
-	return result, nil
+	return result
+}
+
+// podContainersSum sums values of all application containers of a pod
+// cgroup series of the pod itself (empty container label) and the pause container are skipped to avoid double counting
+func podContainersSum(family *dto.MetricFamily, value func(*dto.Metric) float64) PodMetric {
+	result := make(PodMetric)
+	for _, m := range family.GetMetric() {
+		container := getLabel(m.GetLabel(), "container")
+		if container == "" || container == "POD" {
+			continue
+		}
+		key := podKey(m)
+		current := result[key]
+		current.Value += value(m)
+		current.TimestampMs = max(current.TimestampMs, m.GetTimestampMs())
+		result[key] = current
+	}
+	return result
+}
+
+// podNetworkMetric sums network counters of all pod interfaces
+// containers of a pod share the network namespace, so the same interface may be reported by several series
+func podNetworkMetric(family *dto.MetricFamily) PodMetric {
+	type interfaceKey struct {
+		pod   PodKey
+		iface string
+	}
+	interfaces := make(map[interfaceKey]MetricValue)
+	for _, m := range family.GetMetric() {
+		key := interfaceKey{pod: podKey(m), iface: getLabel(m.GetLabel(), "interface")}
+		current := interfaces[key]
+		current.Value = max(current.Value, counterValue(m))
+		current.TimestampMs = max(current.TimestampMs, m.GetTimestampMs())
+		interfaces[key] = current
+	}
+	result := make(PodMetric)
+	for key, value := range interfaces {
+		current := result[key.pod]
+		current.Value += value.Value
+		current.TimestampMs = max(current.TimestampMs, value.TimestampMs)
+		result[key.pod] = current
+	}
+	return result
+}
+
+func podKey(m *dto.Metric) PodKey {
+	return PodKey{
+		Name:      getLabel(m.GetLabel(), "pod"),
+		Namespace: getLabel(m.GetLabel(), "namespace"),
+	}
 }
 
 func getLabel(labels []*dto.LabelPair, name string) string {
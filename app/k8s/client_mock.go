@@ -18,9 +18,15 @@ var _ ClientInterface = &ClientMock{}
 //
 //		// make and configure a mocked ClientInterface
 //		mockedClientInterface := &ClientMock{
+//			NodeCadvisorMetricsFunc: func(ctx context.Context, nodeName string) (CadvisorMetrics, error) {
+//				panic("mock out the NodeCadvisorMetrics method")
+//			},
 //			NodeMetricsFunc: func(ctx context.Context, nodeName string) (NodeMetrics, error) {
 //				panic("mock out the NodeMetrics method")
 //			},
+//			NodeVolumeStatsFunc: func(ctx context.Context, nodeName string) (VolumeStats, error) {
+//				panic("mock out the NodeVolumeStats method")
+//			},
 //		}
 //
 //		// use mockedClientInterface in code that requires ClientInterface
@@ -28,11 +34,24 @@ var _ ClientInterface = &ClientMock{}
 //
 //	}
 type ClientMock struct {
+	// NodeCadvisorMetricsFunc mocks the NodeCadvisorMetrics method.
+	NodeCadvisorMetricsFunc func(ctx context.Context, nodeName string) (CadvisorMetrics, error)
+
 	// NodeMetricsFunc mocks the NodeMetrics method.
 	NodeMetricsFunc func(ctx context.Context, nodeName string) (NodeMetrics, error)
 
+	// NodeVolumeStatsFunc mocks the NodeVolumeStats method.
+	NodeVolumeStatsFunc func(ctx context.Context, nodeName string) (VolumeStats, error)
+
 	// calls tracks calls to the methods.
 	calls struct {
+		// NodeCadvisorMetrics holds details about calls to the NodeCadvisorMetrics method.
+		NodeCadvisorMetrics []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// NodeName is the nodeName argument value.
+			NodeName string
+		}
 		// NodeMetrics holds details about calls to the NodeMetrics method.
 		NodeMetrics []struct {
 			// Ctx is the ctx argument value.
@@ -40,8 +59,53 @@ type ClientMock struct {
 			// NodeName is the nodeName argument value.
 			NodeName string
 		}
+		// NodeVolumeStats holds details about calls to the NodeVolumeStats method.
+		NodeVolumeStats []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// NodeName is the nodeName argument value.
+			NodeName string
+		}
+	}
+	lockNodeCadvisorMetrics sync.RWMutex
+	lockNodeMetrics         sync.RWMutex
+	lockNodeVolumeStats     sync.RWMutex
+}
+
+// NodeCadvisorMetrics calls NodeCadvisorMetricsFunc.
+func (mock *ClientMock) NodeCadvisorMetrics(ctx context.Context, nodeName string) (CadvisorMetrics, error) {
+	if mock.NodeCadvisorMetricsFunc == nil {
+		panic("ClientMock.NodeCadvisorMetricsFunc: method is nil but ClientInterface.NodeCadvisorMetrics was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		NodeName string
+	}{
+		Ctx:      ctx,
+		NodeName: nodeName,
+	}
+	mock.lockNodeCadvisorMetrics.Lock()
+	mock.calls.NodeCadvisorMetrics = append(mock.calls.NodeCadvisorMetrics, callInfo)
+	mock.lockNodeCadvisorMetrics.Unlock()
+	return mock.NodeCadvisorMetricsFunc(ctx, nodeName)
+}
+
+// NodeCadvisorMetricsCalls gets all the calls that were made to NodeCadvisorMetrics.
+// Check the length with:
+//
+//	len(mockedClientInterface.NodeCadvisorMetricsCalls())
+func (mock *ClientMock) NodeCadvisorMetricsCalls() []struct {
+	Ctx      context.Context
+	NodeName string
+} {
+	var calls []struct {
+		Ctx      context.Context
+		NodeName string
 	}
-	lockNodeMetrics sync.RWMutex
+	mock.lockNodeCadvisorMetrics.RLock()
+	calls = mock.calls.NodeCadvisorMetrics
+	mock.lockNodeCadvisorMetrics.RUnlock()
+	return calls
 }
 
 // NodeMetrics calls NodeMetricsFunc.
@@ -79,3 +143,39 @@ func (mock *ClientMock) NodeMetricsCalls() []struct {
 	mock.lockNodeMetrics.RUnlock()
 	return calls
 }
+
+// NodeVolumeStats calls NodeVolumeStatsFunc.
+func (mock *ClientMock) NodeVolumeStats(ctx context.Context, nodeName string) (VolumeStats, error) {
+	if mock.NodeVolumeStatsFunc == nil {
+		panic("ClientMock.NodeVolumeStatsFunc: method is nil but ClientInterface.NodeVolumeStats was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		NodeName string
+	}{
+		Ctx:      ctx,
+		NodeName: nodeName,
+	}
+	mock.lockNodeVolumeStats.Lock()
+	mock.calls.NodeVolumeStats = append(mock.calls.NodeVolumeStats, callInfo)
+	mock.lockNodeVolumeStats.Unlock()
+	return mock.NodeVolumeStatsFunc(ctx, nodeName)
+}
+
+// NodeVolumeStatsCalls gets all the calls that were made to NodeVolumeStats.
+// Check the length with:
+//
+//	len(mockedClientInterface.NodeVolumeStatsCalls())
+func (mock *ClientMock) NodeVolumeStatsCalls() []struct {
+	Ctx      context.Context
+	NodeName string
+} {
+	var calls []struct {
+		Ctx      context.Context
+		NodeName string
+	}
+	mock.lockNodeVolumeStats.RLock()
+	calls = mock.calls.NodeVolumeStats
+	mock.lockNodeVolumeStats.RUnlock()
+	return calls
+}
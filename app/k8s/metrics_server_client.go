@@ -0,0 +1,126 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	metricsv1beta1 "k8s.io/metrics/pkg/client/clientset/versioned/typed/metrics/v1beta1"
+)
+
+// ErrNotSupported is returned for metrics which are not exposed by the metrics.k8s.io API
+var ErrNotSupported = errors.New("not supported by metrics.k8s.io")
+
+// podMetricsTTL is how long the pod metrics list is reused between node scrapes
+// metrics-server refreshes usage every 15 seconds by default
+const podMetricsTTL = 15 * time.Second
+
+var _ ClientInterface = &MetricsServerClient{}
+
+// PodLister lists pods of the informer cache, e.g. listerv1.PodLister
+type PodLister interface {
+	List(selector labels.Selector) ([]*v1.Pod, error)
+}
+
+// MetricsServerClient reads pod usage from the metrics.k8s.io API (metrics-server)
+// it can be used in clusters where nodes/proxy subresource is not accessible
+// the API doesn't support filtering by node, so pod metrics of the whole cluster are fetched once and
+// split by node using the pod lister
+type MetricsServerClient struct {
+	metrics   metricsv1beta1.MetricsV1beta1Interface
+	pods      PodLister
+	mutex     sync.Mutex
+	fetchedAt time.Time
+	byNode    map[string]NodeMetrics
+}
+
+func NewMetricsServerClient(metrics metricsv1beta1.MetricsV1beta1Interface, pods PodLister) *MetricsServerClient {
+	return &MetricsServerClient{
+		metrics: metrics,
+		pods:    pods,
+	}
+}
+
+// NodeMetrics returns CPU usage as cores (rate) instead of cumulative seconds
+func (c *MetricsServerClient) NodeMetrics(ctx context.Context, nodeName string) (NodeMetrics, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if time.Since(c.fetchedAt) > podMetricsTTL {
+		byNode, err := c.fetch(ctx)
+		if err != nil {
+			return NodeMetrics{}, err
+		}
+		c.byNode = byNode
+		c.fetchedAt = time.Now()
+	}
+	result, ok := c.byNode[nodeName]
+	if !ok {
+		return newRateNodeMetrics(), nil
+	}
+	return result, nil
+}
+
+func (c *MetricsServerClient) NodeCadvisorMetrics(ctx context.Context, nodeName string) (CadvisorMetrics, error) {
+	return CadvisorMetrics{}, fmt.Errorf("getting cadvisor metrics: %w", ErrNotSupported)
+}
+
+func (c *MetricsServerClient) NodeVolumeStats(ctx context.Context, nodeName string) (VolumeStats, error) {
+	return VolumeStats{}, fmt.Errorf("getting volume stats: %w", ErrNotSupported)
+}
+
+func (c *MetricsServerClient) fetch(ctx context.Context) (map[string]NodeMetrics, error) {
+	podMetricsList, err := c.metrics.PodMetricses(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing pod metrics: %w", err)
+	}
+	pods, err := c.pods.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("listing pods: %w", err)
+	}
+	podNodes := make(map[PodKey]string, len(pods))
+	for _, pod := range pods {
+		podNodes[PodKey{Name: pod.Name, Namespace: pod.Namespace}] = pod.Spec.NodeName
+	}
+
+	result := make(map[string]NodeMetrics)
+	for _, podMetrics := range podMetricsList.Items {
+		key := PodKey{Name: podMetrics.Name, Namespace: podMetrics.Namespace}
+		nodeName, ok := podNodes[key]
+		if !ok || nodeName == "" {
+			continue
+		}
+		nodeMetrics, ok := result[nodeName]
+		if !ok {
+			nodeMetrics = newRateNodeMetrics()
+			result[nodeName] = nodeMetrics
+		}
+		timestampMs := podMetrics.Timestamp.UnixMilli()
+		var podCores, podMemory float64
+		for _, container := range podMetrics.Containers {
+			cores := container.Usage.Cpu().AsApproximateFloat64()
+			memory := container.Usage.Memory().AsApproximateFloat64()
+			podCores += cores
+			podMemory += memory
+			containerKey := ContainerKey{Pod: key, Container: container.Name}
+			nodeMetrics.ContainerCPUUsageCores[containerKey] = MetricValue{Value: cores, TimestampMs: timestampMs}
+			nodeMetrics.ContainerMemoryWorkingSetBytes[containerKey] = MetricValue{Value: memory, TimestampMs: timestampMs}
+		}
+		nodeMetrics.PodCPUUsageCores[key] = MetricValue{Value: podCores, TimestampMs: timestampMs}
+		nodeMetrics.PodMemoryWorkingSetBytes[key] = MetricValue{Value: podMemory, TimestampMs: timestampMs}
+	}
+	return result, nil
+}
+
+func newRateNodeMetrics() NodeMetrics {
+	return NodeMetrics{
+		PodCPUUsageCores:               make(PodMetric),
+		PodMemoryWorkingSetBytes:       make(PodMetric),
+		ContainerCPUUsageCores:         make(ContainerMetric),
+		ContainerMemoryWorkingSetBytes: make(ContainerMetric),
+	}
+}
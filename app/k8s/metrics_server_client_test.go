@@ -0,0 +1,80 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	listerv1 "k8s.io/client-go/listers/core/v1"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	metricsapi "k8s.io/metrics/pkg/apis/metrics/v1beta1"
+	metricsfake "k8s.io/metrics/pkg/client/clientset/versioned/fake"
+)
+
+func TestMetricsServerClient_NodeMetrics(t *testing.T) {
+	timestamp := time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC)
+	podMetrics := func(namespace, name string, containers ...metricsapi.ContainerMetrics) metricsapi.PodMetrics {
+		return metricsapi.PodMetrics{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Timestamp:  metav1.NewTime(timestamp),
+			Containers: containers,
+		}
+	}
+	containerMetrics := func(name, cpu, memory string) metricsapi.ContainerMetrics {
+		return metricsapi.ContainerMetrics{Name: name, Usage: v1.ResourceList{
+			v1.ResourceCPU:    resource.MustParse(cpu),
+			v1.ResourceMemory: resource.MustParse(memory),
+		}}
+	}
+	metricsClient := metricsfake.NewSimpleClientset()
+	lists := 0
+	// the fake tracker stores PodMetrics as "podmetricses", the typed client lists "pods"
+	metricsClient.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		lists++
+		return true, &metricsapi.PodMetricsList{Items: []metricsapi.PodMetrics{
+			podMetrics("default", "app", containerMetrics("app", "250m", "100Mi"), containerMetrics("sidecar", "50m", "28Mi")),
+			podMetrics("default", "other-node", containerMetrics("app", "1", "1Gi")),
+			podMetrics("default", "not-in-cache", containerMetrics("app", "1", "1Gi")),
+		}}, nil
+	})
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, pod := range []*v1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "app"}, Spec: v1.PodSpec{NodeName: "node-1"}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "other-node"}, Spec: v1.PodSpec{NodeName: "node-2"}},
+	} {
+		require.NoError(t, indexer.Add(pod))
+	}
+	client := NewMetricsServerClient(metricsClient.MetricsV1beta1(), listerv1.NewPodLister(indexer))
+
+	metrics, err := client.NodeMetrics(context.Background(), "node-1")
+	require.NoError(t, err)
+	key := PodKey{Namespace: "default", Name: "app"}
+	require.Len(t, metrics.PodCPUUsageCores, 1)
+	assert.InDelta(t, 0.3, metrics.PodCPUUsageCores[key].Value, 0.0001)
+	assert.Equal(t, timestamp.UnixMilli(), metrics.PodCPUUsageCores[key].TimestampMs)
+	assert.InDelta(t, float64(128*1024*1024), metrics.PodMemoryWorkingSetBytes[key].Value, 0.0001)
+	assert.InDelta(t, 0.05, metrics.ContainerCPUUsageCores[ContainerKey{Pod: key, Container: "sidecar"}].Value, 0.0001)
+	assert.InDelta(t, float64(100*1024*1024), metrics.ContainerMemoryWorkingSetBytes[ContainerKey{Pod: key, Container: "app"}].Value, 0.0001)
+
+	// other nodes reuse the list fetched for the first one
+	metrics, err = client.NodeMetrics(context.Background(), "node-2")
+	require.NoError(t, err)
+	assert.Len(t, metrics.PodCPUUsageCores, 1)
+	metrics, err = client.NodeMetrics(context.Background(), "node-3")
+	require.NoError(t, err)
+	assert.Empty(t, metrics.PodCPUUsageCores)
+	assert.Equal(t, 1, lists)
+
+	_, err = client.NodeCadvisorMetrics(context.Background(), "node-1")
+	require.ErrorIs(t, err, ErrNotSupported)
+	_, err = client.NodeVolumeStats(context.Background(), "node-1")
+	require.ErrorIs(t, err, ErrNotSupported)
+}
@@ -17,7 +17,6 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/lmittmann/tint"
 	"golang.org/x/term"
-	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/homedir"
@@ -25,6 +24,7 @@ import (
 	//_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/joho/godotenv"
 
+	"github.com/r2k1/pgkube/app/backfill"
 	"github.com/r2k1/pgkube/app/queries"
 	"github.com/r2k1/pgkube/app/scraper"
 	"github.com/r2k1/pgkube/app/server"
@@ -36,6 +36,52 @@ type Config struct {
 	LogLevel    string `env:"LOG_LEVEL" envDefault:"INFO"`
 	Addr        string `env:"ADDR" envDefault:":8080"`
 	ClusterName string `env:"CLUSTER_NAME" envDefault:"default"`
+	// Collect network, filesystem and CPU throttling metrics from the kubelet cAdvisor endpoint
+	ScrapeCadvisor bool `env:"SCRAPE_CADVISOR" envDefault:"false"`
+	// Collect persistent volume claim and ephemeral storage usage from the kubelet stats summary
+	ScrapeVolumeStats bool `env:"SCRAPE_VOLUME_STATS" envDefault:"false"`
+	// Source of pod CPU and memory usage: "kubelet" (requires nodes/proxy) or "metrics-server" (metrics.k8s.io API).
+	// cAdvisor scraping is not available and volume stats scraping is disabled with "metrics-server"
+	MetricsSource string `env:"METRICS_SOURCE" envDefault:"kubelet"`
+	// Run scraper only in the replica holding the lease, required to run more than one replica
+	LeaderElection          bool   `env:"LEADER_ELECTION" envDefault:"false"`
+	LeaderElectionNamespace string `env:"POD_NAMESPACE" envDefault:"pgkube"`
+	LeaderElectionLeaseName string `env:"LEADER_ELECTION_LEASE_NAME" envDefault:"pgkube"`
+	// Identity used for leader election, defaults to hostname
+	PodName string `env:"POD_NAME"`
+	// Usage samples and requests of all nodes are buffered and written in bulk every interval, 0 writes every node scrape directly
+	WriteBufferFlushInterval time.Duration `env:"WRITE_BUFFER_FLUSH_INTERVAL" envDefault:"10s"`
+	// Node scrapes wait for a flush when the buffer holds more samples (the database falls behind)
+	WriteBufferMaxSamples int `env:"WRITE_BUFFER_MAX_SAMPLES" envDefault:"200000"`
+	// Keep individual samples for minute resolution in the UI (e.g. 48h), hourly usage is then rolled up from them.
+	// 0 disables raw samples
+	RawSampleRetention time.Duration `env:"RAW_SAMPLE_RETENTION" envDefault:"0"`
+	// Hourly usage older than HOURLY_RETENTION is rolled up into daily usage, which is kept for DAILY_RETENTION
+	// deleted objects are purged once both are set, 0 keeps data forever (e.g. 2160h keeps hourly usage for 90 days)
+	HourlyRetention time.Duration `env:"HOURLY_RETENTION" envDefault:"0"`
+	DailyRetention  time.Duration `env:"DAILY_RETENTION" envDefault:"0"`
+	// Log what the retention job would remove without removing it
+	RetentionDryRun bool `env:"RETENTION_DRY_RUN" envDefault:"false"`
+	// Comma separated group/version/resource entries watched in addition to built-in kinds
+	// e.g. argoproj.io/v1alpha1/rollouts,keda.sh/v1alpha1/scaledobjects,karpenter.sh/v1beta1/nodeclaims
+	ExtraResources []string `env:"EXTRA_RESOURCES" envSeparator:","`
+	// Stored objects don't include metadata.managedFields and the listed annotations, they are rarely useful and large
+	ScrubManagedFields bool     `env:"SCRUB_MANAGED_FIELDS" envDefault:"true"`
+	ScrubAnnotations   []string `env:"SCRUB_ANNOTATIONS" envSeparator:"," envDefault:"kubectl.kubernetes.io/last-applied-configuration"`
+	// Comma separated kind=path entries, only the listed paths (and metadata) of the kind are stored
+	// e.g. PersistentVolume=spec.capacity,Rollout=spec.replicas. Kinds without entries are stored in full, pods, nodes,
+	// persistent volume claims and controllers are always stored in full
+	ObjectKeepPaths []string `env:"OBJECT_KEEP_PATHS" envSeparator:","`
+
+	// Backfill configuration, used by "pgkube backfill"
+	// Prometheus compatible API to import historical usage from
+	PrometheusURL string `env:"PROMETHEUS_URL"`
+	// How far back to import, counting from BACKFILL_END
+	BackfillDuration time.Duration `env:"BACKFILL_DURATION" envDefault:"720h"`
+	// End of the imported range (RFC3339), defaults to the first hour collected by the scraper
+	BackfillEnd string `env:"BACKFILL_END"`
+	// Resolution of the imported data, should match the scraping interval
+	BackfillStep time.Duration `env:"BACKFILL_STEP" envDefault:"1m"`
 
 	// Dev configuration, shouldn't be used in production
 	DisableScrapingDelay bool `env:"DISABLE_SCRAPING_DELAY" envDefault:"false"`
@@ -56,7 +102,7 @@ func (c *Config) SlogLevel() slog.Level {
 }
 
 func main() {
-	err := Execute(context.Background())
+	err := Execute(context.Background(), os.Args[1:])
 	if err != nil {
 		slog.Error("Exiting", "error", err)
 		os.Exit(1)
@@ -65,7 +111,7 @@ func main() {
 	os.Exit(0)
 }
 
-func Execute(ctx context.Context) error {
+func Execute(ctx context.Context, args []string) error {
 	_ = godotenv.Load(".env")
 
 	var cfg Config
@@ -96,18 +142,70 @@ func Execute(ctx context.Context) error {
 		return err
 	}
 
-	clientset, err := K8sClientset(cfg)
+	if len(args) > 0 {
+		switch args[0] {
+		case "backfill":
+			return Backfill(ctx, cfg, queries)
+		default:
+			return fmt.Errorf("unknown command: %s", args[0])
+		}
+	}
+
+	clusterConfig, err := k8sConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	identity := cfg.PodName
+	if identity == "" {
+		identity, err = os.Hostname()
+		if err != nil {
+			return fmt.Errorf("getting hostname: %w", err)
+		}
+	}
+
+	extraResources, err := scraper.ParseResources(cfg.ExtraResources)
+	if err != nil {
+		return err
+	}
+	keepPaths, err := scraper.ParseKeepPaths(cfg.ObjectKeepPaths)
 	if err != nil {
 		return err
 	}
 
-	err = scraper.StartScraper(ctx, queries, clientset, time.Minute, cfg.DisableScrapingDelay)
+	scr, err := scraper.StartScraper(ctx, queries, clusterConfig, scraper.Options{
+		Interval:                 time.Minute,
+		DisableScrapingDelay:     cfg.DisableScrapingDelay,
+		ScrapeCadvisor:           cfg.ScrapeCadvisor,
+		ScrapeVolumeStats:        cfg.ScrapeVolumeStats,
+		MetricsSource:            cfg.MetricsSource,
+		WriteBufferFlushInterval: cfg.WriteBufferFlushInterval,
+		WriteBufferMaxSamples:    cfg.WriteBufferMaxSamples,
+		RawSampleRetention:       cfg.RawSampleRetention,
+		ExtraResources:           extraResources,
+		Scrub: scraper.ScrubOptions{
+			DropManagedFields: cfg.ScrubManagedFields,
+			DropAnnotations:   cfg.ScrubAnnotations,
+			KeepPaths:         keepPaths,
+		},
+		Retention: scraper.RetentionOptions{
+			HourlyRetention: cfg.HourlyRetention,
+			DailyRetention:  cfg.DailyRetention,
+			DryRun:          cfg.RetentionDryRun,
+		},
+		LeaderElection: scraper.LeaderElectionOptions{
+			Enabled:   cfg.LeaderElection,
+			Namespace: cfg.LeaderElectionNamespace,
+			Name:      cfg.LeaderElectionLeaseName,
+			Identity:  identity,
+		},
+	})
 	if err != nil {
 		return err
 	}
 	ctx, cancel := context.WithCancel(ctx)
 	go func() {
-		err := server.NewSrv(queries, "templates", "assets", cfg.EnableTemplateReload).Start(cfg.Addr)
+		err := server.NewSrv(queries, scr, "templates", "assets", cfg.EnableTemplateReload).Start(cfg.Addr)
 		if err != nil {
 			slog.Error("server error", "error", err)
 		}
@@ -117,6 +215,30 @@ func Execute(ctx context.Context) error {
 	return fmt.Errorf("context done: %w", ctx.Err())
 }
 
+func Backfill(ctx context.Context, cfg Config, queries *queries.Queries) error {
+	if cfg.PrometheusURL == "" {
+		return errors.New("PROMETHEUS_URL is required for backfill")
+	}
+	backfiller := backfill.NewBackfiller(backfill.NewPrometheusClient(cfg.PrometheusURL, nil), queries, backfill.Options{
+		Step: cfg.BackfillStep,
+	})
+	var end time.Time
+	if cfg.BackfillEnd != "" {
+		var err error
+		end, err = time.Parse(time.RFC3339, cfg.BackfillEnd)
+		if err != nil {
+			return fmt.Errorf("parsing BACKFILL_END: %w", err)
+		}
+	} else {
+		var err error
+		end, err = backfiller.DefaultEnd(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return backfiller.Run(ctx, end.Add(-cfg.BackfillDuration), end)
+}
+
 func Migrate(databaseURL string) error {
 	if strings.HasPrefix(databaseURL, "postgres://") {
 		databaseURL = strings.TrimPrefix(databaseURL, "postgres")
@@ -145,18 +267,6 @@ func Migrate(databaseURL string) error {
 	return nil
 }
 
-func K8sClientset(cfg Config) (*kubernetes.Clientset, error) {
-	clusterConfig, err := k8sConfig(cfg)
-	if err != nil {
-		return nil, err
-	}
-	clientset, err := kubernetes.NewForConfig(clusterConfig)
-	if err != nil {
-		return nil, fmt.Errorf("unable to create k8s clientset: %w", err)
-	}
-	return clientset, nil
-}
-
 func k8sConfig(cfg Config) (*rest.Config, error) {
 	config, inClusterErr := rest.InClusterConfig()
 	if inClusterErr == nil {
@@ -0,0 +1,71 @@
+create table container_usage_hourly
+(
+    cluster_id                  smallint                 not null,
+    pod_uid                     uuid                     not null,
+    container_name              text                     not null,
+    timestamp                   timestamp with time zone not null,
+    memory_bytes_max            double precision         not null default 0,
+    memory_bytes_min            double precision         not null default 0,
+    memory_bytes_total          double precision         not null default 0,
+    memory_bytes_total_readings int                      not null default 0,
+    memory_bytes_avg            double precision         not null generated always as (case
+                                                                                           when memory_bytes_total_readings = 0
+                                                                                               then 0
+                                                                                           else memory_bytes_total / memory_bytes_total_readings end
+        ) stored,
+    cpu_cores_max               double precision         not null default 0,
+    cpu_cores_min               double precision         not null default 0,
+    cpu_cores_total             double precision         not null default 0,
+    cpu_cores_total_readings    int                      not null default 0,
+    cpu_cores_avg               double precision         not null generated always as (case
+                                                                                           when cpu_cores_total_readings = 0
+                                                                                               then 0
+                                                                                           else cpu_cores_total / cpu_cores_total_readings end) stored,
+    primary key (pod_uid, container_name, timestamp)
+);
+
+create view container as
+select pod.uid                                                               as pod_uid,
+       pod.cluster_id                                                        as cluster_id,
+       c ->> 'name'                                                          as container_name,
+       coalesce(parse_cores(c -> 'resources' -> 'requests' ->> 'cpu'), 0)    as request_cpu_cores,
+       coalesce(parse_bytes(c -> 'resources' -> 'requests' ->> 'memory'), 0) as request_memory_bytes
+from object pod,
+     jsonb_array_elements(pod.data -> 'spec' -> 'containers') c
+where pod.kind = 'Pod';
+
+create view container_usage_request_hourly as
+select container_usage_hourly.timestamp,
+       pod.uid,
+       pod.cluster_id,
+       pod.namespace,
+       pod.name,
+       pod.node_name,
+       container_usage_hourly.container_name,
+       coalesce(container.request_cpu_cores, 0)    as request_cpu_cores,
+       coalesce(container.request_memory_bytes, 0) as request_memory_bytes,
+       -- volumes are claimed by the pod, storage is not attributed to individual containers
+       0                                           as request_storage_bytes,
+       pod.labels,
+       pod.annotations,
+       object_controller.controller_uid,
+       object_controller.controller_kind           as controller_kind,
+       object_controller.controller_name,
+       container_usage_hourly.cpu_cores_avg,
+       container_usage_hourly.memory_bytes_avg,
+       extract(epoch from (least(container_usage_hourly.timestamp + interval '1 hour', pod.deleted_at, now()) -
+                           greatest(container_usage_hourly.timestamp, pod.start_time)) / 3600) as hours
+from container_usage_hourly
+         inner join pod on (container_usage_hourly.pod_uid = pod.uid)
+         left join container on (container.pod_uid = container_usage_hourly.pod_uid and
+                                 container.container_name = container_usage_hourly.container_name)
+         left join object_controller on (container_usage_hourly.pod_uid = object_controller.uid);
+
+create view cost_container_hourly as
+select *,
+       greatest(request_cpu_cores, cpu_cores_avg) *
+       (select coalesce(price_cpu_core_hour, default_price_cpu_core_hour) from config) * hours       as cpu_cost,
+       greatest(request_memory_bytes, memory_bytes_avg) *
+       (select coalesce(price_memory_byte_hour, default_price_memory_byte_hour) from config) * hours as memory_cost,
+       0                                                                                             as storage_cost
+from container_usage_request_hourly;
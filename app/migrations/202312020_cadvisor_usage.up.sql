@@ -0,0 +1,143 @@
+create table pod_cadvisor_hourly
+(
+    cluster_id                    smallint                 not null,
+    pod_uid                       uuid                     not null,
+    timestamp                     timestamp with time zone not null,
+    network_receive_bytes         double precision         not null default 0,
+    network_transmit_bytes        double precision         not null default 0,
+    cpu_cfs_periods               double precision         not null default 0,
+    cpu_cfs_throttled_periods     double precision         not null default 0,
+    fs_usage_bytes_max            double precision         not null default 0,
+    fs_usage_bytes_min            double precision         not null default 0,
+    fs_usage_bytes_total          double precision         not null default 0,
+    fs_usage_bytes_total_readings int                      not null default 0,
+    fs_usage_bytes_avg            double precision         not null generated always as (case
+                                                                                             when fs_usage_bytes_total_readings = 0
+                                                                                                 then 0
+                                                                                             else fs_usage_bytes_total / fs_usage_bytes_total_readings end
+        ) stored,
+    primary key (pod_uid, timestamp)
+);
+
+drop view cost_hourly;
+drop view cost_pod_hourly;
+drop view cost_node_idle_hourly;
+drop view cost_node_system_hourly;
+drop view pod_usage_request_hourly;
+
+create view pod_usage_request_hourly as
+select pod_usage_hourly.timestamp,
+       pod.uid,
+       pod.cluster_id,
+       pod.namespace,
+       pod.name,
+       pod.node_name,
+       pod.request_cpu_cores,
+       pod.request_memory_bytes,
+       pod.request_storage_bytes,
+       pod.labels,
+       pod.annotations,
+       object_controller.controller_uid,
+       object_controller.controller_kind                                 as controller_kind,
+       object_controller.controller_name,
+       cpu_cores_avg,
+       memory_bytes_avg,
+       extract(epoch from (least(pod_usage_hourly.timestamp + interval '1 hour', pod.deleted_at, now()) -
+                           greatest(pod_usage_hourly.timestamp, pod.start_time)) / 3600) as hours,
+       coalesce(pod_cadvisor_hourly.network_receive_bytes, 0)            as network_receive_bytes,
+       coalesce(pod_cadvisor_hourly.network_transmit_bytes, 0)           as network_transmit_bytes,
+       coalesce(pod_cadvisor_hourly.cpu_cfs_periods, 0)                  as cpu_cfs_periods,
+       coalesce(pod_cadvisor_hourly.cpu_cfs_throttled_periods, 0)        as cpu_cfs_throttled_periods,
+       coalesce(pod_cadvisor_hourly.fs_usage_bytes_avg, 0)               as fs_usage_bytes_avg
+from pod_usage_hourly
+         inner join pod on (pod_usage_hourly.pod_uid = pod.uid)
+         left join pod_cadvisor_hourly on (pod_cadvisor_hourly.pod_uid = pod_usage_hourly.pod_uid and
+                                           pod_cadvisor_hourly.timestamp = pod_usage_hourly.timestamp)
+         left join object_controller on (pod_usage_hourly.pod_uid = object_controller.uid);
+
+create view cost_node_idle_hourly as
+select node.timestamp                                                                          as timestamp,
+       node.uid                                                                                as uid,
+       node.cluster_id                                                                         as cluster_id,
+       '_idle'                                                                                 as namespace,
+       '_idle'                                                                                 as name,
+       node.name                                                                               as node_name,
+       allocatable_cpu_cores - coalesce(node_usage_hourly.request_cpu_cores, 0)                as request_cpu_cores,
+       allocatable_memory_bytes - coalesce(node_usage_hourly.request_memory_bytes, 0)          as request_memory_bytes,
+       0                                                                                       as request_storage_bytes,
+       node.labels                                                                             as labels,
+       node.annotations                                                                        as annotations,
+       null::uuid                                                                              as controller_uid,
+       '_idle'                                                                                 as controller_kind,
+       '_idle'                                                                                 as controller_name,
+       capacity_cpu_cores - coalesce(node_usage_hourly.cpu_cores, 0)                           as cpu_cores_avg,
+       capacity_memory_bytes - coalesce(node_usage_hourly.memory_bytes, 0)                     as memory_bytes_avg,
+       node.hours                                                                              as hours,
+       0                                                                                       as network_receive_bytes,
+       0                                                                                       as network_transmit_bytes,
+       0                                                                                       as cpu_cfs_periods,
+       0                                                                                       as cpu_cfs_throttled_periods,
+       0                                                                                       as fs_usage_bytes_avg,
+       (allocatable_cpu_cores - greatest(node_usage_hourly.request_cpu_cores, node_usage_hourly.cpu_cores, 0)) * ( select coalesce(price_cpu_core_hour, default_price_cpu_core_hour) from config ) as cpu_cost,
+       (allocatable_memory_bytes - greatest(node_usage_hourly.request_memory_bytes, allocatable_memory_bytes, 0)) * ( select coalesce(price_memory_byte_hour, default_price_memory_byte_hour) from config ) as memory_cost,
+       0                                                                                       as storage_cost
+from node_hourly node
+         left join ( select node_name,
+                            timestamp,
+                            sum(request_cpu_cores * hours)                    as request_cpu_cores,
+                            sum(request_memory_bytes * hours)                 as request_memory_bytes,
+                            sum(cpu_cores_avg * hours)                        as cpu_cores,
+                            sum(memory_bytes_avg * hours)                     as memory_bytes
+                     from pod_usage_request_hourly
+                     group by node_name, timestamp ) node_usage_hourly
+                   on (node_usage_hourly.node_name = node.name and node_usage_hourly.timestamp = node.timestamp);
+
+create view cost_node_system_hourly as
+select timestamp,
+       uid                                                                                     as uid,
+       cluster_id                                                                              as cluster_id,
+       '_system'                                                                               as namespace,
+       '_system'                                                                               as name,
+       name                                                                                    as node_name,
+       capacity_cpu_cores - allocatable_cpu_cores                                              as request_cpu_cores,
+       capacity_memory_bytes - allocatable_memory_bytes                                        as request_memory_bytes,
+       0                                                                                       as request_storage_bytes,
+       labels                                                                                  as labels,
+       annotations                                                                             as annotations,
+       null::uuid                                                                              as controller_uid,
+       '_system'                                                                               as controller_kind,
+       '_system'                                                                               as controller_name,
+       0                                                                                       as cpu_cores_avg,
+       0                                                                                       as memory_bytes_avg,
+       hours                                                                                   as hours,
+       0                                                                                       as network_receive_bytes,
+       0                                                                                       as network_transmit_bytes,
+       0                                                                                       as cpu_cfs_periods,
+       0                                                                                       as cpu_cfs_throttled_periods,
+       0                                                                                       as fs_usage_bytes_avg,
+       hours * (capacity_cpu_cores - allocatable_cpu_cores) *
+        ( select coalesce(price_cpu_core_hour, default_price_cpu_core_hour) from config )      as cpu_cost,
+       hours * (capacity_memory_bytes - allocatable_memory_bytes) *
+       ( select coalesce(price_memory_byte_hour, default_price_memory_byte_hour) from config ) as memory_cost,
+       0                                                                                       as storage_cost
+from node_hourly;
+
+create view cost_pod_hourly as
+select *,
+       greatest(request_cpu_cores, cpu_cores_avg) *
+       (select coalesce(price_cpu_core_hour, default_price_cpu_core_hour) from config) * hours       as cpu_cost,
+       greatest(request_memory_bytes, memory_bytes_avg) *
+       (select coalesce(price_memory_byte_hour, default_price_memory_byte_hour) from config) * hours as memory_cost,
+       request_storage_bytes * (select coalesce(price_storage_byte_hour, default_price_storage_byte_hour) from config) *
+       hours                                                                                         as storage_cost
+from pod_usage_request_hourly;
+
+create view cost_hourly as
+select *
+from cost_pod_hourly
+union all
+select *
+from cost_node_idle_hourly
+union all
+select *
+from cost_node_system_hourly;
@@ -0,0 +1,53 @@
+-- individual readings, kept for a limited time and rolled up into pod_usage_hourly
+create table pod_usage_raw
+(
+    cluster_id   smallint                 not null,
+    pod_uid      uuid                     not null,
+    timestamp    timestamp with time zone not null,
+    cpu_cores    double precision,
+    memory_bytes double precision,
+    -- samples are added to pod_usage_hourly once
+    rolled_up    boolean                  not null default false
+);
+
+create index pod_usage_raw_timestamp_idx on pod_usage_raw (timestamp);
+create index pod_usage_raw_not_rolled_up_idx on pod_usage_raw (cluster_id) where not rolled_up;
+create index pod_usage_raw_pod_uid_timestamp_idx on pod_usage_raw (pod_uid, timestamp);
+
+create view pod_usage_request_minutely as
+select pod_usage_minutely.timestamp,
+       pod.uid,
+       pod.cluster_id,
+       pod.namespace,
+       pod.name,
+       pod.node_name,
+       pod.request_cpu_cores,
+       pod.request_memory_bytes,
+       pod.request_storage_bytes,
+       pod.labels,
+       pod.annotations,
+       object_controller.controller_uid,
+       object_controller.controller_kind                                   as controller_kind,
+       object_controller.controller_name,
+       pod_usage_minutely.cpu_cores_avg,
+       pod_usage_minutely.memory_bytes_avg,
+       extract(epoch from (least(pod_usage_minutely.timestamp + interval '1 minute', pod.deleted_at, now()) -
+                           greatest(pod_usage_minutely.timestamp, pod.start_time)) / 3600) as hours
+from ( select pod_uid,
+              date_trunc('minute', timestamp) as timestamp,
+              coalesce(avg(cpu_cores), 0)     as cpu_cores_avg,
+              coalesce(avg(memory_bytes), 0)  as memory_bytes_avg
+       from pod_usage_raw
+       group by pod_uid, date_trunc('minute', timestamp) ) pod_usage_minutely
+         inner join pod on (pod_usage_minutely.pod_uid = pod.uid)
+         left join object_controller on (pod_usage_minutely.pod_uid = object_controller.uid);
+
+create view cost_pod_minutely as
+select *,
+       greatest(request_cpu_cores, cpu_cores_avg) *
+       (select coalesce(price_cpu_core_hour, default_price_cpu_core_hour) from config) * hours       as cpu_cost,
+       greatest(request_memory_bytes, memory_bytes_avg) *
+       (select coalesce(price_memory_byte_hour, default_price_memory_byte_hour) from config) * hours as memory_cost,
+       request_storage_bytes * (select coalesce(price_storage_byte_hour, default_price_storage_byte_hour) from config) *
+       hours                                                                                         as storage_cost
+from pod_usage_request_minutely;
@@ -0,0 +1,90 @@
+-- pod usage older than the hourly retention is rolled up into days
+create table pod_usage_daily
+(
+    cluster_id                  smallint                 not null,
+    pod_uid                     uuid                     not null,
+    timestamp                   timestamp with time zone not null,
+    memory_bytes_max            double precision         not null default 0,
+    memory_bytes_min            double precision         not null default 0,
+    memory_bytes_total          double precision         not null default 0,
+    memory_bytes_total_readings int                      not null default 0,
+    memory_bytes_avg            double precision         not null generated always as (case
+                                                                                           when memory_bytes_total_readings = 0
+                                                                                               then 0
+                                                                                           else memory_bytes_total / memory_bytes_total_readings end
+        ) stored,
+    cpu_cores_max               double precision         not null default 0,
+    cpu_cores_min               double precision         not null default 0,
+    cpu_cores_total             double precision         not null default 0,
+    cpu_cores_total_readings    int                      not null default 0,
+    cpu_cores_avg               double precision         not null generated always as (case
+                                                                                           when cpu_cores_total_readings = 0
+                                                                                               then 0
+                                                                                           else cpu_cores_total / cpu_cores_total_readings end) stored,
+    -- hours the pod was running during the day, computed before the hourly rows are deleted
+    hours                       double precision         not null default 0,
+    primary key (pod_uid, timestamp)
+);
+
+-- days which are not rolled up yet are aggregated from pod_usage_hourly
+create view pod_usage_request_daily as
+select pod_usage_daily.timestamp,
+       pod.uid,
+       pod.cluster_id,
+       pod.namespace,
+       pod.name,
+       pod.node_name,
+       pod.request_cpu_cores,
+       pod.request_memory_bytes,
+       pod.request_storage_bytes,
+       pod.labels,
+       pod.annotations,
+       object_controller.controller_uid,
+       object_controller.controller_kind as controller_kind,
+       object_controller.controller_name,
+       pod_usage_daily.cpu_cores_avg,
+       pod_usage_daily.memory_bytes_avg,
+       pod_usage_daily.hours
+from ( select pod_uid, timestamp, cpu_cores_avg, memory_bytes_avg, hours
+       from pod_usage_daily
+       union all
+       select pod_uid,
+              date_trunc('day', timestamp)                                                     as timestamp,
+              coalesce(sum(cpu_cores_total) / nullif(sum(cpu_cores_total_readings), 0), 0)       as cpu_cores_avg,
+              coalesce(sum(memory_bytes_total) / nullif(sum(memory_bytes_total_readings), 0), 0) as memory_bytes_avg,
+              sum(hours)                                                                       as hours
+       from ( select pod_usage_hourly.*,
+                     extract(epoch from (least(pod_usage_hourly.timestamp + interval '1 hour', pod.deleted_at, now()) -
+                                         greatest(pod_usage_hourly.timestamp, pod.start_time)) / 3600) as hours
+              from pod_usage_hourly
+                       inner join pod on (pod_usage_hourly.pod_uid = pod.uid) ) hourly
+       group by pod_uid, date_trunc('day', timestamp) ) pod_usage_daily
+         inner join pod on (pod_usage_daily.pod_uid = pod.uid)
+         left join object_controller on (pod_usage_daily.pod_uid = object_controller.uid);
+
+create view cost_pod_daily as
+select *,
+       greatest(request_cpu_cores, cpu_cores_avg) *
+       (select coalesce(price_cpu_core_hour, default_price_cpu_core_hour) from config) * hours       as cpu_cost,
+       greatest(request_memory_bytes, memory_bytes_avg) *
+       (select coalesce(price_memory_byte_hour, default_price_memory_byte_hour) from config) * hours as memory_cost,
+       request_storage_bytes * (select coalesce(price_storage_byte_hour, default_price_storage_byte_hour) from config) *
+       hours                                                                                         as storage_cost
+from pod_usage_request_daily;
+
+-- start of the hours kept by the retention job, set when older hourly usage is deleted
+alter table cluster
+    add column hourly_retained_from timestamp with time zone;
+
+-- node hours before the retention start have no pod usage left, they would be reported as fully idle
+create or replace view node_hourly as
+select gs.timestamp                                                                   as timestamp,
+       node.*,
+       extract(epoch from (least(gs.timestamp + interval '1 hour', node.deleted_at, now()) -
+                            greatest(gs.timestamp, node.creation_timestamp))) / 3600 as hours
+from node,
+     generate_series(date_trunc('hour', ( select min(creation_timestamp) from node )), date_trunc('hour', now()),
+                     '1 hour'::interval) gs(timestamp)
+where (node.deleted_at is null or gs.timestamp < node.deleted_at)
+  and gs.timestamp >= coalesce(( select hourly_retained_from from cluster where cluster.id = node.cluster_id ),
+                               '-infinity');
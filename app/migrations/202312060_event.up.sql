@@ -0,0 +1,84 @@
+-- kubernetes events, repeated events update count and last_timestamp of the same row
+create table event
+(
+    cluster_id      smallint                 not null,
+    uid             uuid primary key,
+    namespace       text                     not null,
+    name            text                     not null,
+    -- involved object uid isn't always a uuid, e.g. node events use the node name
+    involved_kind   text                     not null,
+    involved_uid    text                     not null,
+    involved_name   text                     not null,
+    type            text                     not null,
+    reason          text                     not null,
+    message         text                     not null,
+    count           int                      not null default 1,
+    first_timestamp timestamp with time zone not null,
+    last_timestamp  timestamp with time zone not null
+);
+
+create index event_last_timestamp_idx on event (last_timestamp);
+create index event_involved_uid_idx on event (involved_uid);
+
+-- all occurrences of an event are attributed to the hour it was last seen
+-- node OOMKilling events only name the killed process, they aren't attributed to pods
+create view pod_event_hourly as
+select pod.uid                                                           as pod_uid,
+       date_trunc('hour', event.last_timestamp)                          as timestamp,
+       coalesce(sum(count) filter (where reason = 'OOMKilled'), 0)        as oom_kills,
+       coalesce(sum(count) filter (where reason = 'Evicted'), 0)          as evictions,
+       coalesce(sum(count) filter (where reason = 'FailedScheduling'), 0) as failed_scheduling
+from event
+         inner join pod on (pod.uid::text = event.involved_uid)
+where event.involved_kind = 'Pod'
+group by pod.uid, date_trunc('hour', event.last_timestamp);
+
+-- event rows don't contribute to usage or cost, they make events visible even for hours without usage (e.g. pending pods)
+create view cost_pod_event_hourly as
+select pod_event_hourly.timestamp,
+       pod.uid,
+       pod.cluster_id,
+       pod.namespace,
+       pod.name,
+       pod.node_name,
+       pod.request_cpu_cores,
+       pod.request_memory_bytes,
+       pod.request_storage_bytes,
+       pod.labels,
+       pod.annotations,
+       object_controller.controller_uid,
+       object_controller.controller_kind as controller_kind,
+       object_controller.controller_name,
+       0                                 as cpu_cores_avg,
+       0                                 as memory_bytes_avg,
+       0                                 as hours,
+       0                                 as network_receive_bytes,
+       0                                 as network_transmit_bytes,
+       0                                 as cpu_cfs_periods,
+       0                                 as cpu_cfs_throttled_periods,
+       0                                 as fs_usage_bytes_avg,
+       0                                 as used_storage_bytes,
+       0                                 as cpu_cost,
+       0                                 as memory_cost,
+       0                                 as storage_cost,
+       pod_event_hourly.oom_kills,
+       pod_event_hourly.evictions,
+       pod_event_hourly.failed_scheduling
+from pod_event_hourly
+         inner join pod on (pod_event_hourly.pod_uid = pod.uid)
+         left join object_controller on (pod_event_hourly.pod_uid = object_controller.uid);
+
+drop view cost_hourly;
+
+create view cost_hourly as
+select *, 0 as oom_kills, 0 as evictions, 0 as failed_scheduling
+from cost_pod_hourly
+union all
+select *, 0 as oom_kills, 0 as evictions, 0 as failed_scheduling
+from cost_node_idle_hourly
+union all
+select *, 0 as oom_kills, 0 as evictions, 0 as failed_scheduling
+from cost_node_system_hourly
+union all
+select *
+from cost_pod_event_hourly;
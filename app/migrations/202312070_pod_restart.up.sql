@@ -0,0 +1,83 @@
+-- last seen restart count and termination of every container, used to compute restart deltas
+create table pod_container_state
+(
+    cluster_id     smallint                 not null,
+    pod_uid        uuid                     not null,
+    container_name text                     not null,
+    restart_count  int                      not null default 0,
+    terminated_at  timestamp with time zone,
+    updated_at     timestamp with time zone not null default now(),
+    primary key (pod_uid, container_name)
+);
+
+create table pod_restart_hourly
+(
+    cluster_id     smallint                 not null,
+    pod_uid        uuid                     not null,
+    container_name text                     not null,
+    timestamp      timestamp with time zone not null,
+    restarts       int                      not null default 0,
+    -- terminations by reason
+    oom_killed     int                      not null default 0,
+    errors         int                      not null default 0,
+    completed      int                      not null default 0,
+    primary key (pod_uid, container_name, timestamp)
+);
+
+drop view cost_hourly;
+drop view cost_pod_event_hourly;
+
+-- event and restart rows don't contribute to usage or cost, they make them visible even for hours without usage
+create view cost_pod_activity_hourly as
+select pod_activity_hourly.timestamp,
+       pod.uid,
+       pod.cluster_id,
+       pod.namespace,
+       pod.name,
+       pod.node_name,
+       pod.request_cpu_cores,
+       pod.request_memory_bytes,
+       pod.request_storage_bytes,
+       pod.labels,
+       pod.annotations,
+       object_controller.controller_uid,
+       object_controller.controller_kind as controller_kind,
+       object_controller.controller_name,
+       0                                 as cpu_cores_avg,
+       0                                 as memory_bytes_avg,
+       0                                 as hours,
+       0                                 as network_receive_bytes,
+       0                                 as network_transmit_bytes,
+       0                                 as cpu_cfs_periods,
+       0                                 as cpu_cfs_throttled_periods,
+       0                                 as fs_usage_bytes_avg,
+       0                                 as used_storage_bytes,
+       0                                 as cpu_cost,
+       0                                 as memory_cost,
+       0                                 as storage_cost,
+       pod_activity_hourly.oom_kills,
+       pod_activity_hourly.evictions,
+       pod_activity_hourly.failed_scheduling,
+       pod_activity_hourly.restarts,
+       pod_activity_hourly.oom_killed
+from ( select pod_uid, timestamp, oom_kills, evictions, failed_scheduling, 0 as restarts, 0 as oom_killed
+       from pod_event_hourly
+       union all
+       select pod_uid, timestamp, 0, 0, 0, sum(restarts), sum(oom_killed)
+       from pod_restart_hourly
+       group by pod_uid, timestamp ) pod_activity_hourly
+         inner join pod on (pod_activity_hourly.pod_uid = pod.uid)
+         left join object_controller on (pod_activity_hourly.pod_uid = object_controller.uid);
+
+create view cost_hourly as
+select *, 0 as oom_kills, 0 as evictions, 0 as failed_scheduling, 0 as restarts, 0 as oom_killed
+from cost_pod_hourly
+union all
+select *, 0 as oom_kills, 0 as evictions, 0 as failed_scheduling, 0 as restarts, 0 as oom_killed
+from cost_node_idle_hourly
+union all
+select *, 0 as oom_kills, 0 as evictions, 0 as failed_scheduling, 0 as restarts, 0 as oom_killed
+from cost_node_system_hourly
+union all
+select *
+from cost_pod_activity_hourly;
@@ -0,0 +1,11 @@
+-- a namespace can be deleted and created again with the same name, the current one is preferred
+create view namespace as
+select distinct on (cluster_id, name) uid,
+                                      cluster_id,
+                                      name,
+                                      data -> 'metadata' -> 'labels'      as labels,
+                                      data -> 'metadata' -> 'annotations' as annotations,
+                                      deleted_at
+from object
+where kind = 'Namespace'
+order by cluster_id, name, deleted_at desc nulls first;
@@ -0,0 +1,28 @@
+-- fields tracked by object_revision: labels, replicas and container resources of the pod template
+create function object_revision_spec(data jsonb) returns jsonb as
+$$
+select jsonb_strip_nulls(jsonb_build_object(
+        'labels', data -> 'metadata' -> 'labels',
+        'replicas', data -> 'spec' -> 'replicas',
+        'resources', (select jsonb_object_agg(container ->> 'name', coalesce(container -> 'resources', '{}'))
+                      from jsonb_array_elements(coalesce(template -> 'initContainers', '[]') ||
+                                                coalesce(template -> 'containers', '[]')) container)))
+from (select coalesce(data -> 'spec' -> 'jobTemplate' -> 'spec' -> 'template' -> 'spec',
+                      data -> 'spec' -> 'template' -> 'spec') as template) pod_template
+$$ language sql immutable;
+
+-- a new revision is recorded whenever tracked fields change, the latest revision has no valid_to
+create table object_revision
+(
+    cluster_id smallint                 not null,
+    uid        uuid                     not null,
+    kind       text                     not null,
+    namespace  text                     not null,
+    name       text                     not null,
+    valid_from timestamp with time zone not null,
+    valid_to   timestamp with time zone,
+    spec       jsonb                    not null,
+    primary key (uid, valid_from)
+);
+
+create index object_revision_name_idx on object_revision (cluster_id, namespace, kind, name);
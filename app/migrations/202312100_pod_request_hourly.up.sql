@@ -0,0 +1,135 @@
+-- requests observed at scrape time, they change during the pod lifetime with in-place pod resize
+-- hours without readings (e.g. before the table existed) fall back to the current pod spec
+create table pod_request_hourly
+(
+    cluster_id                  smallint                 not null,
+    pod_uid                     uuid                     not null,
+    timestamp                   timestamp with time zone not null,
+    cpu_cores_total             double precision         not null default 0,
+    memory_bytes_total          double precision         not null default 0,
+    readings                    int                      not null default 0,
+    cpu_cores_avg               double precision         not null generated always as (case
+                                                                                           when readings = 0 then 0
+                                                                                           else cpu_cores_total / readings end) stored,
+    memory_bytes_avg            double precision         not null generated always as (case
+                                                                                           when readings = 0 then 0
+                                                                                           else memory_bytes_total / readings end) stored,
+    primary key (pod_uid, timestamp)
+);
+
+-- request hours of the day, null for days rolled up before requests were recorded
+alter table pod_usage_daily
+    add column request_cpu_core_hours    double precision,
+    add column request_memory_byte_hours double precision;
+
+create or replace view pod_usage_request_hourly as
+select pod_usage_hourly.timestamp,
+       pod.uid,
+       pod.cluster_id,
+       pod.namespace,
+       pod.name,
+       pod.node_name,
+       coalesce(pod_request_hourly.cpu_cores_avg::numeric, pod.request_cpu_cores)       as request_cpu_cores,
+       coalesce(pod_request_hourly.memory_bytes_avg::numeric, pod.request_memory_bytes) as request_memory_bytes,
+       pod.request_storage_bytes,
+       pod.labels,
+       pod.annotations,
+       object_controller.controller_uid,
+       object_controller.controller_kind                                 as controller_kind,
+       object_controller.controller_name,
+       pod_usage_hourly.cpu_cores_avg,
+       pod_usage_hourly.memory_bytes_avg,
+       extract(epoch from (least(pod_usage_hourly.timestamp + interval '1 hour', pod.deleted_at, now()) -
+                           greatest(pod_usage_hourly.timestamp, pod.start_time)) / 3600) as hours,
+       coalesce(pod_cadvisor_hourly.network_receive_bytes, 0)            as network_receive_bytes,
+       coalesce(pod_cadvisor_hourly.network_transmit_bytes, 0)           as network_transmit_bytes,
+       coalesce(pod_cadvisor_hourly.cpu_cfs_periods, 0)                  as cpu_cfs_periods,
+       coalesce(pod_cadvisor_hourly.cpu_cfs_throttled_periods, 0)        as cpu_cfs_throttled_periods,
+       coalesce(pod_cadvisor_hourly.fs_usage_bytes_avg, 0)               as fs_usage_bytes_avg,
+       coalesce(( select sum(pvc_usage_hourly.used_bytes_avg)
+                  from pvc_usage_hourly
+                           inner join object pvc on (pvc.uid = pvc_usage_hourly.pvc_uid)
+                  where pvc_usage_hourly.timestamp = pod_usage_hourly.timestamp
+                    and pvc.namespace = pod.namespace
+                    and pvc.name in ( select jsonb_path_query(pod.data, '$.spec.volumes[*].persistentVolumeClaim.claimName') #>> '{}' ) ),
+                0)                                                       as used_storage_bytes
+from pod_usage_hourly
+         inner join pod on (pod_usage_hourly.pod_uid = pod.uid)
+         left join pod_cadvisor_hourly on (pod_cadvisor_hourly.pod_uid = pod_usage_hourly.pod_uid and
+                                           pod_cadvisor_hourly.timestamp = pod_usage_hourly.timestamp)
+         left join pod_request_hourly on (pod_request_hourly.pod_uid = pod_usage_hourly.pod_uid and
+                                          pod_request_hourly.timestamp = pod_usage_hourly.timestamp)
+         left join object_controller on (pod_usage_hourly.pod_uid = object_controller.uid);
+
+create or replace view pod_usage_request_minutely as
+select pod_usage_minutely.timestamp,
+       pod.uid,
+       pod.cluster_id,
+       pod.namespace,
+       pod.name,
+       pod.node_name,
+       coalesce(pod_request_hourly.cpu_cores_avg::numeric, pod.request_cpu_cores)       as request_cpu_cores,
+       coalesce(pod_request_hourly.memory_bytes_avg::numeric, pod.request_memory_bytes) as request_memory_bytes,
+       pod.request_storage_bytes,
+       pod.labels,
+       pod.annotations,
+       object_controller.controller_uid,
+       object_controller.controller_kind                                   as controller_kind,
+       object_controller.controller_name,
+       pod_usage_minutely.cpu_cores_avg,
+       pod_usage_minutely.memory_bytes_avg,
+       extract(epoch from (least(pod_usage_minutely.timestamp + interval '1 minute', pod.deleted_at, now()) -
+                           greatest(pod_usage_minutely.timestamp, pod.start_time)) / 3600) as hours
+from ( select pod_uid,
+              date_trunc('minute', timestamp) as timestamp,
+              coalesce(avg(cpu_cores), 0)     as cpu_cores_avg,
+              coalesce(avg(memory_bytes), 0)  as memory_bytes_avg
+       from pod_usage_raw
+       group by pod_uid, date_trunc('minute', timestamp) ) pod_usage_minutely
+         inner join pod on (pod_usage_minutely.pod_uid = pod.uid)
+         left join pod_request_hourly on (pod_request_hourly.pod_uid = pod_usage_minutely.pod_uid and
+                                          pod_request_hourly.timestamp = date_trunc('hour', pod_usage_minutely.timestamp))
+         left join object_controller on (pod_usage_minutely.pod_uid = object_controller.uid);
+
+create or replace view pod_usage_request_daily as
+select pod_usage_daily.timestamp,
+       pod.uid,
+       pod.cluster_id,
+       pod.namespace,
+       pod.name,
+       pod.node_name,
+       coalesce((pod_usage_daily.request_cpu_core_hours / nullif(pod_usage_daily.hours, 0))::numeric,
+                pod.request_cpu_cores)    as request_cpu_cores,
+       coalesce((pod_usage_daily.request_memory_byte_hours / nullif(pod_usage_daily.hours, 0))::numeric,
+                pod.request_memory_bytes) as request_memory_bytes,
+       pod.request_storage_bytes,
+       pod.labels,
+       pod.annotations,
+       object_controller.controller_uid,
+       object_controller.controller_kind as controller_kind,
+       object_controller.controller_name,
+       pod_usage_daily.cpu_cores_avg,
+       pod_usage_daily.memory_bytes_avg,
+       pod_usage_daily.hours
+from ( select pod_uid, timestamp, cpu_cores_avg, memory_bytes_avg, hours, request_cpu_core_hours, request_memory_byte_hours
+       from pod_usage_daily
+       union all
+       select pod_uid,
+              date_trunc('day', timestamp)                                                     as timestamp,
+              coalesce(sum(cpu_cores_total) / nullif(sum(cpu_cores_total_readings), 0), 0)       as cpu_cores_avg,
+              coalesce(sum(memory_bytes_total) / nullif(sum(memory_bytes_total_readings), 0), 0) as memory_bytes_avg,
+              sum(hours)                                                                       as hours,
+              sum(request_cpu_cores * hours)                                                   as request_cpu_core_hours,
+              sum(request_memory_bytes * hours)                                                as request_memory_byte_hours
+       from ( select pod_usage_hourly.*,
+                     extract(epoch from (least(pod_usage_hourly.timestamp + interval '1 hour', pod.deleted_at, now()) -
+                                         greatest(pod_usage_hourly.timestamp, pod.start_time)) / 3600) as hours,
+                     coalesce(pod_request_hourly.cpu_cores_avg, pod.request_cpu_cores)                 as request_cpu_cores,
+                     coalesce(pod_request_hourly.memory_bytes_avg, pod.request_memory_bytes)           as request_memory_bytes
+              from pod_usage_hourly
+                       inner join pod on (pod_usage_hourly.pod_uid = pod.uid)
+                       left join pod_request_hourly on (pod_request_hourly.pod_uid = pod_usage_hourly.pod_uid and
+                                                        pod_request_hourly.timestamp = pod_usage_hourly.timestamp) ) hourly
+       group by pod_uid, date_trunc('day', timestamp) ) pod_usage_daily
+         inner join pod on (pod_usage_daily.pod_uid = pod.uid)
+         left join object_controller on (pod_usage_daily.pod_uid = object_controller.uid);
@@ -0,0 +1,41 @@
+-- effective pod requests computed by the scraper with scheduler rules (init containers, sidecars, overhead)
+alter table object
+    add column request_cpu_cores    double precision,
+    add column request_memory_bytes double precision;
+
+-- pods stored before keep the sum of container requests until they are updated
+update object
+set request_cpu_cores    = coalesce(( select sum(parse_cores(value #>> '{}'))
+                                      from jsonb_path_query(data, '$.spec.containers[*].resources.requests.cpu') as value ), 0),
+    request_memory_bytes = coalesce(( select sum(parse_bytes(value #>> '{}'))
+                                      from jsonb_path_query(data, '$.spec.containers[*].resources.requests.memory') as value ), 0)
+where kind = 'Pod';
+
+-- columns are listed explicitly, the view keeps its columns when object gets new ones
+-- pods are always written with requests, the ones stored before are backfilled above
+create or replace view pod as
+select p.cluster_id,
+       p.uid,
+       p.kind,
+       p.namespace,
+       p.name,
+       p.data,
+       p.deleted_at,
+       (data -> 'status' ->> 'starttime')::timestamp as start_time,
+       data -> 'spec' ->> 'nodeName'                 as node_name,
+       data -> 'metadata' -> 'labels'                as labels,
+       data -> 'metadata' -> 'annotations'           as annotations,
+       coalesce(p.request_cpu_cores::numeric, 0)     as request_cpu_cores,
+       coalesce(p.request_memory_bytes::numeric, 0)  as request_memory_bytes,
+       coalesce(( select sum(parse_bytes(pvc.data -> 'spec' -> 'resources' -> 'requests' ->> 'storage'))
+                  from object as pvc
+                  where pvc.kind = 'PersistentVolumeClaim'
+                    and pvc.name in
+                        ( select replace(jsonb_path_query(p.data, '$.spec.volumes[*].persistentVolumeClaim.claimName')::text,
+                                         '"', '')
+                          from object
+                          where uid = p.uid
+                            and kind = 'Pod' )
+                    and pvc.namespace = p.data -> 'metadata' ->> 'namespace' ), 0) as request_storage_bytes
+from object p
+where kind = 'Pod';
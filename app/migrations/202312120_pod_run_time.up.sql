@@ -0,0 +1,187 @@
+-- the pod holds node resources from scheduling until its containers exit or it's deleted
+alter table object
+    add column scheduled_at timestamp with time zone,
+    add column finished_at  timestamp with time zone;
+
+update object
+set scheduled_at = coalesce(( select min((c ->> 'lastTransitionTime')::timestamptz)
+                              from jsonb_array_elements(data -> 'status' -> 'conditions') c
+                              where c ->> 'type' = 'PodScheduled'
+                                and c ->> 'status' = 'True' ),
+                            (data -> 'status' ->> 'startTime')::timestamptz),
+    finished_at  = case
+                       when data -> 'status' ->> 'phase' in ('Succeeded', 'Failed') then
+                           ( select max((s -> 'state' -> 'terminated' ->> 'finishedAt')::timestamptz)
+                             from jsonb_array_elements(data -> 'status' -> 'containerStatuses') s ) end
+where kind = 'Pod';
+
+-- hours of the period the pod was running, unknown run_start or run_end (null) don't limit the period
+create function pod_run_hours(period_start timestamp with time zone, period interval,
+                              run_start timestamp with time zone, run_end timestamp with time zone) returns numeric as
+$$
+select greatest(extract(epoch from (least(period_start + period, run_end, now()) - greatest(period_start, run_start))) / 3600, 0)
+$$ language sql stable;
+
+create or replace view pod as
+select p.cluster_id,
+       p.uid,
+       p.kind,
+       p.namespace,
+       p.name,
+       p.data,
+       p.deleted_at,
+       (data -> 'status' ->> 'starttime')::timestamp as start_time,
+       data -> 'spec' ->> 'nodeName'                 as node_name,
+       data -> 'metadata' -> 'labels'                as labels,
+       data -> 'metadata' -> 'annotations'           as annotations,
+       coalesce(p.request_cpu_cores::numeric, 0)     as request_cpu_cores,
+       coalesce(p.request_memory_bytes::numeric, 0)  as request_memory_bytes,
+       coalesce(( select sum(parse_bytes(pvc.data -> 'spec' -> 'resources' -> 'requests' ->> 'storage'))
+                  from object as pvc
+                  where pvc.kind = 'PersistentVolumeClaim'
+                    and pvc.name in
+                        ( select replace(jsonb_path_query(p.data, '$.spec.volumes[*].persistentVolumeClaim.claimName')::text,
+                                         '"', '')
+                          from object
+                          where uid = p.uid
+                            and kind = 'Pod' )
+                    and pvc.namespace = p.data -> 'metadata' ->> 'namespace' ), 0) as request_storage_bytes,
+       coalesce(p.scheduled_at, (data -> 'status' ->> 'startTime')::timestamptz) as run_start,
+       least(p.finished_at, p.deleted_at)                                      as run_end
+from object p
+where kind = 'Pod';
+
+create or replace view pod_usage_request_hourly as
+select pod_usage_hourly.timestamp,
+       pod.uid,
+       pod.cluster_id,
+       pod.namespace,
+       pod.name,
+       pod.node_name,
+       coalesce(pod_request_hourly.cpu_cores_avg::numeric, pod.request_cpu_cores)       as request_cpu_cores,
+       coalesce(pod_request_hourly.memory_bytes_avg::numeric, pod.request_memory_bytes) as request_memory_bytes,
+       pod.request_storage_bytes,
+       pod.labels,
+       pod.annotations,
+       object_controller.controller_uid,
+       object_controller.controller_kind                                                 as controller_kind,
+       object_controller.controller_name,
+       pod_usage_hourly.cpu_cores_avg,
+       pod_usage_hourly.memory_bytes_avg,
+       pod_run_hours(pod_usage_hourly.timestamp, interval '1 hour', pod.run_start, pod.run_end) as hours,
+       coalesce(pod_cadvisor_hourly.network_receive_bytes, 0)                            as network_receive_bytes,
+       coalesce(pod_cadvisor_hourly.network_transmit_bytes, 0)                           as network_transmit_bytes,
+       coalesce(pod_cadvisor_hourly.cpu_cfs_periods, 0)                                  as cpu_cfs_periods,
+       coalesce(pod_cadvisor_hourly.cpu_cfs_throttled_periods, 0)                        as cpu_cfs_throttled_periods,
+       coalesce(pod_cadvisor_hourly.fs_usage_bytes_avg, 0)                               as fs_usage_bytes_avg,
+       coalesce(( select sum(pvc_usage_hourly.used_bytes_avg)
+                  from pvc_usage_hourly
+                           inner join object pvc on (pvc.uid = pvc_usage_hourly.pvc_uid)
+                  where pvc_usage_hourly.timestamp = pod_usage_hourly.timestamp
+                    and pvc.namespace = pod.namespace
+                    and pvc.name in ( select jsonb_path_query(pod.data, '$.spec.volumes[*].persistentVolumeClaim.claimName') #>> '{}' ) ),
+                0)                                                                       as used_storage_bytes
+from pod_usage_hourly
+         inner join pod on (pod_usage_hourly.pod_uid = pod.uid)
+         left join pod_cadvisor_hourly on (pod_cadvisor_hourly.pod_uid = pod_usage_hourly.pod_uid and
+                                           pod_cadvisor_hourly.timestamp = pod_usage_hourly.timestamp)
+         left join pod_request_hourly on (pod_request_hourly.pod_uid = pod_usage_hourly.pod_uid and
+                                          pod_request_hourly.timestamp = pod_usage_hourly.timestamp)
+         left join object_controller on (pod_usage_hourly.pod_uid = object_controller.uid);
+
+create or replace view pod_usage_request_minutely as
+select pod_usage_minutely.timestamp,
+       pod.uid,
+       pod.cluster_id,
+       pod.namespace,
+       pod.name,
+       pod.node_name,
+       coalesce(pod_request_hourly.cpu_cores_avg::numeric, pod.request_cpu_cores)       as request_cpu_cores,
+       coalesce(pod_request_hourly.memory_bytes_avg::numeric, pod.request_memory_bytes) as request_memory_bytes,
+       pod.request_storage_bytes,
+       pod.labels,
+       pod.annotations,
+       object_controller.controller_uid,
+       object_controller.controller_kind                                                     as controller_kind,
+       object_controller.controller_name,
+       pod_usage_minutely.cpu_cores_avg,
+       pod_usage_minutely.memory_bytes_avg,
+       pod_run_hours(pod_usage_minutely.timestamp, interval '1 minute', pod.run_start, pod.run_end) as hours
+from ( select pod_uid,
+              date_trunc('minute', timestamp) as timestamp,
+              coalesce(avg(cpu_cores), 0)     as cpu_cores_avg,
+              coalesce(avg(memory_bytes), 0)  as memory_bytes_avg
+       from pod_usage_raw
+       group by pod_uid, date_trunc('minute', timestamp) ) pod_usage_minutely
+         inner join pod on (pod_usage_minutely.pod_uid = pod.uid)
+         left join pod_request_hourly on (pod_request_hourly.pod_uid = pod_usage_minutely.pod_uid and
+                                          pod_request_hourly.timestamp = date_trunc('hour', pod_usage_minutely.timestamp))
+         left join object_controller on (pod_usage_minutely.pod_uid = object_controller.uid);
+
+create or replace view pod_usage_request_daily as
+select pod_usage_daily.timestamp,
+       pod.uid,
+       pod.cluster_id,
+       pod.namespace,
+       pod.name,
+       pod.node_name,
+       coalesce((pod_usage_daily.request_cpu_core_hours / nullif(pod_usage_daily.hours, 0))::numeric,
+                pod.request_cpu_cores)    as request_cpu_cores,
+       coalesce((pod_usage_daily.request_memory_byte_hours / nullif(pod_usage_daily.hours, 0))::numeric,
+                pod.request_memory_bytes) as request_memory_bytes,
+       pod.request_storage_bytes,
+       pod.labels,
+       pod.annotations,
+       object_controller.controller_uid,
+       object_controller.controller_kind as controller_kind,
+       object_controller.controller_name,
+       pod_usage_daily.cpu_cores_avg,
+       pod_usage_daily.memory_bytes_avg,
+       pod_usage_daily.hours
+from ( select pod_uid, timestamp, cpu_cores_avg, memory_bytes_avg, hours, request_cpu_core_hours, request_memory_byte_hours
+       from pod_usage_daily
+       union all
+       select pod_uid,
+              date_trunc('day', timestamp)                                                     as timestamp,
+              coalesce(sum(cpu_cores_total) / nullif(sum(cpu_cores_total_readings), 0), 0)       as cpu_cores_avg,
+              coalesce(sum(memory_bytes_total) / nullif(sum(memory_bytes_total_readings), 0), 0) as memory_bytes_avg,
+              sum(hours)                                                                       as hours,
+              sum(request_cpu_cores * hours)                                                   as request_cpu_core_hours,
+              sum(request_memory_bytes * hours)                                                as request_memory_byte_hours
+       from ( select pod_usage_hourly.*,
+                     pod_run_hours(pod_usage_hourly.timestamp, interval '1 hour', pod.run_start, pod.run_end) as hours,
+                     coalesce(pod_request_hourly.cpu_cores_avg, pod.request_cpu_cores)                     as request_cpu_cores,
+                     coalesce(pod_request_hourly.memory_bytes_avg, pod.request_memory_bytes)               as request_memory_bytes
+              from pod_usage_hourly
+                       inner join pod on (pod_usage_hourly.pod_uid = pod.uid)
+                       left join pod_request_hourly on (pod_request_hourly.pod_uid = pod_usage_hourly.pod_uid and
+                                                        pod_request_hourly.timestamp = pod_usage_hourly.timestamp) ) hourly
+       group by pod_uid, date_trunc('day', timestamp) ) pod_usage_daily
+         inner join pod on (pod_usage_daily.pod_uid = pod.uid)
+         left join object_controller on (pod_usage_daily.pod_uid = object_controller.uid);
+
+create or replace view container_usage_request_hourly as
+select container_usage_hourly.timestamp,
+       pod.uid,
+       pod.cluster_id,
+       pod.namespace,
+       pod.name,
+       pod.node_name,
+       container_usage_hourly.container_name,
+       coalesce(container.request_cpu_cores, 0)    as request_cpu_cores,
+       coalesce(container.request_memory_bytes, 0) as request_memory_bytes,
+       -- volumes are claimed by the pod, storage is not attributed to individual containers
+       0                                           as request_storage_bytes,
+       pod.labels,
+       pod.annotations,
+       object_controller.controller_uid,
+       object_controller.controller_kind           as controller_kind,
+       object_controller.controller_name,
+       container_usage_hourly.cpu_cores_avg,
+       container_usage_hourly.memory_bytes_avg,
+       pod_run_hours(container_usage_hourly.timestamp, interval '1 hour', pod.run_start, pod.run_end) as hours
+from container_usage_hourly
+         inner join pod on (container_usage_hourly.pod_uid = pod.uid)
+         left join container on (container.pod_uid = container_usage_hourly.pod_uid and
+                                 container.container_name = container_usage_hourly.container_name)
+         left join object_controller on (container_usage_hourly.pod_uid = object_controller.uid);
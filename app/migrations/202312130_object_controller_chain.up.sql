@@ -0,0 +1,154 @@
+-- the controller is the top owner of the whole ownerReferences chain, e.g. Rollout -> ReplicaSet -> Pod
+-- the chain stops at owners which aren't stored, the direct owner is the first link
+-- chains are kept up to date by a trigger on object, views and workload queries look them up by uid
+create table object_controller_chain
+(
+    uid               uuid primary key,
+    controller_kind   text,
+    controller_name   text,
+    controller_uid    uuid,
+    direct_owner_kind text,
+    direct_owner_name text,
+    direct_owner_uid  uuid
+);
+
+create index object_controller_chain_direct_owner_uid_idx on object_controller_chain (direct_owner_uid);
+
+-- objects owned directly or indirectly by the object, union stops at ownership cycles
+create function object_descendants(owner_uid uuid) returns table (uid uuid) as
+$$
+with recursive descendant as ( select chain.uid
+                               from object_controller_chain chain
+                               where chain.direct_owner_uid = owner_uid
+                               union
+                               select chain.uid
+                               from object_controller_chain chain
+                                        inner join descendant on (chain.direct_owner_uid = descendant.uid) )
+select descendant.uid
+from descendant
+where descendant.uid != owner_uid
+$$ language sql stable;
+
+-- the chain of an object starts at its controller owner and continues with the chain of that owner when it is stored
+-- objects owned by it share its controller, they are updated when it changes
+create function update_object_controller_chain() returns trigger as
+$$
+declare
+    owner_ref       jsonb;
+    owner_chain     object_controller_chain%rowtype;
+    previous_chain  object_controller_chain%rowtype;
+    top_kind        text;
+    top_name        text;
+    top_uid         uuid;
+begin
+    if tg_op = 'DELETE' then
+        delete from object_controller_chain where uid = old.uid;
+        -- the chain of owned objects stops at the removed object
+        update object_controller_chain
+        set controller_kind = old.kind,
+            controller_name = old.name,
+            controller_uid  = old.uid
+        where uid in ( select uid from object_descendants(old.uid) );
+        return old;
+    end if;
+
+    select * into previous_chain from object_controller_chain where uid = new.uid;
+    select ref
+    into owner_ref
+    from jsonb_array_elements(coalesce(new.data -> 'metadata' -> 'ownerReferences', '[]')) as ref
+    where ref ->> 'controller' = 'true'
+    limit 1;
+
+    if owner_ref is null then
+        delete from object_controller_chain where uid = new.uid;
+        top_kind := new.kind;
+        top_name := new.name;
+        top_uid := new.uid;
+    else
+        select * into owner_chain from object_controller_chain where uid = (owner_ref ->> 'uid')::uuid;
+        top_kind := coalesce(owner_chain.controller_kind, owner_ref ->> 'kind');
+        top_name := coalesce(owner_chain.controller_name, owner_ref ->> 'name');
+        top_uid := coalesce(owner_chain.controller_uid, (owner_ref ->> 'uid')::uuid);
+        insert into object_controller_chain (uid, controller_kind, controller_name, controller_uid,
+                                             direct_owner_kind, direct_owner_name, direct_owner_uid)
+        values (new.uid, top_kind, top_name, top_uid,
+                owner_ref ->> 'kind', owner_ref ->> 'name', (owner_ref ->> 'uid')::uuid)
+        on conflict (uid)
+            do update set controller_kind   = excluded.controller_kind,
+                          controller_name   = excluded.controller_name,
+                          controller_uid    = excluded.controller_uid,
+                          direct_owner_kind = excluded.direct_owner_kind,
+                          direct_owner_name = excluded.direct_owner_name,
+                          direct_owner_uid  = excluded.direct_owner_uid;
+    end if;
+
+    -- owned objects saw the object itself as their controller while it had no chain
+    if top_uid is not distinct from coalesce(previous_chain.controller_uid, new.uid) then
+        return new;
+    end if;
+    update object_controller_chain
+    set controller_kind = top_kind,
+        controller_name = top_name,
+        controller_uid  = top_uid
+    where uid in ( select uid from object_descendants(new.uid) );
+    return new;
+end;
+$$ language plpgsql;
+
+create trigger object_controller_chain_trigger
+    after insert or update of data or delete
+    on object
+    for each row
+execute function update_object_controller_chain();
+
+-- objects stored before get their chains once
+insert into object_controller_chain (uid, controller_kind, controller_name, controller_uid,
+                                     direct_owner_kind, direct_owner_name, direct_owner_uid)
+with recursive owner as ( select uid,
+                                 owner_ref ->> 'kind'        as owner_kind,
+                                 owner_ref ->> 'name'        as owner_name,
+                                 (owner_ref ->> 'uid')::uuid as owner_uid
+                          from ( select uid, jsonb_array_elements(data -> 'metadata' -> 'ownerReferences') as owner_ref
+                                 from object ) owners
+                          where owner_ref ->> 'controller' = 'true' ),
+               chain as ( select uid,
+                                 owner_kind as direct_owner_kind,
+                                 owner_name as direct_owner_name,
+                                 owner_uid  as direct_owner_uid,
+                                 owner_kind as controller_kind,
+                                 owner_name as controller_name,
+                                 owner_uid  as controller_uid,
+                                 1          as depth
+                          from owner
+                          union all
+                          select chain.uid,
+                                 chain.direct_owner_kind,
+                                 chain.direct_owner_name,
+                                 chain.direct_owner_uid,
+                                 owner.owner_kind,
+                                 owner.owner_name,
+                                 owner.owner_uid,
+                                 chain.depth + 1
+                          from chain
+                                   inner join owner on (owner.uid = chain.controller_uid)
+                          -- ownership cycles are invalid, the depth limit keeps them from looping forever
+                          where chain.depth < 16 )
+select distinct on (uid) uid,
+                         controller_kind,
+                         controller_name,
+                         controller_uid,
+                         direct_owner_kind,
+                         direct_owner_name,
+                         direct_owner_uid
+from chain
+order by uid, depth desc;
+
+create or replace view object_controller as
+select uid,
+       controller_kind,
+       controller_name,
+       controller_uid,
+       direct_owner_kind,
+       direct_owner_name,
+       direct_owner_uid
+from object_controller_chain;
@@ -0,0 +1,464 @@
+-- ephemeral storage used by pods (container writable layers, logs and emptyDir volumes), reported by the kubelet stats summary
+create table pod_ephemeral_storage_hourly
+(
+    cluster_id                smallint                 not null,
+    pod_uid                   uuid                     not null,
+    timestamp                 timestamp with time zone not null,
+    used_bytes_max            double precision         not null default 0,
+    used_bytes_min            double precision         not null default 0,
+    used_bytes_total          double precision         not null default 0,
+    used_bytes_total_readings int                      not null default 0,
+    used_bytes_avg            double precision         not null generated always as (case
+                                                                                         when used_bytes_total_readings = 0
+                                                                                             then 0
+                                                                                         else used_bytes_total / used_bytes_total_readings end
+        ) stored,
+    primary key (pod_uid, timestamp)
+);
+
+-- ephemeral storage usage of days rolled up from hourly rows
+alter table pod_usage_daily
+    add column used_ephemeral_byte_hours double precision not null default 0;
+
+-- effective ephemeral-storage request computed by the scraper with scheduler rules
+alter table object
+    add column request_ephemeral_bytes double precision;
+
+-- pods stored before keep the sum of container requests until they are updated
+update object
+set request_ephemeral_bytes = coalesce(( select sum(parse_bytes(value #>> '{}'))
+                                         from jsonb_path_query(data, '$.spec.containers[*].resources.requests."ephemeral-storage"') as value ), 0)
+where kind = 'Pod';
+
+alter table config
+    add column default_price_ephemeral_byte_hour double precision not null default 0,
+    add column price_ephemeral_byte_hour         double precision null;
+
+update config
+set default_price_ephemeral_byte_hour = 0.10 / 30 / 24 / (2 ^ 30);
+-- ephemeral storage price is balanced persistent disk (the default boot disk) https://cloud.google.com/compute/disks-image-pricing#disk
+
+create or replace view pod as
+select p.cluster_id,
+       p.uid,
+       p.kind,
+       p.namespace,
+       p.name,
+       p.data,
+       p.deleted_at,
+       (data -> 'status' ->> 'starttime')::timestamp as start_time,
+       data -> 'spec' ->> 'nodeName'                 as node_name,
+       data -> 'metadata' -> 'labels'                as labels,
+       data -> 'metadata' -> 'annotations'           as annotations,
+       coalesce(p.request_cpu_cores::numeric, 0)     as request_cpu_cores,
+       coalesce(p.request_memory_bytes::numeric, 0)  as request_memory_bytes,
+       coalesce(( select sum(parse_bytes(pvc.data -> 'spec' -> 'resources' -> 'requests' ->> 'storage'))
+                  from object as pvc
+                  where pvc.kind = 'PersistentVolumeClaim'
+                    and pvc.name in
+                        ( select replace(jsonb_path_query(p.data, '$.spec.volumes[*].persistentVolumeClaim.claimName')::text,
+                                         '"', '')
+                          from object
+                          where uid = p.uid
+                            and kind = 'Pod' )
+                    and pvc.namespace = p.data -> 'metadata' ->> 'namespace' ), 0) as request_storage_bytes,
+       coalesce(p.scheduled_at, (data -> 'status' ->> 'startTime')::timestamptz) as run_start,
+       least(p.finished_at, p.deleted_at)                                      as run_end,
+       coalesce(( select jsonb_object_agg(extended_resource.resource, extended_resource.request)
+                  from extended_resource
+                  where extended_resource.uid = p.uid
+                    and extended_resource.request > 0 ), '{}')               as extended_resources,
+       coalesce(p.request_ephemeral_bytes::numeric, 0)                       as request_ephemeral_bytes
+from object p
+where kind = 'Pod';
+
+create or replace view container as
+select pod.uid                                                               as pod_uid,
+       pod.cluster_id                                                        as cluster_id,
+       c ->> 'name'                                                          as container_name,
+       coalesce(parse_cores(c -> 'resources' -> 'requests' ->> 'cpu'), 0)    as request_cpu_cores,
+       coalesce(parse_bytes(c -> 'resources' -> 'requests' ->> 'memory'), 0) as request_memory_bytes,
+       coalesce(( select jsonb_object_agg(request.key, parse_cores(request.value))
+                  from jsonb_each_text(c -> 'resources' -> 'requests') request
+                  where is_extended_resource(request.key) ), '{}')          as extended_resources,
+       coalesce(parse_bytes(c -> 'resources' -> 'requests' ->> 'ephemeral-storage'), 0) as request_ephemeral_bytes
+from object pod,
+     jsonb_array_elements(pod.data -> 'spec' -> 'containers') c
+where pod.kind = 'Pod';
+
+create or replace view pod_usage_request_hourly as
+select pod_usage_hourly.timestamp,
+       pod.uid,
+       pod.cluster_id,
+       pod.namespace,
+       pod.name,
+       pod.node_name,
+       coalesce(pod_request_hourly.cpu_cores_avg::numeric, pod.request_cpu_cores)       as request_cpu_cores,
+       coalesce(pod_request_hourly.memory_bytes_avg::numeric, pod.request_memory_bytes) as request_memory_bytes,
+       pod.request_storage_bytes,
+       pod.labels,
+       pod.annotations,
+       object_controller.controller_uid,
+       object_controller.controller_kind                                                 as controller_kind,
+       object_controller.controller_name,
+       pod_usage_hourly.cpu_cores_avg,
+       pod_usage_hourly.memory_bytes_avg,
+       pod_run_hours(pod_usage_hourly.timestamp, interval '1 hour', pod.run_start, pod.run_end) as hours,
+       coalesce(pod_cadvisor_hourly.network_receive_bytes, 0)                            as network_receive_bytes,
+       coalesce(pod_cadvisor_hourly.network_transmit_bytes, 0)                           as network_transmit_bytes,
+       coalesce(pod_cadvisor_hourly.cpu_cfs_periods, 0)                                  as cpu_cfs_periods,
+       coalesce(pod_cadvisor_hourly.cpu_cfs_throttled_periods, 0)                        as cpu_cfs_throttled_periods,
+       coalesce(pod_cadvisor_hourly.fs_usage_bytes_avg, 0)                               as fs_usage_bytes_avg,
+       coalesce(( select sum(pvc_usage_hourly.used_bytes_avg)
+                  from pvc_usage_hourly
+                           inner join object pvc on (pvc.uid = pvc_usage_hourly.pvc_uid)
+                  where pvc_usage_hourly.timestamp = pod_usage_hourly.timestamp
+                    and pvc.namespace = pod.namespace
+                    and pvc.name in ( select jsonb_path_query(pod.data, '$.spec.volumes[*].persistentVolumeClaim.claimName') #>> '{}' ) ),
+                0)                                                                       as used_storage_bytes,
+       pod.extended_resources,
+       pod.request_ephemeral_bytes,
+       coalesce(pod_ephemeral_storage_hourly.used_bytes_avg, 0)                          as used_ephemeral_bytes
+from pod_usage_hourly
+         inner join pod on (pod_usage_hourly.pod_uid = pod.uid)
+         left join pod_cadvisor_hourly on (pod_cadvisor_hourly.pod_uid = pod_usage_hourly.pod_uid and
+                                           pod_cadvisor_hourly.timestamp = pod_usage_hourly.timestamp)
+         left join pod_request_hourly on (pod_request_hourly.pod_uid = pod_usage_hourly.pod_uid and
+                                          pod_request_hourly.timestamp = pod_usage_hourly.timestamp)
+         left join pod_ephemeral_storage_hourly on (pod_ephemeral_storage_hourly.pod_uid = pod_usage_hourly.pod_uid and
+                                                    pod_ephemeral_storage_hourly.timestamp = pod_usage_hourly.timestamp)
+         left join object_controller on (pod_usage_hourly.pod_uid = object_controller.uid);
+
+create or replace view pod_usage_request_minutely as
+select pod_usage_minutely.timestamp,
+       pod.uid,
+       pod.cluster_id,
+       pod.namespace,
+       pod.name,
+       pod.node_name,
+       coalesce(pod_request_hourly.cpu_cores_avg::numeric, pod.request_cpu_cores)       as request_cpu_cores,
+       coalesce(pod_request_hourly.memory_bytes_avg::numeric, pod.request_memory_bytes) as request_memory_bytes,
+       pod.request_storage_bytes,
+       pod.labels,
+       pod.annotations,
+       object_controller.controller_uid,
+       object_controller.controller_kind                                                     as controller_kind,
+       object_controller.controller_name,
+       pod_usage_minutely.cpu_cores_avg,
+       pod_usage_minutely.memory_bytes_avg,
+       pod_run_hours(pod_usage_minutely.timestamp, interval '1 minute', pod.run_start, pod.run_end) as hours,
+       pod.extended_resources,
+       pod.request_ephemeral_bytes,
+       coalesce(pod_ephemeral_storage_hourly.used_bytes_avg, 0)                          as used_ephemeral_bytes
+from ( select pod_uid,
+              date_trunc('minute', timestamp) as timestamp,
+              coalesce(avg(cpu_cores), 0)     as cpu_cores_avg,
+              coalesce(avg(memory_bytes), 0)  as memory_bytes_avg
+       from pod_usage_raw
+       group by pod_uid, date_trunc('minute', timestamp) ) pod_usage_minutely
+         inner join pod on (pod_usage_minutely.pod_uid = pod.uid)
+         left join pod_request_hourly on (pod_request_hourly.pod_uid = pod_usage_minutely.pod_uid and
+                                          pod_request_hourly.timestamp = date_trunc('hour', pod_usage_minutely.timestamp))
+         left join pod_ephemeral_storage_hourly on (pod_ephemeral_storage_hourly.pod_uid = pod_usage_minutely.pod_uid and
+                                                    pod_ephemeral_storage_hourly.timestamp = date_trunc('hour', pod_usage_minutely.timestamp))
+         left join object_controller on (pod_usage_minutely.pod_uid = object_controller.uid);
+
+create or replace view pod_usage_request_daily as
+select pod_usage_daily.timestamp,
+       pod.uid,
+       pod.cluster_id,
+       pod.namespace,
+       pod.name,
+       pod.node_name,
+       coalesce((pod_usage_daily.request_cpu_core_hours / nullif(pod_usage_daily.hours, 0))::numeric,
+                pod.request_cpu_cores)    as request_cpu_cores,
+       coalesce((pod_usage_daily.request_memory_byte_hours / nullif(pod_usage_daily.hours, 0))::numeric,
+                pod.request_memory_bytes) as request_memory_bytes,
+       pod.request_storage_bytes,
+       pod.labels,
+       pod.annotations,
+       object_controller.controller_uid,
+       object_controller.controller_kind as controller_kind,
+       object_controller.controller_name,
+       pod_usage_daily.cpu_cores_avg,
+       pod_usage_daily.memory_bytes_avg,
+       pod_usage_daily.hours,
+       pod.extended_resources,
+       pod.request_ephemeral_bytes,
+       coalesce(pod_usage_daily.used_ephemeral_byte_hours / nullif(pod_usage_daily.hours, 0), 0) as used_ephemeral_bytes
+from ( select pod_uid, timestamp, cpu_cores_avg, memory_bytes_avg, hours, request_cpu_core_hours, request_memory_byte_hours,
+              used_ephemeral_byte_hours
+       from pod_usage_daily
+       union all
+       select pod_uid,
+              date_trunc('day', timestamp)                                                     as timestamp,
+              coalesce(sum(cpu_cores_total) / nullif(sum(cpu_cores_total_readings), 0), 0)       as cpu_cores_avg,
+              coalesce(sum(memory_bytes_total) / nullif(sum(memory_bytes_total_readings), 0), 0) as memory_bytes_avg,
+              sum(hours)                                                                       as hours,
+              sum(request_cpu_cores * hours)                                                   as request_cpu_core_hours,
+              sum(request_memory_bytes * hours)                                                as request_memory_byte_hours,
+              sum(used_ephemeral_bytes * hours)                                                as used_ephemeral_byte_hours
+       from ( select pod_usage_hourly.*,
+                     pod_run_hours(pod_usage_hourly.timestamp, interval '1 hour', pod.run_start, pod.run_end) as hours,
+                     coalesce(pod_request_hourly.cpu_cores_avg, pod.request_cpu_cores)                     as request_cpu_cores,
+                     coalesce(pod_request_hourly.memory_bytes_avg, pod.request_memory_bytes)               as request_memory_bytes,
+                     coalesce(pod_ephemeral_storage_hourly.used_bytes_avg, 0)                              as used_ephemeral_bytes
+              from pod_usage_hourly
+                       inner join pod on (pod_usage_hourly.pod_uid = pod.uid)
+                       left join pod_request_hourly on (pod_request_hourly.pod_uid = pod_usage_hourly.pod_uid and
+                                                        pod_request_hourly.timestamp = pod_usage_hourly.timestamp)
+                       left join pod_ephemeral_storage_hourly on (pod_ephemeral_storage_hourly.pod_uid = pod_usage_hourly.pod_uid and
+                                                                  pod_ephemeral_storage_hourly.timestamp = pod_usage_hourly.timestamp) ) hourly
+       group by pod_uid, date_trunc('day', timestamp) ) pod_usage_daily
+         inner join pod on (pod_usage_daily.pod_uid = pod.uid)
+         left join object_controller on (pod_usage_daily.pod_uid = object_controller.uid);
+
+create or replace view container_usage_request_hourly as
+select container_usage_hourly.timestamp,
+       pod.uid,
+       pod.cluster_id,
+       pod.namespace,
+       pod.name,
+       pod.node_name,
+       container_usage_hourly.container_name,
+       coalesce(container.request_cpu_cores, 0)    as request_cpu_cores,
+       coalesce(container.request_memory_bytes, 0) as request_memory_bytes,
+       -- volumes are claimed by the pod, storage is not attributed to individual containers
+       0                                           as request_storage_bytes,
+       pod.labels,
+       pod.annotations,
+       object_controller.controller_uid,
+       object_controller.controller_kind           as controller_kind,
+       object_controller.controller_name,
+       container_usage_hourly.cpu_cores_avg,
+       container_usage_hourly.memory_bytes_avg,
+       pod_run_hours(container_usage_hourly.timestamp, interval '1 hour', pod.run_start, pod.run_end) as hours,
+       coalesce(container.extended_resources, '{}') as extended_resources,
+       coalesce(container.request_ephemeral_bytes, 0) as request_ephemeral_bytes,
+       -- ephemeral storage usage is only collected per pod
+       0                                           as used_ephemeral_bytes
+from container_usage_hourly
+         inner join pod on (container_usage_hourly.pod_uid = pod.uid)
+         left join container on (container.pod_uid = container_usage_hourly.pod_uid and
+                                 container.container_name = container_usage_hourly.container_name)
+         left join object_controller on (container_usage_hourly.pod_uid = object_controller.uid);
+
+-- cost views get request_ephemeral_bytes and used_ephemeral_bytes before and ephemeral_cost after the cost columns
+-- usage above the request is priced at every granularity, container rows have no usage of their own
+drop view cost_hourly;
+drop view cost_pod_activity_hourly;
+drop view cost_pod_hourly;
+drop view cost_node_idle_hourly;
+drop view cost_node_system_hourly;
+drop view cost_pod_minutely;
+drop view cost_pod_daily;
+drop view cost_container_hourly;
+
+create view cost_pod_hourly as
+select *,
+       greatest(request_cpu_cores, cpu_cores_avg) *
+       (select coalesce(price_cpu_core_hour, default_price_cpu_core_hour) from config) * hours       as cpu_cost,
+       greatest(request_memory_bytes, memory_bytes_avg) *
+       (select coalesce(price_memory_byte_hour, default_price_memory_byte_hour) from config) * hours as memory_cost,
+       request_storage_bytes * (select coalesce(price_storage_byte_hour, default_price_storage_byte_hour) from config) *
+       hours                                                                                         as storage_cost,
+       extended_resource_cost(extended_resources) * hours                                            as extended_cost,
+       -- usage above the request fills the node disk as well
+       greatest(request_ephemeral_bytes, used_ephemeral_bytes) *
+       (select coalesce(price_ephemeral_byte_hour, default_price_ephemeral_byte_hour) from config) * hours     as ephemeral_cost
+from pod_usage_request_hourly;
+
+create view cost_pod_minutely as
+select *,
+       greatest(request_cpu_cores, cpu_cores_avg) *
+       (select coalesce(price_cpu_core_hour, default_price_cpu_core_hour) from config) * hours       as cpu_cost,
+       greatest(request_memory_bytes, memory_bytes_avg) *
+       (select coalesce(price_memory_byte_hour, default_price_memory_byte_hour) from config) * hours as memory_cost,
+       request_storage_bytes * (select coalesce(price_storage_byte_hour, default_price_storage_byte_hour) from config) *
+       hours                                                                                         as storage_cost,
+       extended_resource_cost(extended_resources) * hours                                            as extended_cost,
+       greatest(request_ephemeral_bytes, used_ephemeral_bytes) *
+       (select coalesce(price_ephemeral_byte_hour, default_price_ephemeral_byte_hour) from config) * hours     as ephemeral_cost
+from pod_usage_request_minutely;
+
+create view cost_pod_daily as
+select *,
+       greatest(request_cpu_cores, cpu_cores_avg) *
+       (select coalesce(price_cpu_core_hour, default_price_cpu_core_hour) from config) * hours       as cpu_cost,
+       greatest(request_memory_bytes, memory_bytes_avg) *
+       (select coalesce(price_memory_byte_hour, default_price_memory_byte_hour) from config) * hours as memory_cost,
+       request_storage_bytes * (select coalesce(price_storage_byte_hour, default_price_storage_byte_hour) from config) *
+       hours                                                                                         as storage_cost,
+       extended_resource_cost(extended_resources) * hours                                            as extended_cost,
+       greatest(request_ephemeral_bytes, used_ephemeral_bytes) *
+       (select coalesce(price_ephemeral_byte_hour, default_price_ephemeral_byte_hour) from config) * hours     as ephemeral_cost
+from pod_usage_request_daily;
+
+create view cost_container_hourly as
+select *,
+       greatest(request_cpu_cores, cpu_cores_avg) *
+       (select coalesce(price_cpu_core_hour, default_price_cpu_core_hour) from config) * hours       as cpu_cost,
+       greatest(request_memory_bytes, memory_bytes_avg) *
+       (select coalesce(price_memory_byte_hour, default_price_memory_byte_hour) from config) * hours as memory_cost,
+       0                                                                                             as storage_cost,
+       extended_resource_cost(extended_resources) * hours                                            as extended_cost,
+       greatest(request_ephemeral_bytes, used_ephemeral_bytes) *
+       (select coalesce(price_ephemeral_byte_hour, default_price_ephemeral_byte_hour) from config) * hours     as ephemeral_cost
+from container_usage_request_hourly;
+
+-- allocatable extended resources which aren't requested by any pod
+create view cost_node_idle_hourly as
+select node.timestamp                                                                          as timestamp,
+       node.uid                                                                                as uid,
+       node.cluster_id                                                                         as cluster_id,
+       '_idle'                                                                                 as namespace,
+       '_idle'                                                                                 as name,
+       node.name                                                                               as node_name,
+       allocatable_cpu_cores - coalesce(node_usage_hourly.request_cpu_cores, 0)                as request_cpu_cores,
+       allocatable_memory_bytes - coalesce(node_usage_hourly.request_memory_bytes, 0)          as request_memory_bytes,
+       0                                                                                       as request_storage_bytes,
+       node.labels                                                                             as labels,
+       node.annotations                                                                        as annotations,
+       null::uuid                                                                              as controller_uid,
+       '_idle'                                                                                 as controller_kind,
+       '_idle'                                                                                 as controller_name,
+       capacity_cpu_cores - coalesce(node_usage_hourly.cpu_cores, 0)                           as cpu_cores_avg,
+       capacity_memory_bytes - coalesce(node_usage_hourly.memory_bytes, 0)                     as memory_bytes_avg,
+       node.hours                                                                              as hours,
+       0                                                                                       as network_receive_bytes,
+       0                                                                                       as network_transmit_bytes,
+       0                                                                                       as cpu_cfs_periods,
+       0                                                                                       as cpu_cfs_throttled_periods,
+       0                                                                                       as fs_usage_bytes_avg,
+       0                                                                                       as used_storage_bytes,
+       node_extended.resources                                                                 as extended_resources,
+       0                                                                                       as request_ephemeral_bytes,
+       0                                                                                       as used_ephemeral_bytes,
+       (allocatable_cpu_cores - greatest(node_usage_hourly.request_cpu_cores, node_usage_hourly.cpu_cores, 0)) * ( select coalesce(price_cpu_core_hour, default_price_cpu_core_hour) from config ) as cpu_cost,
+       (allocatable_memory_bytes - greatest(node_usage_hourly.request_memory_bytes, allocatable_memory_bytes, 0)) * ( select coalesce(price_memory_byte_hour, default_price_memory_byte_hour) from config ) as memory_cost,
+       0                                                                                       as storage_cost,
+       extended_resource_cost(node_extended.resources) * node.hours                            as extended_cost,
+       0                                                                                       as ephemeral_cost
+from node_hourly node
+         left join ( select node_name,
+                            timestamp,
+                            sum(request_cpu_cores * hours)                    as request_cpu_cores,
+                            sum(request_memory_bytes * hours)                 as request_memory_bytes,
+                            sum(cpu_cores_avg * hours)                        as cpu_cores,
+                            sum(memory_bytes_avg * hours)                     as memory_bytes
+                     from pod_usage_request_hourly
+                     group by node_name, timestamp ) node_usage_hourly
+                   on (node_usage_hourly.node_name = node.name and node_usage_hourly.timestamp = node.timestamp)
+         cross join lateral ( select coalesce(jsonb_object_agg(extended_resource.resource,
+                                                               greatest(extended_resource.allocatable - coalesce(requested.request, 0), 0)),
+                                              '{}') as resources
+                              from extended_resource
+                                       left join node_extended_request_hourly requested
+                                                 on (requested.node_name = node.name and
+                                                     requested.timestamp = node.timestamp and
+                                                     requested.resource = extended_resource.resource)
+                              where extended_resource.uid = node.uid
+                                and extended_resource.allocatable > 0 ) node_extended;
+
+-- extended resources reserved by the node (capacity above allocatable)
+create view cost_node_system_hourly as
+select timestamp,
+       uid                                                                                     as uid,
+       cluster_id                                                                              as cluster_id,
+       '_system'                                                                               as namespace,
+       '_system'                                                                               as name,
+       name                                                                                    as node_name,
+       capacity_cpu_cores - allocatable_cpu_cores                                              as request_cpu_cores,
+       capacity_memory_bytes - allocatable_memory_bytes                                        as request_memory_bytes,
+       0                                                                                       as request_storage_bytes,
+       labels                                                                                  as labels,
+       annotations                                                                             as annotations,
+       null::uuid                                                                              as controller_uid,
+       '_system'                                                                               as controller_kind,
+       '_system'                                                                               as controller_name,
+       0                                                                                       as cpu_cores_avg,
+       0                                                                                       as memory_bytes_avg,
+       hours                                                                                   as hours,
+       0                                                                                       as network_receive_bytes,
+       0                                                                                       as network_transmit_bytes,
+       0                                                                                       as cpu_cfs_periods,
+       0                                                                                       as cpu_cfs_throttled_periods,
+       0                                                                                       as fs_usage_bytes_avg,
+       0                                                                                       as used_storage_bytes,
+       node_extended.resources                                                                 as extended_resources,
+       0                                                                                       as request_ephemeral_bytes,
+       0                                                                                       as used_ephemeral_bytes,
+       hours * (capacity_cpu_cores - allocatable_cpu_cores) *
+        ( select coalesce(price_cpu_core_hour, default_price_cpu_core_hour) from config )      as cpu_cost,
+       hours * (capacity_memory_bytes - allocatable_memory_bytes) *
+       ( select coalesce(price_memory_byte_hour, default_price_memory_byte_hour) from config ) as memory_cost,
+       0                                                                                       as storage_cost,
+       hours * extended_resource_cost(node_extended.resources)                                 as extended_cost,
+       0                                                                                       as ephemeral_cost
+from node_hourly
+         cross join lateral ( select coalesce(jsonb_object_agg(extended_resource.resource,
+                                                               extended_resource.capacity - extended_resource.allocatable),
+                                              '{}') as resources
+                              from extended_resource
+                              where extended_resource.uid = node_hourly.uid
+                                and extended_resource.capacity > extended_resource.allocatable ) node_extended;
+
+-- event and restart rows don't contribute to usage or cost, they make them visible even for hours without usage
+create view cost_pod_activity_hourly as
+select pod_activity_hourly.timestamp,
+       pod.uid,
+       pod.cluster_id,
+       pod.namespace,
+       pod.name,
+       pod.node_name,
+       pod.request_cpu_cores,
+       pod.request_memory_bytes,
+       pod.request_storage_bytes,
+       pod.labels,
+       pod.annotations,
+       object_controller.controller_uid,
+       object_controller.controller_kind as controller_kind,
+       object_controller.controller_name,
+       0                                 as cpu_cores_avg,
+       0                                 as memory_bytes_avg,
+       0                                 as hours,
+       0                                 as network_receive_bytes,
+       0                                 as network_transmit_bytes,
+       0                                 as cpu_cfs_periods,
+       0                                 as cpu_cfs_throttled_periods,
+       0                                 as fs_usage_bytes_avg,
+       0                                 as used_storage_bytes,
+       pod.extended_resources,
+       pod.request_ephemeral_bytes,
+       0                                 as used_ephemeral_bytes,
+       0                                 as cpu_cost,
+       0                                 as memory_cost,
+       0                                 as storage_cost,
+       0                                 as extended_cost,
+       0                                 as ephemeral_cost,
+       pod_activity_hourly.oom_kills,
+       pod_activity_hourly.evictions,
+       pod_activity_hourly.failed_scheduling,
+       pod_activity_hourly.restarts,
+       pod_activity_hourly.oom_killed
+from ( select pod_uid, timestamp, oom_kills, evictions, failed_scheduling, 0 as restarts, 0 as oom_killed
+       from pod_event_hourly
+       union all
+       select pod_uid, timestamp, 0, 0, 0, sum(restarts), sum(oom_killed)
+       from pod_restart_hourly
+       group by pod_uid, timestamp ) pod_activity_hourly
+         inner join pod on (pod_activity_hourly.pod_uid = pod.uid)
+         left join object_controller on (pod_activity_hourly.pod_uid = object_controller.uid);
+
+create view cost_hourly as
+select *, 0 as oom_kills, 0 as evictions, 0 as failed_scheduling, 0 as restarts, 0 as oom_killed
+from cost_pod_hourly
+union all
+select *, 0 as oom_kills, 0 as evictions, 0 as failed_scheduling, 0 as restarts, 0 as oom_killed
+from cost_node_idle_hourly
+union all
+select *, 0 as oom_kills, 0 as evictions, 0 as failed_scheduling, 0 as restarts, 0 as oom_killed
+from cost_node_system_hourly
+union all
+select *
+from cost_pod_activity_hourly;
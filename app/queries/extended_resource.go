@@ -0,0 +1,89 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ExtendedResource is a quantity of an extended resource, e.g. nvidia.com/gpu
+// pods set Request, nodes set Allocatable and Capacity
+type ExtendedResource struct {
+	Resource    string  `db:"resource"`
+	Request     float64 `db:"request"`
+	Allocatable float64 `db:"allocatable"`
+	Capacity    float64 `db:"capacity"`
+}
+
+// ReplaceExtendedResources stores extended resources of a pod or a node, stored resources missing from the list are removed
+func (q *Queries) ReplaceExtendedResources(ctx context.Context, uid string, resources []ExtendedResource) error {
+	// empty arrays, nil would be sent as null and nothing would be removed
+	arg := struct {
+		ClusterID   int       `db:"cluster_id"`
+		Uid         string    `db:"uid"`
+		Resources   []string  `db:"resources"`
+		Requests    []float64 `db:"requests"`
+		Allocatable []float64 `db:"allocatable"`
+		Capacity    []float64 `db:"capacity"`
+	}{
+		ClusterID:   q.clusterID,
+		Uid:         uid,
+		Resources:   make([]string, 0, len(resources)),
+		Requests:    make([]float64, 0, len(resources)),
+		Allocatable: make([]float64, 0, len(resources)),
+		Capacity:    make([]float64, 0, len(resources)),
+	}
+	for _, resource := range resources {
+		arg.Resources = append(arg.Resources, resource.Resource)
+		arg.Requests = append(arg.Requests, resource.Request)
+		arg.Allocatable = append(arg.Allocatable, resource.Allocatable)
+		arg.Capacity = append(arg.Capacity, resource.Capacity)
+	}
+	const replaceExtendedResources = `
+with deleted as ( delete from extended_resource where uid = @uid::uuid and resource <> all (@resources::text[]) )
+insert
+into extended_resource (cluster_id, uid, resource, request, allocatable, capacity)
+select @cluster_id::smallint, @uid::uuid, resource, request, allocatable, capacity
+from unnest(@resources::text[], @requests::float8[], @allocatable::float8[], @capacity::float8[])
+         as r(resource, request, allocatable, capacity)
+on conflict (uid, resource)
+    do update set cluster_id  = excluded.cluster_id,
+                  request     = excluded.request,
+                  allocatable = excluded.allocatable,
+                  capacity    = excluded.capacity
+`
+	_, err := q.execStruct(ctx, replaceExtendedResources, arg)
+	return err
+}
+
+func (q *Queries) ListExtendedResources(ctx context.Context, uid string) ([]ExtendedResource, error) {
+	const listExtendedResources = `select resource, request, allocatable, capacity
+from extended_resource
+where uid = $1
+order by resource
+`
+	rows, err := q.query(ctx, listExtendedResources, uid)
+	if err != nil {
+		return nil, err
+	}
+	data, err := pgx.CollectRows(rows, pgx.RowToStructByName[ExtendedResource])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect extended resource rows: %w", err)
+	}
+	return data, nil
+}
+
+// ExtendedResourceNames returns names of extended resources requested by pods or provided by nodes
+func (q *Queries) ExtendedResourceNames(ctx context.Context) ([]string, error) {
+	const extendedResourceNames = `select distinct resource from extended_resource order by resource`
+	rows, err := q.query(ctx, extendedResourceNames)
+	if err != nil {
+		return nil, err
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect extended resource names: %w", err)
+	}
+	return names, nil
+}
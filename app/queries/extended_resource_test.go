@@ -0,0 +1,36 @@
+package queries
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplaceExtendedResources(t *testing.T) {
+	queries := NewTestQueries(t)
+	ctx := context.TODO()
+	uid := string(NewKUUID())
+
+	require.NoError(t, queries.ReplaceExtendedResources(ctx, uid, []ExtendedResource{
+		{Resource: "example.com/fpga", Allocatable: 1, Capacity: 1},
+		{Resource: "nvidia.com/gpu", Allocatable: 3, Capacity: 4},
+	}))
+	// the device plugin stopped advertising fpga
+	require.NoError(t, queries.ReplaceExtendedResources(ctx, uid, []ExtendedResource{
+		{Resource: "nvidia.com/gpu", Allocatable: 4, Capacity: 4},
+	}))
+	resources, err := queries.ListExtendedResources(ctx, uid)
+	require.NoError(t, err)
+	assert.Equal(t, []ExtendedResource{{Resource: "nvidia.com/gpu", Allocatable: 4, Capacity: 4}}, resources)
+
+	names, err := queries.ExtendedResourceNames(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"nvidia.com/gpu"}, names)
+
+	require.NoError(t, queries.ReplaceExtendedResources(ctx, uid, nil))
+	resources, err = queries.ListExtendedResources(ctx, uid)
+	require.NoError(t, err)
+	assert.Empty(t, resources)
+}
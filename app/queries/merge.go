@@ -0,0 +1,239 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// stagingTable is a temporary table which collects readings with COPY, merge inserts them into an hourly table
+// rows of the staging table are deleted when the transaction commits
+type stagingTable struct {
+	name    string
+	create  string
+	columns []string
+	merge   string
+}
+
+// copyMerge copies rows into the staging table and merges them with a single statement in one transaction
+func (q *Queries) copyMerge(ctx context.Context, staging stagingTable, rows [][]any) error {
+	tx, err := q.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", WrapError(err))
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+	if _, err := tx.Exec(ctx, staging.create); err != nil {
+		return fmt.Errorf("creating %s: %w", staging.name, WrapError(err))
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging.name}, staging.columns, pgx.CopyFromRows(rows)); err != nil {
+		return fmt.Errorf("copying into %s: %w", staging.name, WrapError(err))
+	}
+	if _, err := tx.Exec(ctx, staging.merge); err != nil {
+		return fmt.Errorf("merging %s: %w", staging.name, WrapError(err))
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("committing transaction: %w", WrapError(err))
+	}
+	return nil
+}
+
+// aggregateColumns lists the max, min, total and readings columns of a measurement in an hourly table
+func aggregateColumns(column string) string {
+	return fmt.Sprintf("%[1]s_max, %[1]s_min, %[1]s_total, %[1]s_total_readings", column)
+}
+
+// stagedAggregate aggregates staged readings of a measurement, unset readings are skipped
+// zero readings don't lower the minimum, the same as in the per-row upserts
+func stagedAggregate(column string) string {
+	return fmt.Sprintf("coalesce(max(%[1]s), 0), coalesce(min(nullif(%[1]s, 0)), 0), coalesce(sum(%[1]s), 0), count(%[1]s)", column)
+}
+
+// mergeAggregate merges aggregated readings of a measurement with the existing row of an hourly table
+func mergeAggregate(table, column string) string {
+	return fmt.Sprintf(`
+                  %[2]s_total_readings = %[1]s.%[2]s_total_readings + excluded.%[2]s_total_readings,
+                  %[2]s_max = greatest(%[1]s.%[2]s_max, excluded.%[2]s_max),
+                  %[2]s_min = case
+                                  when excluded.%[2]s_min = 0
+                                      then %[1]s.%[2]s_min
+                                  when %[1]s.%[2]s_min < excluded.%[2]s_min and %[1]s.%[2]s_min != 0
+                                      then %[1]s.%[2]s_min
+                                  else excluded.%[2]s_min end,
+                  %[2]s_total = %[1]s.%[2]s_total + excluded.%[2]s_total`, table, column)
+}
+
+// ContainerUsageSample is a single reading of container usage, CPU or memory may be unset
+type ContainerUsageSample struct {
+	PodUid        pgtype.UUID
+	ContainerName string
+	Timestamp     pgtype.Timestamptz
+	CpuCores      pgtype.Float8
+	MemoryBytes   pgtype.Float8
+}
+
+var containerUsageStaging = stagingTable{
+	name: "container_usage_staging",
+	create: `
+create temporary table if not exists container_usage_staging
+(
+    pod_uid        uuid                     not null,
+    container_name text                     not null,
+    cluster_id     smallint                 not null,
+    timestamp      timestamp with time zone not null,
+    cpu_cores      double precision,
+    memory_bytes   double precision
+) on commit delete rows`,
+	columns: []string{"pod_uid", "container_name", "cluster_id", "timestamp", "cpu_cores", "memory_bytes"},
+	merge: `
+insert into container_usage_hourly (pod_uid, container_name, cluster_id, timestamp, ` + aggregateColumns("memory_bytes") + `,
+                                    ` + aggregateColumns("cpu_cores") + `)
+select pod_uid, container_name, cluster_id, timestamp, ` + stagedAggregate("memory_bytes") + `,
+       ` + stagedAggregate("cpu_cores") + `
+from container_usage_staging
+group by pod_uid, container_name, cluster_id, timestamp
+on conflict (pod_uid, container_name, timestamp)
+    do update set cluster_id = excluded.cluster_id,` +
+		mergeAggregate("container_usage_hourly", "memory_bytes") + "," +
+		mergeAggregate("container_usage_hourly", "cpu_cores"),
+}
+
+// MergeContainerUsageSamples merges samples into container_usage_hourly with a single upsert
+// the result is the same as calling UpsertContainerUsedCPU/UpsertContainerUsedMemory for every sample
+func (q *Queries) MergeContainerUsageSamples(ctx context.Context, samples []ContainerUsageSample) error {
+	rows := make([][]any, 0, len(samples))
+	for _, sample := range samples {
+		rows = append(rows, []any{sample.PodUid, sample.ContainerName, q.clusterID, sample.Timestamp, sample.CpuCores, sample.MemoryBytes})
+	}
+	return q.copyMerge(ctx, containerUsageStaging, rows)
+}
+
+var pvcUsageStaging = stagingTable{
+	name: "pvc_usage_staging",
+	create: `
+create temporary table if not exists pvc_usage_staging
+(
+    pvc_uid        uuid                     not null,
+    cluster_id     smallint                 not null,
+    timestamp      timestamp with time zone not null,
+    -- order of the readings, the capacity of the last one is kept
+    reading        int                      not null,
+    used_bytes     double precision         not null,
+    capacity_bytes double precision         not null
+) on commit delete rows`,
+	columns: []string{"pvc_uid", "cluster_id", "timestamp", "reading", "used_bytes", "capacity_bytes"},
+	merge: `
+insert into pvc_usage_hourly (pvc_uid, cluster_id, timestamp, ` + aggregateColumns("used_bytes") + `, capacity_bytes)
+select pvc_uid, cluster_id, timestamp, ` + stagedAggregate("used_bytes") + `,
+       (array_agg(capacity_bytes order by reading desc))[1]
+from pvc_usage_staging
+group by pvc_uid, cluster_id, timestamp
+on conflict (pvc_uid, timestamp)
+    do update set cluster_id = excluded.cluster_id,` +
+		mergeAggregate("pvc_usage_hourly", "used_bytes") + `,
+                  capacity_bytes = excluded.capacity_bytes`,
+}
+
+// MergePVCUsage merges readings into pvc_usage_hourly with a single upsert, the same as calling UpsertPVCUsage
+func (q *Queries) MergePVCUsage(ctx context.Context, arg []UpsertPVCUsageParams) error {
+	rows := make([][]any, 0, len(arg))
+	for i, item := range arg {
+		rows = append(rows, []any{item.PvcUid, q.clusterID, item.Timestamp, i, item.UsedBytes, item.CapacityBytes})
+	}
+	return q.copyMerge(ctx, pvcUsageStaging, rows)
+}
+
+var podEphemeralStorageStaging = stagingTable{
+	name: "pod_ephemeral_storage_staging",
+	create: `
+create temporary table if not exists pod_ephemeral_storage_staging
+(
+    pod_uid    uuid                     not null,
+    cluster_id smallint                 not null,
+    timestamp  timestamp with time zone not null,
+    used_bytes double precision         not null
+) on commit delete rows`,
+	columns: []string{"pod_uid", "cluster_id", "timestamp", "used_bytes"},
+	merge: `
+insert into pod_ephemeral_storage_hourly (pod_uid, cluster_id, timestamp, ` + aggregateColumns("used_bytes") + `)
+select pod_uid, cluster_id, timestamp, ` + stagedAggregate("used_bytes") + `
+from pod_ephemeral_storage_staging
+group by pod_uid, cluster_id, timestamp
+on conflict (pod_uid, timestamp)
+    do update set cluster_id = excluded.cluster_id,` +
+		mergeAggregate("pod_ephemeral_storage_hourly", "used_bytes"),
+}
+
+// MergePodEphemeralStorage merges readings into pod_ephemeral_storage_hourly with a single upsert, the same as calling
+// UpsertPodEphemeralStorage
+func (q *Queries) MergePodEphemeralStorage(ctx context.Context, arg []UpsertPodEphemeralStorageParams) error {
+	rows := make([][]any, 0, len(arg))
+	for _, item := range arg {
+		rows = append(rows, []any{item.PodUid, q.clusterID, item.Timestamp, item.UsedBytes})
+	}
+	return q.copyMerge(ctx, podEphemeralStorageStaging, rows)
+}
+
+// PodCadvisorSample is a single reading of cAdvisor metrics of a pod, metrics which aren't part of the reading are unset
+// network bytes and CFS periods are deltas since the previous scrape
+type PodCadvisorSample struct {
+	PodUid                 pgtype.UUID
+	Timestamp              pgtype.Timestamptz
+	NetworkReceiveBytes    pgtype.Float8
+	NetworkTransmitBytes   pgtype.Float8
+	CpuCfsPeriods          pgtype.Float8
+	CpuCfsThrottledPeriods pgtype.Float8
+	FsUsageBytes           pgtype.Float8
+}
+
+var podCadvisorStaging = stagingTable{
+	name: "pod_cadvisor_staging",
+	create: `
+create temporary table if not exists pod_cadvisor_staging
+(
+    pod_uid                   uuid                     not null,
+    cluster_id                smallint                 not null,
+    timestamp                 timestamp with time zone not null,
+    network_receive_bytes     double precision,
+    network_transmit_bytes    double precision,
+    cpu_cfs_periods           double precision,
+    cpu_cfs_throttled_periods double precision,
+    fs_usage_bytes            double precision
+) on commit delete rows`,
+	columns: []string{"pod_uid", "cluster_id", "timestamp", "network_receive_bytes", "network_transmit_bytes",
+		"cpu_cfs_periods", "cpu_cfs_throttled_periods", "fs_usage_bytes"},
+	merge: `
+insert into pod_cadvisor_hourly (pod_uid, cluster_id, timestamp, network_receive_bytes, network_transmit_bytes,
+                                 cpu_cfs_periods, cpu_cfs_throttled_periods, ` + aggregateColumns("fs_usage_bytes") + `)
+select pod_uid,
+       cluster_id,
+       timestamp,
+       coalesce(sum(network_receive_bytes), 0),
+       coalesce(sum(network_transmit_bytes), 0),
+       coalesce(sum(cpu_cfs_periods), 0),
+       coalesce(sum(cpu_cfs_throttled_periods), 0),
+       ` + stagedAggregate("fs_usage_bytes") + `
+from pod_cadvisor_staging
+group by pod_uid, cluster_id, timestamp
+on conflict (pod_uid, timestamp)
+    do update set cluster_id                = excluded.cluster_id,
+                  network_receive_bytes     = pod_cadvisor_hourly.network_receive_bytes + excluded.network_receive_bytes,
+                  network_transmit_bytes    = pod_cadvisor_hourly.network_transmit_bytes + excluded.network_transmit_bytes,
+                  cpu_cfs_periods           = pod_cadvisor_hourly.cpu_cfs_periods + excluded.cpu_cfs_periods,
+                  cpu_cfs_throttled_periods = pod_cadvisor_hourly.cpu_cfs_throttled_periods + excluded.cpu_cfs_throttled_periods,` +
+		mergeAggregate("pod_cadvisor_hourly", "fs_usage_bytes"),
+}
+
+// MergePodCadvisorSamples merges samples into pod_cadvisor_hourly with a single upsert
+// the result is the same as calling UpsertPodNetwork/UpsertPodCPUThrottling/UpsertPodFsUsage for every sample
+func (q *Queries) MergePodCadvisorSamples(ctx context.Context, samples []PodCadvisorSample) error {
+	rows := make([][]any, 0, len(samples))
+	for _, sample := range samples {
+		rows = append(rows, []any{sample.PodUid, q.clusterID, sample.Timestamp, sample.NetworkReceiveBytes,
+			sample.NetworkTransmitBytes, sample.CpuCfsPeriods, sample.CpuCfsThrottledPeriods, sample.FsUsageBytes})
+	}
+	return q.copyMerge(ctx, podCadvisorStaging, rows)
+}
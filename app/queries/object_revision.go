@@ -0,0 +1,153 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// the first revision starts when the object was created, following revisions when the change was observed
+const recordObjectRevision = `
+with latest as (select cluster_id,
+                       uid,
+                       kind,
+                       namespace,
+                       name,
+                       object_revision_spec(data)                                as spec,
+                       (data -> 'metadata' ->> 'creationTimestamp')::timestamptz as created_at
+                from object
+                where uid = $1),
+     previous as (select spec
+                  from object_revision
+                  where uid = $1
+                    and valid_to is null),
+     closed as (update object_revision
+         set valid_to = now()
+         from latest
+         where object_revision.uid = latest.uid
+           and object_revision.valid_to is null
+           and object_revision.spec <> latest.spec)
+insert
+into object_revision (cluster_id, uid, kind, namespace, name, valid_from, spec)
+select cluster_id,
+       uid,
+       kind,
+       namespace,
+       name,
+       case when exists (select 1 from previous) then now() else least(coalesce(created_at, now()), now()) end,
+       spec
+from latest
+where not exists (select 1 from previous where previous.spec = latest.spec)
+`
+
+func (q *Queries) recordObjectRevision(ctx context.Context, uid string) error {
+	_, err := q.db.Exec(ctx, recordObjectRevision, uid)
+	if err != nil {
+		return fmt.Errorf("recording object revision: %w", WrapError(err))
+	}
+	return nil
+}
+
+type ObjectRevision struct {
+	Uid       pgtype.UUID        `db:"uid" json:"uid"`
+	Kind      string             `db:"kind" json:"kind"`
+	Namespace string             `db:"namespace" json:"namespace"`
+	Name      string             `db:"name" json:"name"`
+	ValidFrom pgtype.Timestamptz `db:"valid_from" json:"validFrom"`
+	// ValidTo is null for the current revision, revisions of deleted objects end when the object was deleted
+	ValidTo pgtype.Timestamptz `db:"valid_to" json:"validTo"`
+	Spec    map[string]any     `db:"spec" json:"spec"`
+}
+
+type TimelineRequest struct {
+	Namespace string
+	Kind      string
+	Name      string
+	Start     time.Time
+	End       time.Time
+}
+
+type TimelineCost struct {
+	Timestamp            pgtype.Timestamptz `db:"timestamp" json:"timestamp"`
+	Pods                 int64              `db:"pods" json:"pods"`
+	RequestCPUCoreHours  float64            `db:"request_cpu_core_hours" json:"requestCpuCoreHours"`
+	RequestMemoryGBHours float64            `db:"request_memory_gb_hours" json:"requestMemoryGbHours"`
+	TotalCost            float64            `db:"total_cost" json:"totalCost"`
+}
+
+// Timeline is the hourly cost of a controller next to revisions of the controller
+type Timeline struct {
+	Costs     []TimelineCost   `json:"costs"`
+	Revisions []ObjectRevision `json:"revisions"`
+}
+
+const timelineCosts = `
+select timestamp,
+       count(distinct uid)                                                                              as pods,
+       coalesce(sum(request_cpu_cores * hours), 0)::float8                                              as request_cpu_core_hours,
+       coalesce(sum(request_memory_bytes * hours), 0)::float8 / 1024 / 1024 / 1024                     as request_memory_gb_hours,
+       coalesce(sum(cpu_cost + memory_cost + storage_cost + extended_cost + ephemeral_cost), 0)::float8 as total_cost
+from cost_hourly
+where cluster_id = @cluster_id
+  and namespace = @namespace
+  and controller_kind = @kind
+  and controller_name = @name
+  and timestamp >= @start
+  and timestamp < @end
+group by timestamp
+order by timestamp
+`
+
+const timelineRevisions = `
+select object_revision.uid,
+       object_revision.kind,
+       object_revision.namespace,
+       object_revision.name,
+       object_revision.valid_from,
+       coalesce(object_revision.valid_to, object.deleted_at) as valid_to,
+       object_revision.spec
+from object_revision
+         left join object on (object.uid = object_revision.uid)
+where object_revision.cluster_id = @cluster_id
+  and object_revision.namespace = @namespace
+  and object_revision.kind = @kind
+  and object_revision.name = @name
+  and object_revision.valid_from < @end
+  and coalesce(object_revision.valid_to, object.deleted_at, 'infinity') >= @start
+order by object_revision.valid_from
+`
+
+// ControllerTimeline returns hourly cost and spec changes of a controller, e.g. to tie cost changes to rollouts
+func (q *Queries) ControllerTimeline(ctx context.Context, req TimelineRequest) (*Timeline, error) {
+	if req.Start.After(req.End) {
+		return nil, fmt.Errorf("start time is after end time")
+	}
+	args := pgx.NamedArgs{
+		"cluster_id": q.clusterID,
+		"namespace":  req.Namespace,
+		"kind":       req.Kind,
+		"name":       req.Name,
+		"start":      req.Start,
+		"end":        req.End,
+	}
+	rows, err := q.query(ctx, timelineCosts, args)
+	if err != nil {
+		return nil, err
+	}
+	costs, err := pgx.CollectRows(rows, pgx.RowToStructByName[TimelineCost])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect timeline cost rows: %w", err)
+	}
+	rows, err = q.query(ctx, timelineRevisions, args)
+	if err != nil {
+		return nil, err
+	}
+	revisions, err := pgx.CollectRows(rows, pgx.RowToStructByName[ObjectRevision])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect object revision rows: %w", err)
+	}
+	return &Timeline{Costs: costs, Revisions: revisions}, nil
+}
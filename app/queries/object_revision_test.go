@@ -0,0 +1,59 @@
+package queries
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestControllerTimeline(t *testing.T) {
+	queries := NewTestQueries(t)
+	ctx := context.TODO()
+	replicas := int32(2)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			UID:               NewKUUID(),
+			Namespace:         "default",
+			Name:              "app",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Template: v1.PodTemplateSpec{Spec: v1.PodSpec{Containers: []v1.Container{{
+				Name: "app",
+				Resources: v1.ResourceRequirements{Requests: v1.ResourceList{
+					v1.ResourceCPU: resource.MustParse("100m"),
+				}},
+			}}}},
+		},
+	}
+	require.NoError(t, queries.UpsertObject(ctx, "Deployment", deployment))
+	// status and unrelated changes don't create revisions
+	deployment.Status.ReadyReplicas = 2
+	require.NoError(t, queries.UpsertObject(ctx, "Deployment", deployment))
+	deployment.Spec.Template.Spec.Containers[0].Resources.Requests[v1.ResourceCPU] = resource.MustParse("200m")
+	require.NoError(t, queries.UpsertObject(ctx, "Deployment", deployment))
+
+	timeline, err := queries.ControllerTimeline(ctx, TimelineRequest{
+		Namespace: "default",
+		Kind:      "Deployment",
+		Name:      "app",
+		Start:     time.Now().Add(-24 * time.Hour),
+		End:       time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	assert.Empty(t, timeline.Costs)
+	require.Len(t, timeline.Revisions, 2)
+	assert.WithinDuration(t, deployment.CreationTimestamp.Time, timeline.Revisions[0].ValidFrom.Time, time.Second)
+	assert.Equal(t, timeline.Revisions[0].ValidTo.Time, timeline.Revisions[1].ValidFrom.Time)
+	assert.False(t, timeline.Revisions[1].ValidTo.Valid)
+	assert.EqualValues(t, 2, timeline.Revisions[1].Spec["replicas"])
+	assert.Equal(t, map[string]any{"app": map[string]any{"requests": map[string]any{"cpu": "200m"}}}, timeline.Revisions[1].Spec["resources"])
+}
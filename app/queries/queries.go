@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
@@ -22,36 +23,86 @@ func (q *Queries) UpsertObject(ctx context.Context, kind string, object any) err
 	if err != nil {
 		return fmt.Errorf("failed to marshal object: %w", err)
 	}
+	return q.UpsertObjectData(ctx, kind, objectGetter, data, PodColumns{})
+}
+
+// PodColumns are computed by the scraper and stored next to the pod, they are null for other kinds
+// pods written without them (e.g. by UpsertObject) have no requests and limits in the pod view
+type PodColumns struct {
+	// effective requests
+	RequestCpuCores       pgtype.Float8
+	RequestMemoryBytes    pgtype.Float8
+	RequestEphemeralBytes pgtype.Float8
+	// effective limits, 0 if no container sets one
+	LimitCpuCores    pgtype.Float8
+	LimitMemoryBytes pgtype.Float8
+	// ScheduledAt and FinishedAt bound the time the pod holds node resources
+	// FinishedAt is set once containers of a succeeded or failed pod exit
+	ScheduledAt pgtype.Timestamptz
+	FinishedAt  pgtype.Timestamptz
+}
+
+// UpsertObjectData stores already serialized object, e.g. with noisy fields removed
+func (q *Queries) UpsertObjectData(ctx context.Context, kind string, objectGetter metav1.Object, data []byte, pod PodColumns) error {
 	uo := struct {
-		ClusterID int    `db:"cluster_id"`
-		Kind      string `db:"kind"`
-		Uid       string `db:"uid"`
-		Namespace string `db:"namespace"`
-		Name      string `db:"name"`
-		Data      any    `db:"data"`
+		ClusterID             int                `db:"cluster_id"`
+		Kind                  string             `db:"kind"`
+		Uid                   string             `db:"uid"`
+		Namespace             string             `db:"namespace"`
+		Name                  string             `db:"name"`
+		Data                  any                `db:"data"`
+		RequestCpuCores       pgtype.Float8      `db:"request_cpu_cores"`
+		RequestMemoryBytes    pgtype.Float8      `db:"request_memory_bytes"`
+		RequestEphemeralBytes pgtype.Float8      `db:"request_ephemeral_bytes"`
+		LimitCpuCores         pgtype.Float8      `db:"limit_cpu_cores"`
+		LimitMemoryBytes      pgtype.Float8      `db:"limit_memory_bytes"`
+		ScheduledAt           pgtype.Timestamptz `db:"scheduled_at"`
+		FinishedAt            pgtype.Timestamptz `db:"finished_at"`
 	}{
-		ClusterID: q.clusterID,
-		Kind:      kind,
-		Uid:       string(objectGetter.GetUID()),
-		Namespace: objectGetter.GetNamespace(),
-		Name:      objectGetter.GetName(),
-		Data:      data,
+		ClusterID:             q.clusterID,
+		Kind:                  kind,
+		Uid:                   string(objectGetter.GetUID()),
+		Namespace:             objectGetter.GetNamespace(),
+		Name:                  objectGetter.GetName(),
+		Data:                  data,
+		RequestCpuCores:       pod.RequestCpuCores,
+		RequestMemoryBytes:    pod.RequestMemoryBytes,
+		RequestEphemeralBytes: pod.RequestEphemeralBytes,
+		LimitCpuCores:         pod.LimitCpuCores,
+		LimitMemoryBytes:      pod.LimitMemoryBytes,
+		ScheduledAt:           pod.ScheduledAt,
+		FinishedAt:            pod.FinishedAt,
 	}
 
 	const upsertObject = `
-insert into object (uid, cluster_id, kind, namespace, name, data)
-values (@uid, @cluster_id, @kind, @namespace, @name, @data)
+insert into object (uid, cluster_id, kind, namespace, name, data, request_cpu_cores, request_memory_bytes,
+                    request_ephemeral_bytes, limit_cpu_cores, limit_memory_bytes, scheduled_at, finished_at)
+values (@uid, @cluster_id, @kind, @namespace, @name, @data, @request_cpu_cores, @request_memory_bytes,
+        @request_ephemeral_bytes, @limit_cpu_cores, @limit_memory_bytes, @scheduled_at, @finished_at)
 on conflict (uid)
-    do update set cluster_id  = @cluster_id,
-                  kind        = @kind,
-                  namespace   = @namespace,
-                  name        = @name,
-                  data        = @data
+    do update set cluster_id              = @cluster_id,
+                  kind                    = @kind,
+                  namespace               = @namespace,
+                  name                    = @name,
+                  data                    = @data,
+                  request_cpu_cores       = @request_cpu_cores,
+                  request_memory_bytes    = @request_memory_bytes,
+                  request_ephemeral_bytes = @request_ephemeral_bytes,
+                  limit_cpu_cores         = @limit_cpu_cores,
+                  limit_memory_bytes      = @limit_memory_bytes,
+                  scheduled_at            = @scheduled_at,
+                  finished_at             = @finished_at
 `
-	_, err = q.execStruct(ctx, upsertObject, uo)
+	_, err := q.execStruct(ctx, upsertObject, uo)
 	if err != nil {
 		return err
 	}
+	// pods are numerous and their resources don't change, changes are tracked on controllers
+	if kind != "Pod" {
+		if err := q.recordObjectRevision(ctx, uo.Uid); err != nil {
+			return err
+		}
+	}
 	slog.Debug("upserted object", "kind", kind, "uid", uo.Uid)
 	return nil
 }
@@ -89,6 +140,67 @@ func (q *Queries) ActiveObjectCount(ctx context.Context) (int, error) {
 	return count, nil
 }
 
+type UpsertEventParams struct {
+	ClusterID      int                `db:"cluster_id"`
+	Uid            pgtype.UUID        `db:"uid"`
+	Namespace      string             `db:"namespace"`
+	Name           string             `db:"name"`
+	InvolvedKind   string             `db:"involved_kind"`
+	InvolvedUid    string             `db:"involved_uid"`
+	InvolvedName   string             `db:"involved_name"`
+	Type           string             `db:"type"`
+	Reason         string             `db:"reason"`
+	Message        string             `db:"message"`
+	Count          int32              `db:"count"`
+	FirstTimestamp pgtype.Timestamptz `db:"first_timestamp"`
+	LastTimestamp  pgtype.Timestamptz `db:"last_timestamp"`
+}
+
+// UpsertEvent stores an event, updates of a repeated event replace its count instead of adding a new row
+// stale updates (e.g. replayed by a resync) never decrease the count
+func (q *Queries) UpsertEvent(ctx context.Context, arg UpsertEventParams) error {
+	arg.ClusterID = q.clusterID
+	const upsertEvent = `
+insert into event (cluster_id, uid, namespace, name, involved_kind, involved_uid, involved_name, type, reason, message,
+                   count, first_timestamp, last_timestamp)
+values (@cluster_id, @uid, @namespace, @name, @involved_kind, @involved_uid, @involved_name, @type, @reason, @message,
+        @count, @first_timestamp, @last_timestamp)
+on conflict (uid)
+    do update set count          = greatest(event.count, excluded.count),
+                  message        = excluded.message,
+                  last_timestamp = greatest(event.last_timestamp, excluded.last_timestamp)
+`
+	_, err := q.execStruct(ctx, upsertEvent, arg)
+	return err
+}
+
+type Event struct {
+	Uid            pgtype.UUID        `db:"uid"`
+	InvolvedKind   string             `db:"involved_kind"`
+	InvolvedUid    string             `db:"involved_uid"`
+	Reason         string             `db:"reason"`
+	Count          int32              `db:"count"`
+	FirstTimestamp pgtype.Timestamptz `db:"first_timestamp"`
+	LastTimestamp  pgtype.Timestamptz `db:"last_timestamp"`
+}
+
+func (q *Queries) ListEvents(ctx context.Context) ([]Event, error) {
+	const listEvents = `select uid, involved_kind, involved_uid, reason, count, first_timestamp, last_timestamp
+from event
+order by last_timestamp desc
+limit 100
+`
+	rows, err := q.query(ctx, listEvents)
+	if err != nil {
+		return nil, err
+	}
+	data, err := pgx.CollectRows(rows, pgx.RowToStructByName[Event])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect event rows: %w", err)
+	}
+	return data, nil
+}
+
 type PodUsageHourly struct {
 	PodUid                   pgtype.UUID        `db:"pod_uid"`
 	ClusterID                int                `db:"cluster_id"`
@@ -123,8 +235,9 @@ limit 100
 }
 
 type UpsertPodUsedCPUParams struct {
-	ClusterID int                `db:"cluster_id"`
-	PodUid    pgtype.UUID        `db:"pod_uid"`
+	ClusterID int         `db:"cluster_id"`
+	PodUid    pgtype.UUID `db:"pod_uid"`
+	// Timestamp of the reading, pod_usage_hourly stores it truncated to the hour
 	Timestamp pgtype.Timestamptz `db:"timestamp"`
 	CpuCores  float64            `db:"cpu_cores"`
 }
@@ -132,6 +245,7 @@ type UpsertPodUsedCPUParams struct {
 func (q *Queries) UpsertPodUsedCPU(ctx context.Context, arg []UpsertPodUsedCPUParams) error {
 	for i := range arg {
 		arg[i].ClusterID = q.clusterID
+		arg[i].Timestamp = truncateToHour(arg[i].Timestamp)
 	}
 	const upsertPodUsedCPU = `
 insert into pod_usage_hourly (pod_uid, cluster_id, timestamp, cpu_cores_max, cpu_cores_min, cpu_cores_total,
@@ -145,6 +259,8 @@ on conflict (pod_uid, timestamp)
                                                      then pod_usage_hourly.cpu_cores_max
                                                  else @cpu_cores end,
                   cpu_cores_min            = case
+                                                 when @cpu_cores = 0
+                                                     then pod_usage_hourly.cpu_cores_min
                                                  when pod_usage_hourly.cpu_cores_min < @cpu_cores and
                                                       pod_usage_hourly.cpu_cores_min != 0
                                                      then pod_usage_hourly.cpu_cores_min
@@ -155,8 +271,9 @@ on conflict (pod_uid, timestamp)
 }
 
 type UpsertPodUsedMemoryParams struct {
-	ClusterID   int                `db:"cluster_id"`
-	PodUid      pgtype.UUID        `db:"pod_uid"`
+	ClusterID int         `db:"cluster_id"`
+	PodUid    pgtype.UUID `db:"pod_uid"`
+	// Timestamp of the reading, pod_usage_hourly stores it truncated to the hour
 	Timestamp   pgtype.Timestamptz `db:"timestamp"`
 	MemoryBytes float64            `db:"memory_bytes"`
 }
@@ -164,6 +281,7 @@ type UpsertPodUsedMemoryParams struct {
 func (q *Queries) UpsertPodUsedMemory(ctx context.Context, arg []UpsertPodUsedMemoryParams) error {
 	for i := range arg {
 		arg[i].ClusterID = q.clusterID
+		arg[i].Timestamp = truncateToHour(arg[i].Timestamp)
 	}
 	const upsertPodUsedMemory = `
 insert into pod_usage_hourly (pod_uid, cluster_id, timestamp, memory_bytes_max, memory_bytes_min,
@@ -178,6 +296,8 @@ on conflict (pod_uid, timestamp)
                                                         then pod_usage_hourly.memory_bytes_max
                                                     else @memory_bytes end,
                   memory_bytes_min            = case
+                                                    when @memory_bytes = 0
+                                                        then pod_usage_hourly.memory_bytes_min
                                                     when pod_usage_hourly.memory_bytes_min < @memory_bytes and
                                                          pod_usage_hourly.memory_bytes_min != 0
                                                         then pod_usage_hourly.memory_bytes_min
@@ -187,6 +307,693 @@ on conflict (pod_uid, timestamp)
 	return execBatch(ctx, q, upsertPodUsedMemory, arg)
 }
 
+type InsertPodUsageHourlyParams struct {
+	ClusterID                int                `db:"cluster_id"`
+	PodUid                   pgtype.UUID        `db:"pod_uid"`
+	Timestamp                pgtype.Timestamptz `db:"timestamp"`
+	MemoryBytesMax           float64            `db:"memory_bytes_max"`
+	MemoryBytesMin           float64            `db:"memory_bytes_min"`
+	MemoryBytesTotal         float64            `db:"memory_bytes_total"`
+	MemoryBytesTotalReadings int32              `db:"memory_bytes_total_readings"`
+	CpuCoresMax              float64            `db:"cpu_cores_max"`
+	CpuCoresMin              float64            `db:"cpu_cores_min"`
+	CpuCoresTotal            float64            `db:"cpu_cores_total"`
+	CpuCoresTotalReadings    int32              `db:"cpu_cores_total_readings"`
+}
+
+// podUsageHourlyOnConflict merges aggregated readings with the existing row
+const podUsageHourlyOnConflict = `
+on conflict (pod_uid, timestamp)
+    do update set cluster_id                  = excluded.cluster_id,
+                  memory_bytes_total_readings = pod_usage_hourly.memory_bytes_total_readings + excluded.memory_bytes_total_readings,
+                  memory_bytes_max            = greatest(pod_usage_hourly.memory_bytes_max, excluded.memory_bytes_max),
+                  memory_bytes_min            = case
+                                                    when excluded.memory_bytes_min = 0
+                                                        then pod_usage_hourly.memory_bytes_min
+                                                    when pod_usage_hourly.memory_bytes_min < excluded.memory_bytes_min and
+                                                         pod_usage_hourly.memory_bytes_min != 0
+                                                        then pod_usage_hourly.memory_bytes_min
+                                                    else excluded.memory_bytes_min end,
+                  memory_bytes_total          = pod_usage_hourly.memory_bytes_total + excluded.memory_bytes_total,
+                  cpu_cores_total_readings    = pod_usage_hourly.cpu_cores_total_readings + excluded.cpu_cores_total_readings,
+                  cpu_cores_max               = greatest(pod_usage_hourly.cpu_cores_max, excluded.cpu_cores_max),
+                  cpu_cores_min               = case
+                                                    when excluded.cpu_cores_min = 0
+                                                        then pod_usage_hourly.cpu_cores_min
+                                                    when pod_usage_hourly.cpu_cores_min < excluded.cpu_cores_min and
+                                                         pod_usage_hourly.cpu_cores_min != 0
+                                                        then pod_usage_hourly.cpu_cores_min
+                                                    else excluded.cpu_cores_min end,
+                  cpu_cores_total             = pod_usage_hourly.cpu_cores_total + excluded.cpu_cores_total
+`
+
+// InsertPodUsageHourly stores pre-aggregated hourly readings, hours which already have a row for the pod are skipped
+// importing the same range again (e.g. a repeated backfill) doesn't count readings twice
+func (q *Queries) InsertPodUsageHourly(ctx context.Context, arg []InsertPodUsageHourlyParams) error {
+	for i := range arg {
+		arg[i].ClusterID = q.clusterID
+	}
+	const insertPodUsageHourly = `
+insert into pod_usage_hourly (pod_uid, cluster_id, timestamp, memory_bytes_max, memory_bytes_min, memory_bytes_total,
+                              memory_bytes_total_readings, cpu_cores_max, cpu_cores_min, cpu_cores_total,
+                              cpu_cores_total_readings)
+values (@pod_uid, @cluster_id, @timestamp, @memory_bytes_max, @memory_bytes_min, @memory_bytes_total,
+        @memory_bytes_total_readings, @cpu_cores_max, @cpu_cores_min, @cpu_cores_total, @cpu_cores_total_readings)
+on conflict (pod_uid, timestamp) do nothing
+`
+	return execBatch(ctx, q, insertPodUsageHourly, arg)
+}
+
+// PodUsageSample is a single reading of pod usage, CPU or memory may be unset
+type PodUsageSample struct {
+	PodUid pgtype.UUID
+	// Timestamp of the reading, raw samples keep it as is and pod_usage_hourly stores it truncated to the hour
+	Timestamp   pgtype.Timestamptz
+	CpuCores    pgtype.Float8
+	MemoryBytes pgtype.Float8
+}
+
+func truncateToHour(timestamp pgtype.Timestamptz) pgtype.Timestamptz {
+	timestamp.Time = timestamp.Time.Truncate(time.Hour)
+	return timestamp
+}
+
+var podUsageStaging = stagingTable{
+	name: "pod_usage_staging",
+	create: `
+create temporary table if not exists pod_usage_staging
+(
+    pod_uid      uuid                     not null,
+    cluster_id   smallint                 not null,
+    timestamp    timestamp with time zone not null,
+    cpu_cores    double precision,
+    memory_bytes double precision
+) on commit delete rows`,
+	columns: []string{"pod_uid", "cluster_id", "timestamp", "cpu_cores", "memory_bytes"},
+	merge: `
+insert into pod_usage_hourly (pod_uid, cluster_id, timestamp, memory_bytes_max, memory_bytes_min, memory_bytes_total,
+                              memory_bytes_total_readings, cpu_cores_max, cpu_cores_min, cpu_cores_total,
+                              cpu_cores_total_readings)
+select pod_uid,
+       cluster_id,
+       timestamp,
+       coalesce(max(memory_bytes), 0),
+       coalesce(min(nullif(memory_bytes, 0)), 0),
+       coalesce(sum(memory_bytes), 0),
+       count(memory_bytes),
+       coalesce(max(cpu_cores), 0),
+       coalesce(min(nullif(cpu_cores, 0)), 0),
+       coalesce(sum(cpu_cores), 0),
+       count(cpu_cores)
+from pod_usage_staging
+group by pod_uid, cluster_id, timestamp
+` + podUsageHourlyOnConflict,
+}
+
+// MergePodUsageSamples copies samples into a staging table and merges them into pod_usage_hourly with a single upsert
+// the result is the same as calling UpsertPodUsedCPU/UpsertPodUsedMemory for every sample, zero readings don't lower
+// the minimum in either
+func (q *Queries) MergePodUsageSamples(ctx context.Context, samples []PodUsageSample) error {
+	rows := make([][]any, 0, len(samples))
+	for _, sample := range samples {
+		rows = append(rows, []any{sample.PodUid, q.clusterID, truncateToHour(sample.Timestamp), sample.CpuCores, sample.MemoryBytes})
+	}
+	return q.copyMerge(ctx, podUsageStaging, rows)
+}
+
+// InsertPodUsageRaw appends samples to pod_usage_raw
+func (q *Queries) InsertPodUsageRaw(ctx context.Context, samples []PodUsageSample) error {
+	_, err := q.db.CopyFrom(ctx, pgx.Identifier{"pod_usage_raw"},
+		[]string{"pod_uid", "cluster_id", "timestamp", "cpu_cores", "memory_bytes"},
+		pgx.CopyFromSlice(len(samples), func(i int) ([]any, error) {
+			sample := samples[i]
+			return []any{sample.PodUid, q.clusterID, sample.Timestamp, sample.CpuCores, sample.MemoryBytes}, nil
+		}))
+	if err != nil {
+		return fmt.Errorf("copying raw samples: %w", WrapError(err))
+	}
+	return nil
+}
+
+// RollupPodUsageRaw merges raw samples which aren't rolled up yet into pod_usage_hourly
+// every sample is counted once, hourly rows written by other sources (e.g. backfill) are kept
+func (q *Queries) RollupPodUsageRaw(ctx context.Context) (int64, error) {
+	const rollupPodUsageRaw = `
+with rolled_up as (
+    update pod_usage_raw
+        set rolled_up = true
+        where cluster_id = @cluster_id
+            and not rolled_up
+        returning pod_uid, cluster_id, timestamp, cpu_cores, memory_bytes )
+insert
+into pod_usage_hourly (pod_uid, cluster_id, timestamp, memory_bytes_max, memory_bytes_min, memory_bytes_total,
+                       memory_bytes_total_readings, cpu_cores_max, cpu_cores_min, cpu_cores_total,
+                       cpu_cores_total_readings)
+select pod_uid,
+       cluster_id,
+       date_trunc('hour', timestamp),
+       coalesce(max(memory_bytes), 0),
+       coalesce(min(nullif(memory_bytes, 0)), 0),
+       coalesce(sum(memory_bytes), 0),
+       count(memory_bytes),
+       coalesce(max(cpu_cores), 0),
+       coalesce(min(nullif(cpu_cores, 0)), 0),
+       coalesce(sum(cpu_cores), 0),
+       count(cpu_cores)
+from rolled_up
+group by pod_uid, cluster_id, date_trunc('hour', timestamp)
+` + podUsageHourlyOnConflict
+	cmd, err := q.db.Exec(ctx, rollupPodUsageRaw, pgx.NamedArgs{"cluster_id": q.clusterID})
+	if err != nil {
+		return 0, fmt.Errorf("rolling up raw samples: %w", WrapError(err))
+	}
+	return cmd.RowsAffected(), nil
+}
+
+// DeletePodUsageRaw deletes rolled up raw samples older than before
+func (q *Queries) DeletePodUsageRaw(ctx context.Context, before time.Time) (int64, error) {
+	const deletePodUsageRaw = `delete from pod_usage_raw where cluster_id = $1 and rolled_up and timestamp < $2`
+	cmd, err := q.db.Exec(ctx, deletePodUsageRaw, q.clusterID, before)
+	if err != nil {
+		return 0, fmt.Errorf("deleting raw samples: %w", WrapError(err))
+	}
+	return cmd.RowsAffected(), nil
+}
+
+// EarliestPodUsageHour returns the first hour with collected pod usage, the result is not valid if there is no data
+func (q *Queries) EarliestPodUsageHour(ctx context.Context) (pgtype.Timestamptz, error) {
+	const earliestPodUsageHour = `select min(timestamp) from pod_usage_hourly where cluster_id = $1`
+	var result pgtype.Timestamptz
+	err := q.db.QueryRow(ctx, earliestPodUsageHour, q.clusterID).Scan(&result)
+	return result, WrapError(err)
+}
+
+type PodLifetime struct {
+	Uid       pgtype.UUID        `db:"uid"`
+	Namespace string             `db:"namespace"`
+	Name      string             `db:"name"`
+	CreatedAt pgtype.Timestamptz `db:"created_at"`
+	DeletedAt pgtype.Timestamptz `db:"deleted_at"`
+}
+
+// ListPodLifetimes returns all stored pods (including deleted ones) with their creation and deletion time
+func (q *Queries) ListPodLifetimes(ctx context.Context) ([]PodLifetime, error) {
+	const listPodLifetimes = `select uid,
+       namespace,
+       name,
+       (data -> 'metadata' ->> 'creationTimestamp')::timestamptz as created_at,
+       deleted_at
+from object
+where kind = 'Pod'
+  and cluster_id = $1
+`
+	rows, err := q.query(ctx, listPodLifetimes, q.clusterID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := pgx.CollectRows(rows, pgx.RowToStructByName[PodLifetime])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect pod lifetime rows: %w", err)
+	}
+	return data, nil
+}
+
+type ContainerUsageHourly struct {
+	PodUid                   pgtype.UUID        `db:"pod_uid"`
+	ContainerName            string             `db:"container_name"`
+	ClusterID                int                `db:"cluster_id"`
+	Timestamp                pgtype.Timestamptz `db:"timestamp"`
+	MemoryBytesMax           float64            `db:"memory_bytes_max"`
+	MemoryBytesMin           float64            `db:"memory_bytes_min"`
+	MemoryBytesTotal         float64            `db:"memory_bytes_total"`
+	MemoryBytesTotalReadings int32              `db:"memory_bytes_total_readings"`
+	MemoryBytesAvg           float64            `db:"memory_bytes_avg"`
+	CpuCoresMax              float64            `db:"cpu_cores_max"`
+	CpuCoresMin              float64            `db:"cpu_cores_min"`
+	CpuCoresTotal            float64            `db:"cpu_cores_total"`
+	CpuCoresTotalReadings    int32              `db:"cpu_cores_total_readings"`
+	CpuCoresAvg              float64            `db:"cpu_cores_avg"`
+}
+
+func (q *Queries) ListContainerUsageHourly(ctx context.Context) ([]ContainerUsageHourly, error) {
+	const listContainerUsageHourly = `select pod_uid, container_name, cluster_id, timestamp, memory_bytes_max, memory_bytes_min, memory_bytes_total, memory_bytes_total_readings, memory_bytes_avg, cpu_cores_max, cpu_cores_min, cpu_cores_total, cpu_cores_total_readings, cpu_cores_avg
+from container_usage_hourly
+order by timestamp desc, container_name
+limit 100
+`
+	rows, err := q.query(ctx, listContainerUsageHourly)
+	if err != nil {
+		return nil, err
+	}
+	data, err := pgx.CollectRows(rows, pgx.RowToStructByName[ContainerUsageHourly])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect container usage rows: %w", err)
+	}
+	return data, nil
+}
+
+type UpsertContainerUsedCPUParams struct {
+	ClusterID     int                `db:"cluster_id"`
+	PodUid        pgtype.UUID        `db:"pod_uid"`
+	ContainerName string             `db:"container_name"`
+	Timestamp     pgtype.Timestamptz `db:"timestamp"`
+	CpuCores      float64            `db:"cpu_cores"`
+}
+
+func (q *Queries) UpsertContainerUsedCPU(ctx context.Context, arg []UpsertContainerUsedCPUParams) error {
+	for i := range arg {
+		arg[i].ClusterID = q.clusterID
+	}
+	const upsertContainerUsedCPU = `
+insert into container_usage_hourly (pod_uid, container_name, cluster_id, timestamp, cpu_cores_max, cpu_cores_min,
+                                    cpu_cores_total, cpu_cores_total_readings)
+values (@pod_uid, @container_name, @cluster_id, @timestamp, @cpu_cores, @cpu_cores, @cpu_cores, 1)
+on conflict (pod_uid, container_name, timestamp)
+    do update set cluster_id 			   = @cluster_id,
+                  cpu_cores_total_readings = container_usage_hourly.cpu_cores_total_readings + 1,
+                  cpu_cores_max            = case
+                                                 when container_usage_hourly.cpu_cores_max > @cpu_cores
+                                                     then container_usage_hourly.cpu_cores_max
+                                                 else @cpu_cores end,
+                  cpu_cores_min            = case
+                                                 when @cpu_cores = 0
+                                                     then container_usage_hourly.cpu_cores_min
+                                                 when container_usage_hourly.cpu_cores_min < @cpu_cores and
+                                                      container_usage_hourly.cpu_cores_min != 0
+                                                     then container_usage_hourly.cpu_cores_min
+                                                 else @cpu_cores end,
+                  cpu_cores_total          = container_usage_hourly.cpu_cores_total + @cpu_cores
+`
+	return execBatch(ctx, q, upsertContainerUsedCPU, arg)
+}
+
+type UpsertContainerUsedMemoryParams struct {
+	ClusterID     int                `db:"cluster_id"`
+	PodUid        pgtype.UUID        `db:"pod_uid"`
+	ContainerName string             `db:"container_name"`
+	Timestamp     pgtype.Timestamptz `db:"timestamp"`
+	MemoryBytes   float64            `db:"memory_bytes"`
+}
+
+func (q *Queries) UpsertContainerUsedMemory(ctx context.Context, arg []UpsertContainerUsedMemoryParams) error {
+	for i := range arg {
+		arg[i].ClusterID = q.clusterID
+	}
+	const upsertContainerUsedMemory = `
+insert into container_usage_hourly (pod_uid, container_name, cluster_id, timestamp, memory_bytes_max, memory_bytes_min,
+                                    memory_bytes_total, memory_bytes_total_readings)
+values (@pod_uid, @container_name, @cluster_id, @timestamp, @memory_bytes, @memory_bytes, @memory_bytes, 1)
+on conflict (pod_uid, container_name, timestamp)
+    do update set cluster_id 			      = @cluster_id,
+                  memory_bytes_total_readings = container_usage_hourly.memory_bytes_total_readings + 1,
+                  memory_bytes_max            = case
+                                                    when container_usage_hourly.memory_bytes_max > @memory_bytes
+                                                        then container_usage_hourly.memory_bytes_max
+                                                    else @memory_bytes end,
+                  memory_bytes_min            = case
+                                                    when @memory_bytes = 0
+                                                        then container_usage_hourly.memory_bytes_min
+                                                    when container_usage_hourly.memory_bytes_min < @memory_bytes and
+                                                         container_usage_hourly.memory_bytes_min != 0
+                                                        then container_usage_hourly.memory_bytes_min
+                                                    else @memory_bytes end,
+                  memory_bytes_total          = container_usage_hourly.memory_bytes_total + @memory_bytes
+`
+	return execBatch(ctx, q, upsertContainerUsedMemory, arg)
+}
+
+type UpsertPodRequestParams struct {
+	ClusterID   int                `db:"cluster_id"`
+	PodUid      pgtype.UUID        `db:"pod_uid"`
+	Timestamp   pgtype.Timestamptz `db:"timestamp"`
+	CpuCores    float64            `db:"cpu_cores"`
+	MemoryBytes float64            `db:"memory_bytes"`
+}
+
+// UpsertPodRequests adds a reading of the effective pod requests, the hourly request is the average of readings
+func (q *Queries) UpsertPodRequests(ctx context.Context, arg []UpsertPodRequestParams) error {
+	for i := range arg {
+		arg[i].ClusterID = q.clusterID
+	}
+	const upsertPodRequests = `
+insert into pod_request_hourly (pod_uid, cluster_id, timestamp, cpu_cores_total, memory_bytes_total, readings)
+values (@pod_uid, @cluster_id, @timestamp, @cpu_cores, @memory_bytes, 1)
+on conflict (pod_uid, timestamp)
+    do update set cluster_id         = @cluster_id,
+                  cpu_cores_total    = pod_request_hourly.cpu_cores_total + @cpu_cores,
+                  memory_bytes_total = pod_request_hourly.memory_bytes_total + @memory_bytes,
+                  readings           = pod_request_hourly.readings + 1
+`
+	return execBatch(ctx, q, upsertPodRequests, arg)
+}
+
+type PodRequestHourly struct {
+	PodUid         pgtype.UUID        `db:"pod_uid"`
+	Timestamp      pgtype.Timestamptz `db:"timestamp"`
+	CpuCoresAvg    float64            `db:"cpu_cores_avg"`
+	MemoryBytesAvg float64            `db:"memory_bytes_avg"`
+	Readings       int32              `db:"readings"`
+}
+
+func (q *Queries) ListPodRequestHourly(ctx context.Context) ([]PodRequestHourly, error) {
+	const listPodRequestHourly = `select pod_uid, timestamp, cpu_cores_avg, memory_bytes_avg, readings
+from pod_request_hourly
+order by timestamp desc
+limit 100
+`
+	rows, err := q.query(ctx, listPodRequestHourly)
+	if err != nil {
+		return nil, err
+	}
+	data, err := pgx.CollectRows(rows, pgx.RowToStructByName[PodRequestHourly])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect pod request rows: %w", err)
+	}
+	return data, nil
+}
+
+// UpsertPodContainerStatusParams describes a container status, TerminatedAt and Reason are empty if the container never terminated
+type UpsertPodContainerStatusParams struct {
+	ClusterID     int                `db:"cluster_id"`
+	PodUid        pgtype.UUID        `db:"pod_uid"`
+	ContainerName string             `db:"container_name"`
+	RestartCount  int32              `db:"restart_count"`
+	TerminatedAt  pgtype.Timestamptz `db:"terminated_at"`
+	Reason        string             `db:"reason"`
+}
+
+// UpsertPodContainerStatus records the restart count and latest termination of containers
+// the difference to the previously recorded state is added to pod_restart_hourly at the hour of the termination
+func (q *Queries) UpsertPodContainerStatus(ctx context.Context, arg []UpsertPodContainerStatusParams) error {
+	for i := range arg {
+		arg[i].ClusterID = q.clusterID
+	}
+	const upsertPodContainerStatus = `
+with prev as ( select restart_count, terminated_at
+               from pod_container_state
+               where pod_uid = @pod_uid
+                 and container_name = @container_name ),
+     state as (
+         insert into pod_container_state (cluster_id, pod_uid, container_name, restart_count, terminated_at)
+             values (@cluster_id, @pod_uid, @container_name, @restart_count, @terminated_at)
+             on conflict (pod_uid, container_name)
+                 do update set restart_count = excluded.restart_count,
+                     terminated_at = coalesce(excluded.terminated_at, pod_container_state.terminated_at),
+                     updated_at = now() ),
+     change as ( select greatest(@restart_count - coalesce(( select restart_count from prev ), 0), 0) as restarts,
+                        coalesce(@terminated_at::timestamptz >
+                                 coalesce(( select terminated_at from prev ), '-infinity'), false)   as terminated )
+insert
+into pod_restart_hourly (cluster_id, pod_uid, container_name, timestamp, restarts, oom_killed, errors, completed)
+select @cluster_id,
+       @pod_uid,
+       @container_name,
+       date_trunc('hour', coalesce(@terminated_at::timestamptz, now())),
+       restarts,
+       case when terminated and @reason = 'OOMKilled' then 1 else 0 end,
+       case when terminated and @reason = 'Error' then 1 else 0 end,
+       case when terminated and @reason = 'Completed' then 1 else 0 end
+from change
+where restarts > 0
+   or terminated
+on conflict (pod_uid, container_name, timestamp)
+    do update set restarts   = pod_restart_hourly.restarts + excluded.restarts,
+                  oom_killed = pod_restart_hourly.oom_killed + excluded.oom_killed,
+                  errors     = pod_restart_hourly.errors + excluded.errors,
+                  completed  = pod_restart_hourly.completed + excluded.completed
+`
+	return execBatch(ctx, q, upsertPodContainerStatus, arg)
+}
+
+type PodRestartHourly struct {
+	PodUid        pgtype.UUID        `db:"pod_uid"`
+	ContainerName string             `db:"container_name"`
+	Timestamp     pgtype.Timestamptz `db:"timestamp"`
+	Restarts      int32              `db:"restarts"`
+	OomKilled     int32              `db:"oom_killed"`
+	Errors        int32              `db:"errors"`
+	Completed     int32              `db:"completed"`
+}
+
+func (q *Queries) ListPodRestartHourly(ctx context.Context) ([]PodRestartHourly, error) {
+	const listPodRestartHourly = `select pod_uid, container_name, timestamp, restarts, oom_killed, errors, completed
+from pod_restart_hourly
+order by timestamp desc, container_name
+limit 100
+`
+	rows, err := q.query(ctx, listPodRestartHourly)
+	if err != nil {
+		return nil, err
+	}
+	data, err := pgx.CollectRows(rows, pgx.RowToStructByName[PodRestartHourly])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect pod restart rows: %w", err)
+	}
+	return data, nil
+}
+
+type PodCadvisorHourly struct {
+	PodUid                    pgtype.UUID        `db:"pod_uid"`
+	ClusterID                 int                `db:"cluster_id"`
+	Timestamp                 pgtype.Timestamptz `db:"timestamp"`
+	NetworkReceiveBytes       float64            `db:"network_receive_bytes"`
+	NetworkTransmitBytes      float64            `db:"network_transmit_bytes"`
+	CpuCfsPeriods             float64            `db:"cpu_cfs_periods"`
+	CpuCfsThrottledPeriods    float64            `db:"cpu_cfs_throttled_periods"`
+	FsUsageBytesMax           float64            `db:"fs_usage_bytes_max"`
+	FsUsageBytesMin           float64            `db:"fs_usage_bytes_min"`
+	FsUsageBytesTotal         float64            `db:"fs_usage_bytes_total"`
+	FsUsageBytesTotalReadings int32              `db:"fs_usage_bytes_total_readings"`
+	FsUsageBytesAvg           float64            `db:"fs_usage_bytes_avg"`
+}
+
+func (q *Queries) ListPodCadvisorHourly(ctx context.Context) ([]PodCadvisorHourly, error) {
+	const listPodCadvisorHourly = `select pod_uid, cluster_id, timestamp, network_receive_bytes, network_transmit_bytes, cpu_cfs_periods, cpu_cfs_throttled_periods, fs_usage_bytes_max, fs_usage_bytes_min, fs_usage_bytes_total, fs_usage_bytes_total_readings, fs_usage_bytes_avg
+from pod_cadvisor_hourly
+order by timestamp desc
+limit 100
+`
+	rows, err := q.query(ctx, listPodCadvisorHourly)
+	if err != nil {
+		return nil, err
+	}
+	data, err := pgx.CollectRows(rows, pgx.RowToStructByName[PodCadvisorHourly])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect pod cadvisor rows: %w", err)
+	}
+	return data, nil
+}
+
+type UpsertPodNetworkParams struct {
+	ClusterID            int                `db:"cluster_id"`
+	PodUid               pgtype.UUID        `db:"pod_uid"`
+	Timestamp            pgtype.Timestamptz `db:"timestamp"`
+	NetworkReceiveBytes  float64            `db:"network_receive_bytes"`
+	NetworkTransmitBytes float64            `db:"network_transmit_bytes"`
+}
+
+// UpsertPodNetwork adds bytes transferred since the previous scrape to the hourly totals
+func (q *Queries) UpsertPodNetwork(ctx context.Context, arg []UpsertPodNetworkParams) error {
+	for i := range arg {
+		arg[i].ClusterID = q.clusterID
+	}
+	const upsertPodNetwork = `
+insert into pod_cadvisor_hourly (pod_uid, cluster_id, timestamp, network_receive_bytes, network_transmit_bytes)
+values (@pod_uid, @cluster_id, @timestamp, @network_receive_bytes, @network_transmit_bytes)
+on conflict (pod_uid, timestamp)
+    do update set cluster_id             = @cluster_id,
+                  network_receive_bytes  = pod_cadvisor_hourly.network_receive_bytes + @network_receive_bytes,
+                  network_transmit_bytes = pod_cadvisor_hourly.network_transmit_bytes + @network_transmit_bytes
+`
+	return execBatch(ctx, q, upsertPodNetwork, arg)
+}
+
+type UpsertPodCPUThrottlingParams struct {
+	ClusterID              int                `db:"cluster_id"`
+	PodUid                 pgtype.UUID        `db:"pod_uid"`
+	Timestamp              pgtype.Timestamptz `db:"timestamp"`
+	CpuCfsPeriods          float64            `db:"cpu_cfs_periods"`
+	CpuCfsThrottledPeriods float64            `db:"cpu_cfs_throttled_periods"`
+}
+
+// UpsertPodCPUThrottling adds CFS periods elapsed since the previous scrape to the hourly totals
+func (q *Queries) UpsertPodCPUThrottling(ctx context.Context, arg []UpsertPodCPUThrottlingParams) error {
+	for i := range arg {
+		arg[i].ClusterID = q.clusterID
+	}
+	const upsertPodCPUThrottling = `
+insert into pod_cadvisor_hourly (pod_uid, cluster_id, timestamp, cpu_cfs_periods, cpu_cfs_throttled_periods)
+values (@pod_uid, @cluster_id, @timestamp, @cpu_cfs_periods, @cpu_cfs_throttled_periods)
+on conflict (pod_uid, timestamp)
+    do update set cluster_id                = @cluster_id,
+                  cpu_cfs_periods           = pod_cadvisor_hourly.cpu_cfs_periods + @cpu_cfs_periods,
+                  cpu_cfs_throttled_periods = pod_cadvisor_hourly.cpu_cfs_throttled_periods + @cpu_cfs_throttled_periods
+`
+	return execBatch(ctx, q, upsertPodCPUThrottling, arg)
+}
+
+type UpsertPodFsUsageParams struct {
+	ClusterID    int                `db:"cluster_id"`
+	PodUid       pgtype.UUID        `db:"pod_uid"`
+	Timestamp    pgtype.Timestamptz `db:"timestamp"`
+	FsUsageBytes float64            `db:"fs_usage_bytes"`
+}
+
+func (q *Queries) UpsertPodFsUsage(ctx context.Context, arg []UpsertPodFsUsageParams) error {
+	for i := range arg {
+		arg[i].ClusterID = q.clusterID
+	}
+	const upsertPodFsUsage = `
+insert into pod_cadvisor_hourly (pod_uid, cluster_id, timestamp, fs_usage_bytes_max, fs_usage_bytes_min,
+                                 fs_usage_bytes_total, fs_usage_bytes_total_readings)
+values (@pod_uid, @cluster_id, @timestamp, @fs_usage_bytes, @fs_usage_bytes, @fs_usage_bytes, 1)
+on conflict (pod_uid, timestamp)
+    do update set cluster_id                    = @cluster_id,
+                  fs_usage_bytes_total_readings = pod_cadvisor_hourly.fs_usage_bytes_total_readings + 1,
+                  fs_usage_bytes_max            = case
+                                                      when pod_cadvisor_hourly.fs_usage_bytes_max > @fs_usage_bytes
+                                                          then pod_cadvisor_hourly.fs_usage_bytes_max
+                                                      else @fs_usage_bytes end,
+                  fs_usage_bytes_min            = case
+                                                      when @fs_usage_bytes = 0
+                                                          then pod_cadvisor_hourly.fs_usage_bytes_min
+                                                      when pod_cadvisor_hourly.fs_usage_bytes_min < @fs_usage_bytes and
+                                                           pod_cadvisor_hourly.fs_usage_bytes_min != 0
+                                                          then pod_cadvisor_hourly.fs_usage_bytes_min
+                                                      else @fs_usage_bytes end,
+                  fs_usage_bytes_total          = pod_cadvisor_hourly.fs_usage_bytes_total + @fs_usage_bytes
+`
+	return execBatch(ctx, q, upsertPodFsUsage, arg)
+}
+
+type PVCUsageHourly struct {
+	PvcUid                 pgtype.UUID        `db:"pvc_uid"`
+	ClusterID              int                `db:"cluster_id"`
+	Timestamp              pgtype.Timestamptz `db:"timestamp"`
+	UsedBytesMax           float64            `db:"used_bytes_max"`
+	UsedBytesMin           float64            `db:"used_bytes_min"`
+	UsedBytesTotal         float64            `db:"used_bytes_total"`
+	UsedBytesTotalReadings int32              `db:"used_bytes_total_readings"`
+	UsedBytesAvg           float64            `db:"used_bytes_avg"`
+	CapacityBytes          float64            `db:"capacity_bytes"`
+}
+
+func (q *Queries) ListPVCUsageHourly(ctx context.Context) ([]PVCUsageHourly, error) {
+	const listPVCUsageHourly = `select pvc_uid, cluster_id, timestamp, used_bytes_max, used_bytes_min, used_bytes_total, used_bytes_total_readings, used_bytes_avg, capacity_bytes
+from pvc_usage_hourly
+order by timestamp desc
+limit 100
+`
+	rows, err := q.query(ctx, listPVCUsageHourly)
+	if err != nil {
+		return nil, err
+	}
+	data, err := pgx.CollectRows(rows, pgx.RowToStructByName[PVCUsageHourly])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect pvc usage rows: %w", err)
+	}
+	return data, nil
+}
+
+type UpsertPVCUsageParams struct {
+	ClusterID     int                `db:"cluster_id"`
+	PvcUid        pgtype.UUID        `db:"pvc_uid"`
+	Timestamp     pgtype.Timestamptz `db:"timestamp"`
+	UsedBytes     float64            `db:"used_bytes"`
+	CapacityBytes float64            `db:"capacity_bytes"`
+}
+
+func (q *Queries) UpsertPVCUsage(ctx context.Context, arg []UpsertPVCUsageParams) error {
+	for i := range arg {
+		arg[i].ClusterID = q.clusterID
+	}
+	const upsertPVCUsage = `
+insert into pvc_usage_hourly (pvc_uid, cluster_id, timestamp, used_bytes_max, used_bytes_min, used_bytes_total,
+                              used_bytes_total_readings, capacity_bytes)
+values (@pvc_uid, @cluster_id, @timestamp, @used_bytes, @used_bytes, @used_bytes, 1, @capacity_bytes)
+on conflict (pvc_uid, timestamp)
+    do update set cluster_id                = @cluster_id,
+                  used_bytes_total_readings = pvc_usage_hourly.used_bytes_total_readings + 1,
+                  used_bytes_max            = case
+                                                  when pvc_usage_hourly.used_bytes_max > @used_bytes
+                                                      then pvc_usage_hourly.used_bytes_max
+                                                  else @used_bytes end,
+                  used_bytes_min            = case
+                                                  when @used_bytes = 0
+                                                      then pvc_usage_hourly.used_bytes_min
+                                                  when pvc_usage_hourly.used_bytes_min < @used_bytes and
+                                                       pvc_usage_hourly.used_bytes_min != 0
+                                                      then pvc_usage_hourly.used_bytes_min
+                                                  else @used_bytes end,
+                  used_bytes_total          = pvc_usage_hourly.used_bytes_total + @used_bytes,
+                  capacity_bytes            = @capacity_bytes
+`
+	return execBatch(ctx, q, upsertPVCUsage, arg)
+}
+
+type PodEphemeralStorageHourly struct {
+	PodUid                 pgtype.UUID        `db:"pod_uid"`
+	ClusterID              int                `db:"cluster_id"`
+	Timestamp              pgtype.Timestamptz `db:"timestamp"`
+	UsedBytesMax           float64            `db:"used_bytes_max"`
+	UsedBytesMin           float64            `db:"used_bytes_min"`
+	UsedBytesTotal         float64            `db:"used_bytes_total"`
+	UsedBytesTotalReadings int32              `db:"used_bytes_total_readings"`
+	UsedBytesAvg           float64            `db:"used_bytes_avg"`
+}
+
+func (q *Queries) ListPodEphemeralStorageHourly(ctx context.Context) ([]PodEphemeralStorageHourly, error) {
+	const listPodEphemeralStorageHourly = `select pod_uid, cluster_id, timestamp, used_bytes_max, used_bytes_min, used_bytes_total, used_bytes_total_readings, used_bytes_avg
+from pod_ephemeral_storage_hourly
+order by timestamp desc
+limit 100
+`
+	rows, err := q.query(ctx, listPodEphemeralStorageHourly)
+	if err != nil {
+		return nil, err
+	}
+	data, err := pgx.CollectRows(rows, pgx.RowToStructByName[PodEphemeralStorageHourly])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect pod ephemeral storage rows: %w", err)
+	}
+	return data, nil
+}
+
+type UpsertPodEphemeralStorageParams struct {
+	ClusterID int                `db:"cluster_id"`
+	PodUid    pgtype.UUID        `db:"pod_uid"`
+	Timestamp pgtype.Timestamptz `db:"timestamp"`
+	UsedBytes float64            `db:"used_bytes"`
+}
+
+// UpsertPodEphemeralStorage records ephemeral storage used by pods: container writable layers, logs and emptyDir volumes
+func (q *Queries) UpsertPodEphemeralStorage(ctx context.Context, arg []UpsertPodEphemeralStorageParams) error {
+	for i := range arg {
+		arg[i].ClusterID = q.clusterID
+	}
+	const upsertPodEphemeralStorage = `
+insert into pod_ephemeral_storage_hourly (pod_uid, cluster_id, timestamp, used_bytes_max, used_bytes_min,
+                                          used_bytes_total, used_bytes_total_readings)
+values (@pod_uid, @cluster_id, @timestamp, @used_bytes, @used_bytes, @used_bytes, 1)
+on conflict (pod_uid, timestamp)
+    do update set cluster_id                = @cluster_id,
+                  used_bytes_total_readings = pod_ephemeral_storage_hourly.used_bytes_total_readings + 1,
+                  used_bytes_max            = case
+                                                  when pod_ephemeral_storage_hourly.used_bytes_max > @used_bytes
+                                                      then pod_ephemeral_storage_hourly.used_bytes_max
+                                                  else @used_bytes end,
+                  used_bytes_min            = case
+                                                  when @used_bytes = 0
+                                                      then pod_ephemeral_storage_hourly.used_bytes_min
+                                                  when pod_ephemeral_storage_hourly.used_bytes_min < @used_bytes and
+                                                       pod_ephemeral_storage_hourly.used_bytes_min != 0
+                                                      then pod_ephemeral_storage_hourly.used_bytes_min
+                                                  else @used_bytes end,
+                  used_bytes_total          = pod_ephemeral_storage_hourly.used_bytes_total + @used_bytes
+`
+	return execBatch(ctx, q, upsertPodEphemeralStorage, arg)
+}
+
 func (q *Queries) GetClusterID(ctx context.Context, name string) (int, error) {
 	const getClusterID = `select id from cluster where name = $1`
 	var id int
@@ -2,6 +2,7 @@ package queries
 
 import (
 	"context"
+	"encoding/json"
 	"log/slog"
 	"os"
 	"testing"
@@ -13,7 +14,9 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/term"
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/uuid"
@@ -79,6 +82,38 @@ func TestUpsertPod(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestUpsertEvent(t *testing.T) {
+	queries := NewTestQueries(t)
+	first := time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC)
+	params := UpsertEventParams{
+		Uid:            NewUUID(),
+		Namespace:      "default",
+		Name:           "app.17a",
+		InvolvedKind:   "Pod",
+		InvolvedUid:    string(NewKUUID()),
+		InvolvedName:   "app",
+		Type:           "Warning",
+		Reason:         "Evicted",
+		Count:          1,
+		FirstTimestamp: pgtype.Timestamptz{Time: first, Valid: true},
+		LastTimestamp:  pgtype.Timestamptz{Time: first, Valid: true},
+	}
+	require.NoError(t, queries.UpsertEvent(context.TODO(), params))
+	repeated := params
+	repeated.Count = 3
+	repeated.LastTimestamp = pgtype.Timestamptz{Time: first.Add(time.Hour), Valid: true}
+	require.NoError(t, queries.UpsertEvent(context.TODO(), repeated))
+	// stale update
+	require.NoError(t, queries.UpsertEvent(context.TODO(), params))
+
+	events, err := queries.ListEvents(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, int32(3), events[0].Count)
+	assert.True(t, events[0].FirstTimestamp.Time.Equal(first))
+	assert.True(t, events[0].LastTimestamp.Time.Equal(first.Add(time.Hour)))
+}
+
 func TestUpsertPodUsedCPU(t *testing.T) {
 	queries := NewTestQueries(t)
 
@@ -129,6 +164,423 @@ func TestUpsertPodUsedMemory(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestInsertPodUsageHourly(t *testing.T) {
+	queries := NewTestQueries(t)
+	podUID := NewUUID()
+	timestamp := pgtype.Timestamptz{Time: time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC), Valid: true}
+	nextHour := pgtype.Timestamptz{Time: timestamp.Time.Add(time.Hour), Valid: true}
+
+	require.NoError(t, queries.UpsertPodUsedCPU(context.TODO(), []UpsertPodUsedCPUParams{
+		{PodUid: podUID, Timestamp: timestamp, CpuCores: 2},
+	}))
+	params := func() []InsertPodUsageHourlyParams {
+		row := func(timestamp pgtype.Timestamptz) InsertPodUsageHourlyParams {
+			return InsertPodUsageHourlyParams{
+				PodUid:                   podUID,
+				Timestamp:                timestamp,
+				MemoryBytesMax:           300,
+				MemoryBytesMin:           100,
+				MemoryBytesTotal:         400,
+				MemoryBytesTotalReadings: 2,
+				CpuCoresMax:              4,
+				CpuCoresMin:              1,
+				CpuCoresTotal:            5,
+				CpuCoresTotalReadings:    2,
+			}
+		}
+		return []InsertPodUsageHourlyParams{row(timestamp), row(nextHour)}
+	}
+	require.NoError(t, queries.InsertPodUsageHourly(context.TODO(), params()))
+	// the same import again doesn't count readings twice
+	require.NoError(t, queries.InsertPodUsageHourly(context.TODO(), params()))
+
+	usage, err := queries.ListPodUsageHourly(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, usage, 2)
+	// ordered by timestamp desc
+	assert.Equal(t, int32(2), usage[0].CpuCoresTotalReadings)
+	assert.InDelta(t, 2.5, usage[0].CpuCoresAvg, 0.0001)
+	assert.InDelta(t, 200.0, usage[0].MemoryBytesAvg, 0.0001)
+	// the hour which already had usage is kept as is
+	assert.Equal(t, int32(1), usage[1].CpuCoresTotalReadings)
+	assert.InDelta(t, 2.0, usage[1].CpuCoresAvg, 0.0001)
+	assert.Equal(t, int32(0), usage[1].MemoryBytesTotalReadings)
+}
+
+func TestMergePodUsageSamples(t *testing.T) {
+	queries := NewTestQueries(t)
+	podUID := NewUUID()
+	timestamp := pgtype.Timestamptz{Time: time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC), Valid: true}
+
+	require.NoError(t, queries.UpsertPodUsedMemory(context.TODO(), []UpsertPodUsedMemoryParams{
+		{PodUid: podUID, Timestamp: timestamp, MemoryBytes: 300},
+	}))
+	require.NoError(t, queries.MergePodUsageSamples(context.TODO(), []PodUsageSample{
+		{PodUid: podUID, Timestamp: timestamp, CpuCores: pgtype.Float8{Float64: 1, Valid: true}},
+		{PodUid: podUID, Timestamp: timestamp, CpuCores: pgtype.Float8{Float64: 2, Valid: true}},
+		{PodUid: podUID, Timestamp: timestamp, MemoryBytes: pgtype.Float8{Float64: 100, Valid: true}},
+	}))
+	// the staging table is reused by the next merge
+	require.NoError(t, queries.MergePodUsageSamples(context.TODO(), []PodUsageSample{
+		{PodUid: podUID, Timestamp: timestamp, CpuCores: pgtype.Float8{Float64: 3, Valid: true}},
+	}))
+
+	usage, err := queries.ListPodUsageHourly(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, usage, 1)
+	assert.Equal(t, int32(3), usage[0].CpuCoresTotalReadings)
+	assert.InDelta(t, 2.0, usage[0].CpuCoresAvg, 0.0001)
+	assert.InDelta(t, 1.0, usage[0].CpuCoresMin, 0.0001)
+	assert.Equal(t, int32(2), usage[0].MemoryBytesTotalReadings)
+	assert.InDelta(t, 100.0, usage[0].MemoryBytesMin, 0.0001)
+	assert.InDelta(t, 300.0, usage[0].MemoryBytesMax, 0.0001)
+}
+
+func TestPodUsageMinIgnoresZeroReadings(t *testing.T) {
+	queries := NewTestQueries(t)
+	upsertedUID, mergedUID := NewUUID(), NewUUID()
+	timestamp := pgtype.Timestamptz{Time: time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC), Valid: true}
+
+	for _, cores := range []float64{5, 0} {
+		require.NoError(t, queries.UpsertPodUsedCPU(context.TODO(), []UpsertPodUsedCPUParams{
+			{PodUid: upsertedUID, Timestamp: timestamp, CpuCores: cores},
+		}))
+	}
+	require.NoError(t, queries.MergePodUsageSamples(context.TODO(), []PodUsageSample{
+		{PodUid: mergedUID, Timestamp: timestamp, CpuCores: pgtype.Float8{Float64: 5, Valid: true}},
+		{PodUid: mergedUID, Timestamp: timestamp, CpuCores: pgtype.Float8{Float64: 0, Valid: true}},
+	}))
+	// a merge of zero readings only doesn't lower the minimum either
+	require.NoError(t, queries.MergePodUsageSamples(context.TODO(), []PodUsageSample{
+		{PodUid: mergedUID, Timestamp: timestamp, CpuCores: pgtype.Float8{Float64: 0, Valid: true}},
+	}))
+
+	usage, err := queries.ListPodUsageHourly(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, usage, 2)
+	for _, row := range usage {
+		assert.InDelta(t, 5.0, row.CpuCoresMin, 0.0001)
+		assert.InDelta(t, 5.0, row.CpuCoresMax, 0.0001)
+	}
+}
+
+func TestRollupPodUsageRaw(t *testing.T) {
+	queries := NewTestQueries(t)
+	podUID := NewUUID()
+	hour := time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) pgtype.Timestamptz {
+		return pgtype.Timestamptz{Time: hour.Add(d), Valid: true}
+	}
+
+	// e.g. written by backfill before raw samples were enabled
+	require.NoError(t, queries.UpsertPodUsedCPU(context.TODO(), []UpsertPodUsedCPUParams{
+		{PodUid: podUID, Timestamp: at(0), CpuCores: 2},
+	}))
+	require.NoError(t, queries.InsertPodUsageRaw(context.TODO(), []PodUsageSample{
+		{PodUid: podUID, Timestamp: at(time.Minute), CpuCores: pgtype.Float8{Float64: 1, Valid: true}},
+		{PodUid: podUID, Timestamp: at(2 * time.Minute), CpuCores: pgtype.Float8{Float64: 3, Valid: true}},
+		{PodUid: podUID, Timestamp: at(time.Minute), MemoryBytes: pgtype.Float8{Float64: 100, Valid: true}},
+		{PodUid: podUID, Timestamp: at(time.Hour), CpuCores: pgtype.Float8{Float64: 5, Valid: true}},
+	}))
+
+	rows, err := queries.RollupPodUsageRaw(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), rows)
+	// samples are only rolled up once
+	rows, err = queries.RollupPodUsageRaw(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), rows)
+
+	usage, err := queries.ListPodUsageHourly(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, usage, 2)
+	// ordered by timestamp desc
+	assert.Equal(t, int32(1), usage[0].CpuCoresTotalReadings)
+	assert.Equal(t, int32(3), usage[1].CpuCoresTotalReadings)
+	assert.InDelta(t, 2.0, usage[1].CpuCoresAvg, 0.0001)
+	assert.InDelta(t, 1.0, usage[1].CpuCoresMin, 0.0001)
+	assert.InDelta(t, 3.0, usage[1].CpuCoresMax, 0.0001)
+	assert.Equal(t, int32(1), usage[1].MemoryBytesTotalReadings)
+	assert.InDelta(t, 100.0, usage[1].MemoryBytesMax, 0.0001)
+
+	// samples which aren't rolled up yet are kept
+	require.NoError(t, queries.InsertPodUsageRaw(context.TODO(), []PodUsageSample{
+		{PodUid: podUID, Timestamp: at(3 * time.Minute), CpuCores: pgtype.Float8{Float64: 1, Valid: true}},
+	}))
+	deleted, err := queries.DeletePodUsageRaw(context.TODO(), hour.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+}
+
+func TestUpsertObjectData_PodRequests(t *testing.T) {
+	queries := NewTestQueries(t)
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{UID: NewKUUID()},
+		Spec: v1.PodSpec{Containers: []v1.Container{{
+			Name:      "app",
+			Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")}},
+		}}},
+	}
+	podRequestCPU := func() float64 {
+		var cpu float64
+		require.NoError(t, queries.db.QueryRow(context.TODO(), "select request_cpu_cores from pod where uid = $1", string(pod.UID)).Scan(&cpu))
+		return cpu
+	}
+	// requests are computed by the scraper, the spec isn't parsed by the view
+	require.NoError(t, queries.UpsertObject(context.TODO(), "Pod", pod))
+	assert.InDelta(t, 0.0, podRequestCPU(), 0.0001)
+
+	data, err := json.Marshal(pod)
+	require.NoError(t, err)
+	require.NoError(t, queries.UpsertObjectData(context.TODO(), "Pod", pod, data, PodColumns{
+		RequestCpuCores:    pgtype.Float8{Float64: 2.5, Valid: true},
+		RequestMemoryBytes: pgtype.Float8{Float64: 100, Valid: true},
+	}))
+	assert.InDelta(t, 2.5, podRequestCPU(), 0.0001)
+}
+
+func TestUpsertObjectData_PodLimits(t *testing.T) {
+	queries := NewTestQueries(t)
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{UID: NewKUUID()},
+		Spec: v1.PodSpec{Containers: []v1.Container{{
+			Name:      "app",
+			Resources: v1.ResourceRequirements{Limits: v1.ResourceList{v1.ResourceMemory: resource.MustParse("1Gi")}},
+		}}},
+	}
+	podLimits := func() (cpu float64, memory float64) {
+		require.NoError(t, queries.db.QueryRow(context.TODO(), "select limit_cpu_cores, limit_memory_bytes from pod where uid = $1", string(pod.UID)).Scan(&cpu, &memory))
+		return cpu, memory
+	}
+	// limits are computed by the scraper, the spec isn't parsed by the view
+	require.NoError(t, queries.UpsertObject(context.TODO(), "Pod", pod))
+	cpu, memory := podLimits()
+	assert.InDelta(t, 0.0, cpu, 0.0001)
+	assert.InDelta(t, 0.0, memory, 0.0001)
+
+	data, err := json.Marshal(pod)
+	require.NoError(t, err)
+	require.NoError(t, queries.UpsertObjectData(context.TODO(), "Pod", pod, data, PodColumns{
+		LimitCpuCores:    pgtype.Float8{Float64: 2, Valid: true},
+		LimitMemoryBytes: pgtype.Float8{Float64: 100, Valid: true},
+	}))
+	cpu, memory = podLimits()
+	assert.InDelta(t, 2.0, cpu, 0.0001)
+	assert.InDelta(t, 100.0, memory, 0.0001)
+}
+
+func TestObjectController(t *testing.T) {
+	queries := NewTestQueries(t)
+	controller := true
+	ownedBy := func(kind string, owner metav1.ObjectMeta) []metav1.OwnerReference {
+		return []metav1.OwnerReference{{Kind: kind, Name: owner.Name, UID: owner.UID, Controller: &controller}}
+	}
+	// the operator resource isn't stored, the chain ends with its reference
+	cluster := metav1.ObjectMeta{Name: "db", UID: NewKUUID()}
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "db", UID: NewKUUID(), OwnerReferences: ownedBy("Cluster", cluster)}}
+	replicaSet := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "db-7d4b9", UID: NewKUUID(), OwnerReferences: ownedBy("Deployment", deployment.ObjectMeta)}}
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "db-7d4b9-x2k4p", UID: NewKUUID(), OwnerReferences: ownedBy("ReplicaSet", replicaSet.ObjectMeta)}}
+	// owners stored after the pod update its chain
+	require.NoError(t, queries.UpsertObject(context.TODO(), "Pod", pod))
+	require.NoError(t, queries.UpsertObject(context.TODO(), "ReplicaSet", replicaSet))
+	require.NoError(t, queries.UpsertObject(context.TODO(), "Deployment", deployment))
+
+	var controllerKind, controllerName, directOwnerKind, directOwnerName string
+	err := queries.db.QueryRow(context.TODO(), `
+select controller_kind, controller_name, direct_owner_kind, direct_owner_name
+from object_controller
+where uid = $1`, string(pod.UID)).Scan(&controllerKind, &controllerName, &directOwnerKind, &directOwnerName)
+	require.NoError(t, err)
+	assert.Equal(t, "Cluster", controllerKind)
+	assert.Equal(t, "db", controllerName)
+	assert.Equal(t, "ReplicaSet", directOwnerKind)
+	assert.Equal(t, "db-7d4b9", directOwnerName)
+}
+
+func TestUpsertPodRequests(t *testing.T) {
+	queries := NewTestQueries(t)
+	uid := NewKUUID()
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{UID: uid},
+		Spec: v1.PodSpec{Containers: []v1.Container{{
+			Name:      "app",
+			Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")}},
+		}}},
+	}
+	data, err := json.Marshal(pod)
+	require.NoError(t, err)
+	require.NoError(t, queries.UpsertObjectData(context.TODO(), "Pod", pod, data, PodColumns{
+		RequestCpuCores:    pgtype.Float8{Float64: 1, Valid: true},
+		RequestMemoryBytes: pgtype.Float8{Float64: 0, Valid: true},
+	}))
+	podUID, err := parsePGUUID(uid)
+	require.NoError(t, err)
+	hour := time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) pgtype.Timestamptz {
+		return pgtype.Timestamptz{Time: hour.Add(d), Valid: true}
+	}
+	require.NoError(t, queries.UpsertPodUsedCPU(context.TODO(), []UpsertPodUsedCPUParams{
+		{PodUid: podUID, Timestamp: at(0), CpuCores: 1},
+		{PodUid: podUID, Timestamp: at(time.Hour), CpuCores: 1},
+	}))
+	// the pod was resized during the first hour
+	require.NoError(t, queries.UpsertPodRequests(context.TODO(), []UpsertPodRequestParams{
+		{PodUid: podUID, Timestamp: at(0), CpuCores: 2, MemoryBytes: 100},
+		{PodUid: podUID, Timestamp: at(0), CpuCores: 4, MemoryBytes: 300},
+	}))
+
+	requests, err := queries.ListPodRequestHourly(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, requests, 1)
+	assert.Equal(t, int32(2), requests[0].Readings)
+	assert.InDelta(t, 3.0, requests[0].CpuCoresAvg, 0.0001)
+	assert.InDelta(t, 200.0, requests[0].MemoryBytesAvg, 0.0001)
+
+	var cpu float64
+	err = queries.db.QueryRow(context.TODO(), "select request_cpu_cores from pod_usage_request_hourly where uid = $1 and timestamp = $2", podUID, hour).Scan(&cpu)
+	require.NoError(t, err)
+	assert.InDelta(t, 3.0, cpu, 0.0001)
+	// hours without readings use the requests stored with the pod
+	err = queries.db.QueryRow(context.TODO(), "select request_cpu_cores from pod_usage_request_hourly where uid = $1 and timestamp = $2", podUID, hour.Add(time.Hour)).Scan(&cpu)
+	require.NoError(t, err)
+	assert.InDelta(t, 1.0, cpu, 0.0001)
+}
+
+func TestUpsertPodContainerStatus(t *testing.T) {
+	queries := NewTestQueries(t)
+	podUID := NewUUID()
+	terminated := time.Date(2023, 12, 1, 10, 30, 0, 0, time.UTC)
+	status := func(restarts int32, terminatedAt time.Time, reason string) UpsertPodContainerStatusParams {
+		return UpsertPodContainerStatusParams{
+			PodUid:        podUID,
+			ContainerName: "app",
+			RestartCount:  restarts,
+			TerminatedAt:  pgtype.Timestamptz{Time: terminatedAt, Valid: !terminatedAt.IsZero()},
+			Reason:        reason,
+		}
+	}
+
+	require.NoError(t, queries.UpsertPodContainerStatus(context.TODO(), []UpsertPodContainerStatusParams{status(0, time.Time{}, "")}))
+	rows, err := queries.ListPodRestartHourly(context.TODO())
+	require.NoError(t, err)
+	assert.Empty(t, rows)
+
+	require.NoError(t, queries.UpsertPodContainerStatus(context.TODO(), []UpsertPodContainerStatusParams{status(1, terminated, "OOMKilled")}))
+	// repeated updates of the same status don't count again
+	require.NoError(t, queries.UpsertPodContainerStatus(context.TODO(), []UpsertPodContainerStatusParams{status(1, terminated, "OOMKilled")}))
+	require.NoError(t, queries.UpsertPodContainerStatus(context.TODO(), []UpsertPodContainerStatusParams{status(3, terminated.Add(10*time.Minute), "Error")}))
+
+	rows, err = queries.ListPodRestartHourly(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.True(t, rows[0].Timestamp.Time.Equal(time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC)))
+	assert.Equal(t, int32(3), rows[0].Restarts)
+	assert.Equal(t, int32(1), rows[0].OomKilled)
+	assert.Equal(t, int32(1), rows[0].Errors)
+}
+
+func TestUpsertContainerUsage(t *testing.T) {
+	queries := NewTestQueries(t)
+	podUID := NewUUID()
+	timestamp := pgtype.Timestamptz{Time: time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC), Valid: true}
+
+	cpuParams := []UpsertContainerUsedCPUParams{
+		{PodUid: podUID, ContainerName: "app", Timestamp: timestamp, CpuCores: 1.0},
+		{PodUid: podUID, ContainerName: "sidecar", Timestamp: timestamp, CpuCores: 0.5},
+	}
+	require.NoError(t, queries.UpsertContainerUsedCPU(context.TODO(), cpuParams))
+	cpuParams[0].CpuCores = 3.0
+	require.NoError(t, queries.UpsertContainerUsedCPU(context.TODO(), cpuParams[:1]))
+
+	memoryParams := []UpsertContainerUsedMemoryParams{
+		{PodUid: podUID, ContainerName: "app", Timestamp: timestamp, MemoryBytes: 1024.0},
+	}
+	require.NoError(t, queries.UpsertContainerUsedMemory(context.TODO(), memoryParams))
+
+	usage, err := queries.ListContainerUsageHourly(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, usage, 2)
+	assert.Equal(t, "app", usage[0].ContainerName)
+	assert.InDelta(t, 1.0, usage[0].CpuCoresMin, 0.0001)
+	assert.InDelta(t, 3.0, usage[0].CpuCoresMax, 0.0001)
+	assert.InDelta(t, 2.0, usage[0].CpuCoresAvg, 0.0001)
+	assert.InDelta(t, 1024.0, usage[0].MemoryBytesAvg, 0.0001)
+	assert.Equal(t, "sidecar", usage[1].ContainerName)
+	assert.InDelta(t, 0.5, usage[1].CpuCoresAvg, 0.0001)
+}
+
+func TestUpsertPodCadvisor(t *testing.T) {
+	queries := NewTestQueries(t)
+	podUID := NewUUID()
+	timestamp := pgtype.Timestamptz{Time: time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC), Valid: true}
+
+	network := []UpsertPodNetworkParams{
+		{PodUid: podUID, Timestamp: timestamp, NetworkReceiveBytes: 100, NetworkTransmitBytes: 10},
+	}
+	require.NoError(t, queries.UpsertPodNetwork(context.TODO(), network))
+	require.NoError(t, queries.UpsertPodNetwork(context.TODO(), network))
+
+	throttling := []UpsertPodCPUThrottlingParams{
+		{PodUid: podUID, Timestamp: timestamp, CpuCfsPeriods: 600, CpuCfsThrottledPeriods: 60},
+	}
+	require.NoError(t, queries.UpsertPodCPUThrottling(context.TODO(), throttling))
+
+	for _, bytes := range []float64{1000, 3000} {
+		fs := []UpsertPodFsUsageParams{{PodUid: podUID, Timestamp: timestamp, FsUsageBytes: bytes}}
+		require.NoError(t, queries.UpsertPodFsUsage(context.TODO(), fs))
+	}
+
+	usage, err := queries.ListPodCadvisorHourly(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, usage, 1)
+	assert.InDelta(t, 200.0, usage[0].NetworkReceiveBytes, 0.0001)
+	assert.InDelta(t, 20.0, usage[0].NetworkTransmitBytes, 0.0001)
+	assert.InDelta(t, 600.0, usage[0].CpuCfsPeriods, 0.0001)
+	assert.InDelta(t, 60.0, usage[0].CpuCfsThrottledPeriods, 0.0001)
+	assert.InDelta(t, 1000.0, usage[0].FsUsageBytesMin, 0.0001)
+	assert.InDelta(t, 3000.0, usage[0].FsUsageBytesMax, 0.0001)
+	assert.InDelta(t, 2000.0, usage[0].FsUsageBytesAvg, 0.0001)
+}
+
+func TestUpsertPVCUsage(t *testing.T) {
+	queries := NewTestQueries(t)
+	pvcUID := NewUUID()
+	timestamp := pgtype.Timestamptz{Time: time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC), Valid: true}
+
+	for _, used := range []float64{100, 300} {
+		params := []UpsertPVCUsageParams{{PvcUid: pvcUID, Timestamp: timestamp, UsedBytes: used, CapacityBytes: 1000}}
+		require.NoError(t, queries.UpsertPVCUsage(context.TODO(), params))
+	}
+
+	usage, err := queries.ListPVCUsageHourly(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, usage, 1)
+	assert.Equal(t, pvcUID, usage[0].PvcUid)
+	assert.InDelta(t, 100.0, usage[0].UsedBytesMin, 0.0001)
+	assert.InDelta(t, 300.0, usage[0].UsedBytesMax, 0.0001)
+	assert.InDelta(t, 200.0, usage[0].UsedBytesAvg, 0.0001)
+	assert.InDelta(t, 1000.0, usage[0].CapacityBytes, 0.0001)
+}
+
+func TestUpsertPodEphemeralStorage(t *testing.T) {
+	queries := NewTestQueries(t)
+	podUID := NewUUID()
+	timestamp := pgtype.Timestamptz{Time: time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC), Valid: true}
+
+	for _, used := range []float64{100, 300} {
+		params := []UpsertPodEphemeralStorageParams{{PodUid: podUID, Timestamp: timestamp, UsedBytes: used}}
+		require.NoError(t, queries.UpsertPodEphemeralStorage(context.TODO(), params))
+	}
+
+	usage, err := queries.ListPodEphemeralStorageHourly(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, usage, 1)
+	assert.Equal(t, podUID, usage[0].PodUid)
+	assert.InDelta(t, 100.0, usage[0].UsedBytesMin, 0.0001)
+	assert.InDelta(t, 300.0, usage[0].UsedBytesMax, 0.0001)
+	assert.InDelta(t, 200.0, usage[0].UsedBytesAvg, 0.0001)
+}
+
 func TestListPodUsageHourly(t *testing.T) {
 	_, err := NewTestQueries(t).ListPodUsageHourly(context.TODO())
 	require.NoError(t, err)
@@ -0,0 +1,188 @@
+package queries
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type RetentionParams struct {
+	// HourlyBefore is the start of the first day kept at hourly resolution, older days are rolled up into pod_usage_daily
+	// zero keeps all hourly data
+	HourlyBefore time.Time
+	// DailyBefore deletes older pod_usage_daily rows, zero keeps all daily data
+	DailyBefore time.Time
+	// ObjectsBefore purges objects deleted before it which aren't referenced by any usage row, zero keeps all objects
+	ObjectsBefore time.Time
+	// DryRun rolls back all changes, the result reports what would be removed
+	DryRun bool
+}
+
+type RetentionResult struct {
+	RolledUpDailyRows           int64 `json:"rolledUpDailyRows"`
+	DeletedPodUsageHourly       int64 `json:"deletedPodUsageHourly"`
+	DeletedContainerUsageHourly int64 `json:"deletedContainerUsageHourly"`
+	DeletedPodCadvisorHourly    int64 `json:"deletedPodCadvisorHourly"`
+	DeletedPVCUsageHourly       int64 `json:"deletedPvcUsageHourly"`
+	DeletedPodEphemeralStorage  int64 `json:"deletedPodEphemeralStorage"`
+	DeletedEvents               int64 `json:"deletedEvents"`
+	DeletedPodRequestHourly     int64 `json:"deletedPodRequestHourly"`
+	DeletedPodRestartHourly     int64 `json:"deletedPodRestartHourly"`
+	DeletedPodContainerStates   int64 `json:"deletedPodContainerStates"`
+	DeletedPodUsageDaily        int64 `json:"deletedPodUsageDaily"`
+	DeletedObjects              int64 `json:"deletedObjects"`
+	DeletedObjectRevisions      int64 `json:"deletedObjectRevisions"`
+	DeletedExtendedResources    int64 `json:"deletedExtendedResources"`
+}
+
+const rollupPodUsageDaily = `
+insert into pod_usage_daily (pod_uid, cluster_id, timestamp, memory_bytes_max, memory_bytes_min, memory_bytes_total,
+                             memory_bytes_total_readings, cpu_cores_max, cpu_cores_min, cpu_cores_total,
+                             cpu_cores_total_readings, hours, request_cpu_core_hours, request_memory_byte_hours,
+                             used_ephemeral_byte_hours)
+select pod_usage_hourly.pod_uid,
+       pod_usage_hourly.cluster_id,
+       date_trunc('day', pod_usage_hourly.timestamp),
+       max(pod_usage_hourly.memory_bytes_max),
+       coalesce(min(nullif(pod_usage_hourly.memory_bytes_min, 0)), 0),
+       sum(pod_usage_hourly.memory_bytes_total),
+       sum(pod_usage_hourly.memory_bytes_total_readings),
+       max(pod_usage_hourly.cpu_cores_max),
+       coalesce(min(nullif(pod_usage_hourly.cpu_cores_min, 0)), 0),
+       sum(pod_usage_hourly.cpu_cores_total),
+       sum(pod_usage_hourly.cpu_cores_total_readings),
+       coalesce(sum(running.hours), 0),
+       sum(coalesce(pod_request_hourly.cpu_cores_avg, pod.request_cpu_cores) * running.hours),
+       sum(coalesce(pod_request_hourly.memory_bytes_avg, pod.request_memory_bytes) * running.hours),
+       coalesce(sum(pod_ephemeral_storage_hourly.used_bytes_avg * running.hours), 0)
+from pod_usage_hourly
+         left join pod on (pod_usage_hourly.pod_uid = pod.uid)
+         left join pod_request_hourly on (pod_request_hourly.pod_uid = pod_usage_hourly.pod_uid and
+                                          pod_request_hourly.timestamp = pod_usage_hourly.timestamp)
+         left join pod_ephemeral_storage_hourly on (pod_ephemeral_storage_hourly.pod_uid = pod_usage_hourly.pod_uid and
+                                                    pod_ephemeral_storage_hourly.timestamp = pod_usage_hourly.timestamp)
+         cross join lateral (select pod_run_hours(pod_usage_hourly.timestamp, interval '1 hour', pod.run_start, pod.run_end) as hours) running
+where pod_usage_hourly.cluster_id = @cluster_id
+  and pod_usage_hourly.timestamp < @before
+group by pod_usage_hourly.pod_uid, pod_usage_hourly.cluster_id, date_trunc('day', pod_usage_hourly.timestamp)
+on conflict (pod_uid, timestamp)
+    do update set memory_bytes_max            = greatest(pod_usage_daily.memory_bytes_max, excluded.memory_bytes_max),
+                  memory_bytes_min            = coalesce(least(nullif(pod_usage_daily.memory_bytes_min, 0), nullif(excluded.memory_bytes_min, 0)), 0),
+                  memory_bytes_total          = pod_usage_daily.memory_bytes_total + excluded.memory_bytes_total,
+                  memory_bytes_total_readings = pod_usage_daily.memory_bytes_total_readings + excluded.memory_bytes_total_readings,
+                  cpu_cores_max               = greatest(pod_usage_daily.cpu_cores_max, excluded.cpu_cores_max),
+                  cpu_cores_min               = coalesce(least(nullif(pod_usage_daily.cpu_cores_min, 0), nullif(excluded.cpu_cores_min, 0)), 0),
+                  cpu_cores_total             = pod_usage_daily.cpu_cores_total + excluded.cpu_cores_total,
+                  cpu_cores_total_readings    = pod_usage_daily.cpu_cores_total_readings + excluded.cpu_cores_total_readings,
+                  hours                       = pod_usage_daily.hours + excluded.hours,
+                  request_cpu_core_hours      = pod_usage_daily.request_cpu_core_hours + excluded.request_cpu_core_hours,
+                  request_memory_byte_hours   = pod_usage_daily.request_memory_byte_hours + excluded.request_memory_byte_hours,
+                  used_ephemeral_byte_hours   = pod_usage_daily.used_ephemeral_byte_hours + excluded.used_ephemeral_byte_hours
+`
+
+const purgeObjects = `
+delete
+from object
+where cluster_id = @cluster_id
+  and deleted_at < @before
+  and not exists (select 1 from pod_usage_hourly where pod_uid = object.uid)
+  and not exists (select 1 from pod_usage_daily where pod_uid = object.uid)
+  and not exists (select 1 from container_usage_hourly where pod_uid = object.uid)
+  and not exists (select 1 from pod_cadvisor_hourly where pod_uid = object.uid)
+  and not exists (select 1 from pvc_usage_hourly where pvc_uid = object.uid)
+  and not exists (select 1 from pod_ephemeral_storage_hourly where pod_uid = object.uid)
+  and not exists (select 1 from pod_restart_hourly where pod_uid = object.uid)
+  and not exists (select 1 from pod_request_hourly where pod_uid = object.uid)
+`
+
+// revisions of purged objects and revisions which ended before the cutoff
+const purgeObjectRevisions = `
+delete
+from object_revision
+where cluster_id = @cluster_id
+  and (valid_to < @before or not exists (select 1 from object where uid = object_revision.uid))
+`
+
+// extended resources of purged objects
+const purgeExtendedResources = `
+delete
+from extended_resource
+where cluster_id = @cluster_id
+  and not exists (select 1 from object where uid = extended_resource.uid)
+`
+
+// ApplyRetention rolls up and deletes usage data outside the retention windows in a single transaction
+func (q *Queries) ApplyRetention(ctx context.Context, params RetentionParams) (RetentionResult, error) {
+	var result RetentionResult
+	tx, err := q.db.Begin(ctx)
+	if err != nil {
+		return result, fmt.Errorf("beginning transaction: %w", WrapError(err))
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	exec := func(name string, sql string, before time.Time, rows *int64) error {
+		cmd, err := tx.Exec(ctx, sql, pgx.NamedArgs{"cluster_id": q.clusterID, "before": before})
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, WrapError(err))
+		}
+		*rows = cmd.RowsAffected()
+		return nil
+	}
+
+	if !params.HourlyBefore.IsZero() {
+		var retainedFrom int64
+		steps := []struct {
+			name string
+			sql  string
+			rows *int64
+		}{
+			{"rolling up daily usage", rollupPodUsageDaily, &result.RolledUpDailyRows},
+			{"deleting pod usage", `delete from pod_usage_hourly where cluster_id = @cluster_id and timestamp < @before`, &result.DeletedPodUsageHourly},
+			{"deleting container usage", `delete from container_usage_hourly where cluster_id = @cluster_id and timestamp < @before`, &result.DeletedContainerUsageHourly},
+			{"deleting cadvisor usage", `delete from pod_cadvisor_hourly where cluster_id = @cluster_id and timestamp < @before`, &result.DeletedPodCadvisorHourly},
+			{"deleting pvc usage", `delete from pvc_usage_hourly where cluster_id = @cluster_id and timestamp < @before`, &result.DeletedPVCUsageHourly},
+			{"deleting ephemeral storage usage", `delete from pod_ephemeral_storage_hourly where cluster_id = @cluster_id and timestamp < @before`, &result.DeletedPodEphemeralStorage},
+			{"deleting events", `delete from event where cluster_id = @cluster_id and last_timestamp < @before`, &result.DeletedEvents},
+			{"deleting pod requests", `delete from pod_request_hourly where cluster_id = @cluster_id and timestamp < @before`, &result.DeletedPodRequestHourly},
+			{"deleting pod restarts", `delete from pod_restart_hourly where cluster_id = @cluster_id and timestamp < @before`, &result.DeletedPodRestartHourly},
+			// containers of pods which weren't updated for that long don't restart anymore
+			{"deleting container states", `delete from pod_container_state where cluster_id = @cluster_id and updated_at < @before`, &result.DeletedPodContainerStates},
+			// node hours without pod usage aren't reported as idle
+			{"updating retention start", `update cluster set hourly_retained_from = greatest(hourly_retained_from, @before) where id = @cluster_id`, &retainedFrom},
+		}
+		for _, step := range steps {
+			if err := exec(step.name, step.sql, params.HourlyBefore, step.rows); err != nil {
+				return result, err
+			}
+		}
+	}
+	if !params.DailyBefore.IsZero() {
+		err := exec("deleting daily usage", `delete from pod_usage_daily where cluster_id = @cluster_id and timestamp < @before`, params.DailyBefore, &result.DeletedPodUsageDaily)
+		if err != nil {
+			return result, err
+		}
+	}
+	if !params.ObjectsBefore.IsZero() {
+		if err := exec("purging objects", purgeObjects, params.ObjectsBefore, &result.DeletedObjects); err != nil {
+			return result, err
+		}
+		if err := exec("purging object revisions", purgeObjectRevisions, params.ObjectsBefore, &result.DeletedObjectRevisions); err != nil {
+			return result, err
+		}
+		if err := exec("purging extended resources", purgeExtendedResources, params.ObjectsBefore, &result.DeletedExtendedResources); err != nil {
+			return result, err
+		}
+	}
+
+	if params.DryRun {
+		return result, nil
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return result, fmt.Errorf("committing transaction: %w", WrapError(err))
+	}
+	return result, nil
+}
@@ -0,0 +1,95 @@
+package queries
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestApplyRetention(t *testing.T) {
+	queries := NewTestQueries(t)
+	createPod := func() pgtype.UUID {
+		uid := NewKUUID()
+		require.NoError(t, queries.UpsertObject(context.TODO(), "Pod", &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{UID: uid},
+		}))
+		puid, err := parsePGUUID(uid)
+		require.NoError(t, err)
+		return puid
+	}
+	usedPod := createPod()
+	unusedPod := createPod()
+	_, err := queries.DeleteObjects(context.TODO(), "Pod", []pgtype.UUID{})
+	require.NoError(t, err)
+
+	day := time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, queries.UpsertPodUsedCPU(context.TODO(), []UpsertPodUsedCPUParams{
+		{PodUid: usedPod, Timestamp: pgtype.Timestamptz{Time: day.Add(10 * time.Hour), Valid: true}, CpuCores: 1},
+		{PodUid: usedPod, Timestamp: pgtype.Timestamptz{Time: day.Add(11 * time.Hour), Valid: true}, CpuCores: 3},
+		{PodUid: usedPod, Timestamp: pgtype.Timestamptz{Time: day.Add(48 * time.Hour), Valid: true}, CpuCores: 5},
+	}))
+	require.NoError(t, queries.UpsertPodEphemeralStorage(context.TODO(), []UpsertPodEphemeralStorageParams{
+		{PodUid: usedPod, Timestamp: pgtype.Timestamptz{Time: day.Add(10 * time.Hour), Valid: true}, UsedBytes: 1024},
+	}))
+
+	params := RetentionParams{
+		HourlyBefore:  day.Add(24 * time.Hour),
+		ObjectsBefore: time.Now().Add(time.Hour),
+		DryRun:        true,
+	}
+	expected := RetentionResult{
+		RolledUpDailyRows:          1,
+		DeletedPodUsageHourly:      2,
+		DeletedPodEphemeralStorage: 1,
+		DeletedObjects:             1,
+	}
+	result, err := queries.ApplyRetention(context.TODO(), params)
+	require.NoError(t, err)
+	assert.Equal(t, expected, result)
+	usage, err := queries.ListPodUsageHourly(context.TODO())
+	require.NoError(t, err)
+	assert.Len(t, usage, 3, "dry run doesn't remove anything")
+
+	params.DryRun = false
+	result, err = queries.ApplyRetention(context.TODO(), params)
+	require.NoError(t, err)
+	assert.Equal(t, expected, result)
+	usage, err = queries.ListPodUsageHourly(context.TODO())
+	require.NoError(t, err)
+	assert.Len(t, usage, 1)
+
+	var readings int
+	var cpuAvg float64
+	err = queries.db.QueryRow(context.TODO(), "select cpu_cores_total_readings, cpu_cores_avg from pod_usage_daily where pod_uid = $1 and timestamp = $2", usedPod, day).Scan(&readings, &cpuAvg)
+	require.NoError(t, err)
+	assert.Equal(t, 2, readings)
+	assert.InDelta(t, 2.0, cpuAvg, 0.0001)
+
+	var usedEphemeral float64
+	err = queries.db.QueryRow(context.TODO(), "select used_ephemeral_bytes from pod_usage_request_daily where uid = $1 and timestamp = $2", usedPod, day).Scan(&usedEphemeral)
+	require.NoError(t, err)
+	assert.InDelta(t, 512.0, usedEphemeral, 0.0001, "ephemeral storage usage is kept after the hourly rows are deleted")
+
+	// nodes have no pod usage left in the expired hours, they aren't reported as idle
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{UID: NewKUUID(), Name: "retention-node", CreationTimestamp: metav1.NewTime(day.Add(-24 * time.Hour))}}
+	require.NoError(t, queries.UpsertObject(context.TODO(), "Node", node))
+	var expiredIdle, retainedIdle int
+	require.NoError(t, queries.db.QueryRow(context.TODO(), "select count(*) from cost_hourly where node_name = $1 and namespace = '_idle' and timestamp < $2", node.Name, params.HourlyBefore).Scan(&expiredIdle))
+	require.NoError(t, queries.db.QueryRow(context.TODO(), "select count(*) from cost_hourly where node_name = $1 and namespace = '_idle' and timestamp >= $2 and timestamp < $3", node.Name, params.HourlyBefore, params.HourlyBefore.Add(24*time.Hour)).Scan(&retainedIdle))
+	assert.Equal(t, 0, expiredIdle)
+	assert.Equal(t, 24, retainedIdle)
+
+	var objects int
+	require.NoError(t, queries.db.QueryRow(context.TODO(), "select count(*) from object where uid = any($1)", []pgtype.UUID{usedPod, unusedPod}).Scan(&objects))
+	assert.Equal(t, 1, objects, "objects referenced by usage are kept")
+
+	result, err = queries.ApplyRetention(context.TODO(), RetentionParams{DailyBefore: day.Add(24 * time.Hour)})
+	require.NoError(t, err)
+	assert.Equal(t, RetentionResult{DeletedPodUsageDaily: 1}, result)
+}
@@ -26,26 +26,60 @@ func Cols() []string {
 		"namespace",
 		"controller_kind",
 		"controller_name",
+		"direct_owner_kind",
+		"direct_owner_name",
 		"name",
+		"container_name",
 		"node_name",
 		"request_cpu_core_hours",
 		"used_cpu_core_hours",
 		"request_memory_gb_hours",
 		"used_memory_gb_hours",
+		"limit_cpu_core_hours",
+		"limit_memory_gb_hours",
+		"cpu_overcommit_ratio",
+		"memory_overcommit_ratio",
 		"request_storage_gb_hours",
+		"used_storage_gb_hours",
+		"storage_efficiency_percent",
+		"request_ephemeral_gb_hours",
+		"used_ephemeral_gb_hours",
+		"used_fs_gb_hours",
+		"network_receive_gb",
+		"network_transmit_gb",
+		"cpu_throttled_percent",
+		"oom_kills",
+		"evictions",
+		"failed_scheduling",
+		"restarts",
+		"oom_killed",
 		"hours",
 		"cpu_cost",
 		"memory_cost",
 		"storage_cost",
+		"extended_cost",
+		"ephemeral_cost",
 		"total_cost",
 	}
 }
 
+const (
+	GranularityHour   = "hour"
+	GranularityMinute = "minute"
+	GranularityDay    = "day"
+)
+
+// MaxMinuteRange is the longest time range which can be queried with minute granularity
+const MaxMinuteRange = 48 * time.Hour
+
 type WorkloadAggRequest struct {
 	Cols    []string
 	OrderBy string
 	Start   time.Time
 	End     time.Time
+	// Granularity is GranularityHour (default), GranularityMinute or GranularityDay
+	// minute data is only available with raw samples enabled, daily data also covers hours removed by the retention job
+	Granularity string
 }
 
 func Contains(data []string, term string) bool {
@@ -57,22 +91,8 @@ func Contains(data []string, term string) bool {
 	return false
 }
 
-// nolint:cyclop
-func (w WorkloadAggRequest) Validate() error {
-	for _, g := range w.Cols {
-		if !Contains(Cols(), g) {
-			return fmt.Errorf("invalid column: %s", g)
-		}
-	}
-
-	orderByCol := strings.TrimPrefix(strings.TrimSuffix(w.OrderBy, " desc"), " asc")
-	if !Contains(Cols(), orderByCol) {
-		return fmt.Errorf("invalid order by: %s", w.OrderBy)
-	}
-	return nil
-}
-
-var labelRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9-_.]*[a-zA-Z0-9]$`)
+// labelRegex matches label keys with an optional prefix, e.g. topology.kubernetes.io/zone
+var labelRegex = regexp.MustCompile(`^([a-zA-Z0-9][a-zA-Z0-9-_.]*[a-zA-Z0-9]/)?[a-zA-Z0-9][a-zA-Z0-9-_.]*[a-zA-Z0-9]$`)
 
 func workloadQuery(req WorkloadAggRequest) (string, []interface{}, error) {
 	psq := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
@@ -83,70 +103,286 @@ func workloadQuery(req WorkloadAggRequest) (string, []interface{}, error) {
 
 	selectStmts := make([]string, 0)
 	groupByStmts := make([]string, 0)
+	// overcommit ratios leave out rows without a limit, they aren't bounded by one
 	selectMap := map[string]string{
-		"timestamp":                "timestamp",
-		"date":                     "timestamp::date::text",
-		"cluster":                  "(select name from cluster where id = cluster_id)",
-		"namespace":                "namespace",
-		"controller_kind":          "controller_kind",
-		"controller_name":          "controller_name",
-		"name":                     "name",
-		"node_name":                "node_name",
-		"request_cpu_core_hours":   "round((sum(request_cpu_cores * hours))::numeric, 2)",
-		"used_cpu_core_hours":      "round((sum(cpu_cores_avg * hours))::numeric, 2)",
-		"request_memory_gb_hours":  "round(sum(request_memory_bytes * hours)) / 1024 / 1024 / 1024",
-		"used_memory_gb_hours":     "round(sum(memory_bytes_avg * hours)) / 1024 / 1024 / 1024",
-		"request_storage_gb_hours": "round(sum(request_storage_bytes * hours)) / 1024 / 1024 / 1024",
-		"hours":                    "round(sum(hours), 2)",
-		"cpu_cost":                 "round(sum(cpu_cost)::numeric, 2)",
-		"memory_cost":              "round(sum(memory_cost)::numeric, 2)",
-		"storage_cost":             "round(sum(storage_cost)::numeric, 2)",
-		"total_cost":               "round(sum(memory_cost + cpu_cost + storage_cost)::numeric, 2)",
+		"timestamp":                  "timestamp",
+		"date":                       "timestamp::date::text",
+		"cluster":                    "(select name from cluster where id = cluster_id)",
+		"namespace":                  "namespace",
+		"controller_kind":            "controller_kind",
+		"controller_name":            "controller_name",
+		"direct_owner_kind":          "coalesce(direct_owner_kind, controller_kind)",
+		"direct_owner_name":          "coalesce(direct_owner_name, controller_name)",
+		"name":                       "name",
+		"container_name":             "container_name",
+		"node_name":                  "node_name",
+		"request_cpu_core_hours":     "round((sum(request_cpu_cores * hours))::numeric, 2)",
+		"used_cpu_core_hours":        "round((sum(cpu_cores_avg * hours))::numeric, 2)",
+		"request_memory_gb_hours":    "round(sum(request_memory_bytes * hours)) / 1024 / 1024 / 1024",
+		"used_memory_gb_hours":       "round(sum(memory_bytes_avg * hours)) / 1024 / 1024 / 1024",
+		"limit_cpu_core_hours":       "round((sum(limit_cpu_cores * hours))::numeric, 2)",
+		"limit_memory_gb_hours":      "round(sum(limit_memory_bytes * hours)) / 1024 / 1024 / 1024",
+		"cpu_overcommit_ratio":       "round((sum(limit_cpu_cores * hours) / nullif(sum(request_cpu_cores * hours) filter (where limit_cpu_cores > 0), 0))::numeric, 2)",
+		"memory_overcommit_ratio":    "round((sum(limit_memory_bytes * hours) / nullif(sum(request_memory_bytes * hours) filter (where limit_memory_bytes > 0), 0))::numeric, 2)",
+		"request_storage_gb_hours":   "round(sum(request_storage_bytes * hours)) / 1024 / 1024 / 1024",
+		"used_storage_gb_hours":      "round(sum(used_storage_bytes * hours)) / 1024 / 1024 / 1024",
+		"storage_efficiency_percent": "round((100 * sum(used_storage_bytes * hours) / nullif(sum(request_storage_bytes * hours), 0))::numeric, 2)",
+		"request_ephemeral_gb_hours": "round(sum(request_ephemeral_bytes * hours)) / 1024 / 1024 / 1024",
+		"used_ephemeral_gb_hours":    "round(sum(used_ephemeral_bytes * hours)) / 1024 / 1024 / 1024",
+		"used_fs_gb_hours":           "round(sum(fs_usage_bytes_avg * hours)) / 1024 / 1024 / 1024",
+		"network_receive_gb":         "round(sum(network_receive_bytes)) / 1024 / 1024 / 1024",
+		"network_transmit_gb":        "round(sum(network_transmit_bytes)) / 1024 / 1024 / 1024",
+		"cpu_throttled_percent":      "round((100 * sum(cpu_cfs_throttled_periods) / nullif(sum(cpu_cfs_periods), 0))::numeric, 2)",
+		"oom_kills":                  "sum(oom_kills)",
+		"evictions":                  "sum(evictions)",
+		"failed_scheduling":          "sum(failed_scheduling)",
+		"restarts":                   "sum(restarts)",
+		"oom_killed":                 "sum(oom_killed)",
+		"hours":                      "round(sum(hours), 2)",
+		"cpu_cost":                   "round(sum(cpu_cost)::numeric, 2)",
+		"memory_cost":                "round(sum(memory_cost)::numeric, 2)",
+		"storage_cost":               "round(sum(storage_cost)::numeric, 2)",
+		"extended_cost":              "round(sum(extended_cost)::numeric, 2)",
+		"ephemeral_cost":             "round(sum(ephemeral_cost)::numeric, 2)",
+		"total_cost":                 "round(sum(memory_cost + cpu_cost + storage_cost + extended_cost + ephemeral_cost)::numeric, 2)",
 	}
 	groupByCols := map[string]struct{}{
-		"timestamp":       {},
-		"date":            {},
-		"cluster":         {},
-		"namespace":       {},
-		"controller_kind": {},
-		"controller_name": {},
-		"name":            {},
-		"node_name":       {},
+		"timestamp":         {},
+		"date":              {},
+		"cluster":           {},
+		"namespace":         {},
+		"controller_kind":   {},
+		"controller_name":   {},
+		"direct_owner_kind": {},
+		"direct_owner_name": {},
+		"name":              {},
+		"container_name":    {},
+		"node_name":         {},
+	}
+	source, err := workloadSource(req)
+	if err != nil {
+		return "", nil, err
+	}
+	var joins []string
+	addJoin := func(join string) {
+		if !Contains(joins, join) {
+			joins = append(joins, join)
+		}
 	}
 	// keep column order consistent
 	for _, c := range Cols() {
 		if Contains(req.Cols, c) {
+			if _, ok := podOnlyCols[c]; ok && source == containerSource {
+				return "", nil, fmt.Errorf("column %s is not available when grouping by container_name", c)
+			}
+			if _, ok := podOnlyCols[c]; ok && (source == minuteSource || source == daySource) {
+				return "", nil, fmt.Errorf("column %s is not available with %s granularity", c, req.Granularity)
+			}
 			selectStmts = append(selectStmts, selectMap[c]+" as "+c)
 			if _, ok := groupByCols[c]; ok {
 				groupByStmts = append(groupByStmts, selectMap[c])
 			}
+			if join, ok := colJoins[c]; ok {
+				addJoin(join)
+			}
 		}
 	}
 	for _, c := range req.Cols {
-		if !strings.HasPrefix(c, "label_") {
+		resource, cost, ok := parseExtendedResourceCol(c)
+		if !ok {
 			continue
 		}
-		label := strings.TrimPrefix(c, "label_")
 		// IMPORTANT. Protection from SQL injection
-		if !labelRegex.MatchString(label) {
-			return "", nil, fmt.Errorf("invalid label: %s", label)
+		if !labelRegex.MatchString(resource) {
+			return "", nil, fmt.Errorf("invalid extended resource: %s", resource)
+		}
+		quantity := fmt.Sprintf("coalesce((extended_resources->>'%s')::numeric, 0) * hours", resource)
+		if cost {
+			quantity = fmt.Sprintf("%s * extended_resource_price('%s')", quantity, resource)
+		}
+		selectStmts = append(selectStmts, fmt.Sprintf(`round(sum(%s)::numeric, 2) as "%s"`, quantity, c))
+	}
+	for _, labelCol := range labelCols {
+		for _, c := range req.Cols {
+			if !strings.HasPrefix(c, labelCol.prefix) {
+				continue
+			}
+			label := strings.TrimPrefix(c, labelCol.prefix)
+			// IMPORTANT. Protection from SQL injection
+			if !labelRegex.MatchString(label) {
+				return "", nil, fmt.Errorf("invalid %s: %s", labelCol.name, label)
+			}
+			selectStmts = append(selectStmts, fmt.Sprintf(`coalesce(%s->>'%s', '') as "%s"`, labelCol.column, label, c))
+			groupByStmts = append(groupByStmts, fmt.Sprintf("%s->>'%s'", labelCol.column, label))
+			if labelCol.join != "" {
+				addJoin(labelCol.join)
+			}
 		}
-		selectStmts = append(selectStmts, fmt.Sprintf("coalesce(labels->>'%s', '') as %s", label, c))
-		groupByStmts = append(groupByStmts, fmt.Sprintf("labels->>'%s'", label))
 	}
 	query := psq.
 		Select(selectStmts...).
 		GroupBy(groupByStmts...).
-		From("cost_hourly").
+		From(source).
 		Where(sq.GtOrEq{"timestamp": req.Start}).
 		Where(sq.Lt{"timestamp": req.End})
+	for _, join := range joins {
+		query = query.LeftJoin(join)
+	}
 	if req.OrderBy != "" {
-		query = query.OrderBy(req.OrderBy)
+		orderBy, err := orderByClause(req)
+		if err != nil {
+			return "", nil, err
+		}
+		query = query.OrderBy(orderBy)
 	}
 	// nolint: wrapcheck
 	return query.ToSql()
 }
 
+const (
+	podSource       = "cost_hourly"
+	containerSource = "cost_container_hourly"
+	minuteSource    = "cost_pod_minutely"
+	daySource       = "cost_pod_daily"
+)
+
+// joined columns are renamed to avoid ambiguity with the source
+const (
+	namespaceJoin = "(select cluster_id as ns_cluster_id, name as ns_name, labels as ns_labels from namespace) ns on (ns.ns_cluster_id = cluster_id and ns.ns_name = namespace)"
+	// a node can be deleted and created again with the same name, the current one is preferred
+	nodeJoin = "(select distinct on (cluster_id, name) cluster_id as nl_cluster_id, name as nl_name, labels as node_labels from node order by cluster_id, name, deleted_at desc nulls first) nl on (nl.nl_cluster_id = cluster_id and nl.nl_name = node_name)"
+	// idle and system rows have no owner, they fall back to the controller columns
+	// chains are stored by the object trigger, the join is a primary key lookup
+	ownerJoin = "(select uid as ow_uid, direct_owner_kind, direct_owner_name from object_controller_chain) ow on (ow.ow_uid = uid)"
+)
+
+// colJoins are joined when the column is selected
+var colJoins = map[string]string{
+	"direct_owner_kind": ownerJoin,
+	"direct_owner_name": ownerJoin,
+}
+
+// labelCols are column families selecting a label or an annotation, e.g. label_app or annotation_owner.example.com/team
+var labelCols = []struct {
+	prefix string
+	name   string
+	column string
+	join   string
+}{
+	{prefix: "label_", name: "label", column: "labels"},
+	{prefix: "nslabel_", name: "namespace label", column: "ns_labels", join: namespaceJoin},
+	{prefix: "nodelabel_", name: "node label", column: "node_labels", join: nodeJoin},
+	{prefix: "annotation_", name: "annotation", column: "annotations"},
+}
+
+// IsLabelCol reports whether the column belongs to one of the label column families
+func IsLabelCol(col string) bool {
+	for _, labelCol := range labelCols {
+		if strings.HasPrefix(col, labelCol.prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// extended resource columns are request_<resource>_hours and <resource>_cost, e.g. request_nvidia.com/gpu_hours
+const (
+	extendedRequestPrefix = "request_"
+	extendedRequestSuffix = "_hours"
+	extendedCostSuffix    = "_cost"
+)
+
+// ExtendedResourceCols returns the columns of an extended resource
+func ExtendedResourceCols(resource string) []string {
+	return []string{extendedRequestPrefix + resource + extendedRequestSuffix, resource + extendedCostSuffix}
+}
+
+// IsExtendedResourceCol reports whether the column belongs to an extended resource
+func IsExtendedResourceCol(col string) bool {
+	_, _, ok := parseExtendedResourceCol(col)
+	return ok
+}
+
+// parseExtendedResourceCol returns the resource of the column, names of extended resources always have a domain
+// which keeps them apart from other columns, e.g. request_cpu_core_hours
+func parseExtendedResourceCol(col string) (resource string, cost bool, ok bool) {
+	switch {
+	case IsLabelCol(col):
+		return "", false, false
+	case strings.HasPrefix(col, extendedRequestPrefix) && strings.HasSuffix(col, extendedRequestSuffix):
+		resource = strings.TrimSuffix(strings.TrimPrefix(col, extendedRequestPrefix), extendedRequestSuffix)
+	case strings.HasSuffix(col, extendedCostSuffix):
+		resource, cost = strings.TrimSuffix(col, extendedCostSuffix), true
+	default:
+		return "", false, false
+	}
+	return resource, cost, strings.Contains(resource, "/")
+}
+
+// orderByClause accepts a column followed by an optional direction, e.g. "total_cost desc"
+// label and extended resource columns must be selected, their keys are validated together with the selected columns
+func orderByClause(req WorkloadAggRequest) (string, error) {
+	col, direction, _ := strings.Cut(strings.TrimSpace(req.OrderBy), " ")
+	direction = strings.ToLower(strings.TrimSpace(direction))
+	if direction != "" && direction != "asc" && direction != "desc" {
+		return "", fmt.Errorf("invalid order by: %s", req.OrderBy)
+	}
+	switch {
+	case Contains(Cols(), col):
+	case (IsLabelCol(col) || IsExtendedResourceCol(col)) && Contains(req.Cols, col):
+		col = `"` + col + `"`
+	default:
+		return "", fmt.Errorf("invalid order by: %s", req.OrderBy)
+	}
+	if direction == "" {
+		return col, nil
+	}
+	return col + " " + direction, nil
+}
+
+// podOnlyCols are collected per pod and can't be split between containers
+var podOnlyCols = map[string]struct{}{
+	"used_storage_gb_hours":      {},
+	"storage_efficiency_percent": {},
+	"used_ephemeral_gb_hours":    {},
+	"used_fs_gb_hours":           {},
+	"network_receive_gb":         {},
+	"network_transmit_gb":        {},
+	"cpu_throttled_percent":      {},
+	"oom_kills":                  {},
+	"evictions":                  {},
+	"failed_scheduling":          {},
+	"restarts":                   {},
+	"oom_killed":                 {},
+}
+
+// workloadSource returns the view the aggregation is computed from
+// container level, minute and day rows only exist for pods, idle and system rows are excluded
+func workloadSource(req WorkloadAggRequest) (string, error) {
+	switch req.Granularity {
+	case "", GranularityHour:
+	case GranularityMinute:
+		if Contains(req.Cols, "container_name") {
+			return "", fmt.Errorf("column container_name is not available with minute granularity")
+		}
+		if req.End.Sub(req.Start) > MaxMinuteRange {
+			return "", fmt.Errorf("time range is too long for minute granularity, max %s", MaxMinuteRange)
+		}
+		return minuteSource, nil
+	case GranularityDay:
+		if Contains(req.Cols, "container_name") {
+			return "", fmt.Errorf("column container_name is not available with day granularity")
+		}
+		return daySource, nil
+	default:
+		return "", fmt.Errorf("invalid granularity: %s", req.Granularity)
+	}
+	if Contains(req.Cols, "container_name") {
+		return containerSource, nil
+	}
+	return podSource, nil
+}
+
 type WorkloadAggResult struct {
 	Columns      []string
 	Rows         [][]string
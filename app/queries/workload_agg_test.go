@@ -2,11 +2,15 @@ package queries
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestWorkloadAgg_SQLInjection(t *testing.T) {
@@ -21,6 +25,103 @@ func TestWorkloadAgg_SQLInjection(t *testing.T) {
 	require.ErrorContains(t, err, "invalid label")
 }
 
+func TestWorkloadAgg_NamespaceLabelSQLInjection(t *testing.T) {
+	_, _, err := workloadQuery(WorkloadAggRequest{
+		Cols:    []string{"nslabel_team') as x; DROP TABLE object; --"},
+		OrderBy: "namespace",
+		Start:   time.Now().Add(-24 * time.Hour),
+		End:     time.Now(),
+	})
+	require.ErrorContains(t, err, "invalid namespace label")
+}
+
+func TestWorkloadAgg_NodeLabelSQLInjection(t *testing.T) {
+	_, _, err := workloadQuery(WorkloadAggRequest{
+		Cols:    []string{"nodelabel_zone'); DROP TABLE object; --"},
+		OrderBy: "namespace",
+		Start:   time.Now().Add(-24 * time.Hour),
+		End:     time.Now(),
+	})
+	require.ErrorContains(t, err, "invalid node label")
+}
+
+func TestWorkloadAgg_AnnotationSQLInjection(t *testing.T) {
+	_, _, err := workloadQuery(WorkloadAggRequest{
+		Cols:    []string{"annotation_owner.example.com/team'); DROP TABLE object; --"},
+		OrderBy: "namespace",
+		Start:   time.Now().Add(-24 * time.Hour),
+		End:     time.Now(),
+	})
+	require.ErrorContains(t, err, "invalid annotation")
+}
+
+func TestWorkloadAgg_ExtendedResourceSQLInjection(t *testing.T) {
+	_, _, err := workloadQuery(WorkloadAggRequest{
+		Cols:    []string{"request_nvidia.com/gpu') as x; DROP TABLE object; --_hours"},
+		OrderBy: "namespace",
+		Start:   time.Now().Add(-24 * time.Hour),
+		End:     time.Now(),
+	})
+	assert.ErrorContains(t, err, "invalid extended resource")
+}
+
+func TestParseExtendedResourceCol(t *testing.T) {
+	testCases := []struct {
+		col      string
+		resource string
+		cost     bool
+		ok       bool
+	}{
+		{col: "request_nvidia.com/gpu_hours", resource: "nvidia.com/gpu"},
+		{col: "nvidia.com/gpu_cost", resource: "nvidia.com/gpu", cost: true},
+		{col: "request_cpu_core_hours"},
+		{col: "storage_cost"},
+		{col: "label_example.com/team_cost"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.col, func(t *testing.T) {
+			resource, cost, ok := parseExtendedResourceCol(tc.col)
+			assert.Equal(t, tc.resource != "", ok)
+			if ok {
+				assert.Equal(t, tc.resource, resource)
+				assert.Equal(t, tc.cost, cost)
+			}
+		})
+	}
+	assert.Equal(t, []string{"request_nvidia.com/gpu_hours", "nvidia.com/gpu_cost"}, ExtendedResourceCols("nvidia.com/gpu"))
+}
+
+func TestWorkloadAgg_OrderBy(t *testing.T) {
+	testCases := []struct {
+		orderBy string
+		want    string
+		err     bool
+	}{
+		{orderBy: "total_cost desc", want: "ORDER BY total_cost desc"},
+		{orderBy: "namespace", want: "ORDER BY namespace"},
+		{orderBy: "annotation_owner.example.com/team asc", want: `ORDER BY "annotation_owner.example.com/team" asc`},
+		{orderBy: "label_app", err: true},
+		{orderBy: "namespace; DROP TABLE object", err: true},
+		{orderBy: "(select 1)", err: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.orderBy, func(t *testing.T) {
+			sql, _, err := workloadQuery(WorkloadAggRequest{
+				Cols:    []string{"namespace", "annotation_owner.example.com/team", "total_cost"},
+				OrderBy: tc.orderBy,
+				Start:   time.Now().Add(-24 * time.Hour),
+				End:     time.Now(),
+			})
+			if tc.err {
+				require.ErrorContains(t, err, "invalid order by")
+				return
+			}
+			require.NoError(t, err)
+			assert.Contains(t, sql, tc.want)
+		})
+	}
+}
+
 func TestWorkloadAgg(t *testing.T) {
 	queries := NewTestQueries(t)
 	testCases := []struct {
@@ -46,6 +147,227 @@ func TestWorkloadAgg(t *testing.T) {
 				OrderBy: "label_app",
 			},
 		},
+		{
+			name: "WithNamespaceLabelColumns",
+			req: WorkloadAggRequest{
+				Cols:    []string{"namespace", "nslabel_team", "label_app.kubernetes.io/name", "total_cost"},
+				Start:   time.Now().Add(-24 * time.Hour),
+				End:     time.Now(),
+				OrderBy: "namespace",
+			},
+		},
+		{
+			name: "WithAnnotationColumns",
+			req: WorkloadAggRequest{
+				Cols:    []string{"namespace", "annotation_owner.example.com/team", "label_app", "total_cost"},
+				Start:   time.Now().Add(-24 * time.Hour),
+				End:     time.Now(),
+				OrderBy: "annotation_owner.example.com/team desc",
+			},
+		},
+		{
+			name: "WithDirectOwnerColumns",
+			req: WorkloadAggRequest{
+				Cols:    []string{"namespace", "controller_kind", "controller_name", "direct_owner_kind", "direct_owner_name", "nslabel_team", "total_cost"},
+				Start:   time.Now().Add(-24 * time.Hour),
+				End:     time.Now(),
+				OrderBy: "direct_owner_name",
+			},
+		},
+		{
+			name: "WithDirectOwnerAndContainerName",
+			req: WorkloadAggRequest{
+				Cols:    []string{"direct_owner_kind", "direct_owner_name", "container_name", "total_cost"},
+				Start:   time.Now().Add(-24 * time.Hour),
+				End:     time.Now(),
+				OrderBy: "total_cost desc",
+			},
+		},
+		{
+			name: "WithExtendedResourceColumns",
+			req: WorkloadAggRequest{
+				Cols:    []string{"namespace", "request_nvidia.com/gpu_hours", "nvidia.com/gpu_cost", "extended_cost", "total_cost"},
+				Start:   time.Now().Add(-24 * time.Hour),
+				End:     time.Now(),
+				OrderBy: "request_nvidia.com/gpu_hours desc",
+			},
+		},
+		{
+			name: "WithExtendedResourceColumnsAndContainerName",
+			req: WorkloadAggRequest{
+				Cols:    []string{"container_name", "request_nvidia.com/gpu_hours", "nvidia.com/gpu_cost"},
+				Start:   time.Now().Add(-24 * time.Hour),
+				End:     time.Now(),
+				OrderBy: "container_name",
+			},
+		},
+		{
+			name: "WithNodeLabelColumns",
+			req: WorkloadAggRequest{
+				Cols:    []string{"nodelabel_topology.kubernetes.io/zone", "nodelabel_karpenter.sh/capacity-type", "node_name", "total_cost"},
+				Start:   time.Now().Add(-24 * time.Hour),
+				End:     time.Now(),
+				OrderBy: "total_cost desc",
+			},
+		},
+		{
+			name: "WithContainerName",
+			req: WorkloadAggRequest{
+				Cols:    []string{"namespace", "name", "container_name", "label_app", "total_cost"},
+				Start:   time.Now().Add(-24 * time.Hour),
+				End:     time.Now(),
+				OrderBy: "container_name",
+			},
+		},
+		{
+			name: "WithCadvisorColumns",
+			req: WorkloadAggRequest{
+				Cols:    []string{"namespace", "used_fs_gb_hours", "network_receive_gb", "network_transmit_gb", "cpu_throttled_percent"},
+				Start:   time.Now().Add(-24 * time.Hour),
+				End:     time.Now(),
+				OrderBy: "cpu_throttled_percent desc",
+			},
+		},
+		{
+			name: "WithStorageUsageColumns",
+			req: WorkloadAggRequest{
+				Cols:    []string{"namespace", "request_storage_gb_hours", "used_storage_gb_hours", "storage_efficiency_percent"},
+				Start:   time.Now().Add(-24 * time.Hour),
+				End:     time.Now(),
+				OrderBy: "storage_efficiency_percent",
+			},
+		},
+		{
+			name: "WithEphemeralStorageColumns",
+			req: WorkloadAggRequest{
+				Cols:    []string{"namespace", "request_ephemeral_gb_hours", "used_ephemeral_gb_hours", "ephemeral_cost", "total_cost"},
+				Start:   time.Now().Add(-24 * time.Hour),
+				End:     time.Now(),
+				OrderBy: "used_ephemeral_gb_hours desc",
+			},
+		},
+		{
+			name: "WithContainerNameAndEphemeralStorageUsage",
+			req: WorkloadAggRequest{
+				Cols:    []string{"container_name", "request_ephemeral_gb_hours", "used_ephemeral_gb_hours"},
+				Start:   time.Now().Add(-24 * time.Hour),
+				End:     time.Now(),
+				OrderBy: "container_name",
+			},
+			err: true,
+		},
+		{
+			name: "WithLimitColumns",
+			req: WorkloadAggRequest{
+				Cols:    []string{"namespace", "limit_cpu_core_hours", "limit_memory_gb_hours", "cpu_overcommit_ratio", "memory_overcommit_ratio"},
+				Start:   time.Now().Add(-24 * time.Hour),
+				End:     time.Now(),
+				OrderBy: "memory_overcommit_ratio desc",
+			},
+		},
+		{
+			name: "WithLimitColumnsAndContainerName",
+			req: WorkloadAggRequest{
+				Cols:    []string{"container_name", "limit_cpu_core_hours", "cpu_overcommit_ratio"},
+				Start:   time.Now().Add(-24 * time.Hour),
+				End:     time.Now(),
+				OrderBy: "cpu_overcommit_ratio desc",
+			},
+		},
+		{
+			name: "WithEventColumns",
+			req: WorkloadAggRequest{
+				Cols:    []string{"namespace", "controller_name", "oom_kills", "evictions", "failed_scheduling", "restarts", "oom_killed"},
+				Start:   time.Now().Add(-24 * time.Hour),
+				End:     time.Now(),
+				OrderBy: "oom_kills desc",
+			},
+		},
+		{
+			name: "WithContainerNameAndPodOnlyColumn",
+			req: WorkloadAggRequest{
+				Cols:    []string{"container_name", "network_receive_gb"},
+				Start:   time.Now().Add(-24 * time.Hour),
+				End:     time.Now(),
+				OrderBy: "container_name",
+			},
+			err: true,
+		},
+		{
+			name: "WithMinuteGranularity",
+			req: WorkloadAggRequest{
+				Cols:        []string{"timestamp", "namespace", "label_app", "total_cost"},
+				Start:       time.Now().Add(-time.Hour),
+				End:         time.Now(),
+				OrderBy:     "timestamp",
+				Granularity: GranularityMinute,
+			},
+		},
+		{
+			name: "WithMinuteGranularityAndContainerName",
+			req: WorkloadAggRequest{
+				Cols:        []string{"container_name"},
+				Start:       time.Now().Add(-time.Hour),
+				End:         time.Now(),
+				OrderBy:     "container_name",
+				Granularity: GranularityMinute,
+			},
+			err: true,
+		},
+		{
+			name: "WithMinuteGranularityAndPodOnlyColumn",
+			req: WorkloadAggRequest{
+				Cols:        []string{"namespace", "network_receive_gb"},
+				Start:       time.Now().Add(-time.Hour),
+				End:         time.Now(),
+				OrderBy:     "namespace",
+				Granularity: GranularityMinute,
+			},
+			err: true,
+		},
+		{
+			name: "WithMinuteGranularityAndLongRange",
+			req: WorkloadAggRequest{
+				Cols:        []string{"namespace"},
+				Start:       time.Now().Add(-MaxMinuteRange - time.Hour),
+				End:         time.Now(),
+				OrderBy:     "namespace",
+				Granularity: GranularityMinute,
+			},
+			err: true,
+		},
+		{
+			name: "WithDayGranularity",
+			req: WorkloadAggRequest{
+				Cols:        []string{"date", "namespace", "controller_name", "total_cost"},
+				Start:       time.Now().Add(-30 * 24 * time.Hour),
+				End:         time.Now(),
+				OrderBy:     "date",
+				Granularity: GranularityDay,
+			},
+		},
+		{
+			name: "WithDayGranularityAndContainerName",
+			req: WorkloadAggRequest{
+				Cols:        []string{"container_name"},
+				Start:       time.Now().Add(-24 * time.Hour),
+				End:         time.Now(),
+				OrderBy:     "container_name",
+				Granularity: GranularityDay,
+			},
+			err: true,
+		},
+		{
+			name: "WithInvalidGranularity",
+			req: WorkloadAggRequest{
+				Cols:        []string{"namespace"},
+				Start:       time.Now().Add(-time.Hour),
+				End:         time.Now(),
+				OrderBy:     "namespace",
+				Granularity: "second",
+			},
+			err: true,
+		},
 		{
 			name: "WithInvalidColumns",
 			req: WorkloadAggRequest{
@@ -89,3 +411,41 @@ func TestWorkloadAgg(t *testing.T) {
 		})
 	}
 }
+
+func TestWorkloadAgg_OvercommitRatio(t *testing.T) {
+	queries := NewTestQueries(t)
+	namespace := "overcommit-" + string(NewKUUID())
+	hour := time.Now().UTC().Truncate(time.Hour).Add(-2 * time.Hour)
+	createPod := func(limitCPUCores float64) {
+		pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{UID: NewKUUID(), Namespace: namespace, Name: "app"}}
+		data, err := json.Marshal(pod)
+		require.NoError(t, err)
+		require.NoError(t, queries.UpsertObjectData(context.TODO(), "Pod", pod, data, PodColumns{
+			RequestCpuCores: pgtype.Float8{Float64: 1, Valid: true},
+			LimitCpuCores:   pgtype.Float8{Float64: limitCPUCores, Valid: true},
+		}))
+		uid, err := parsePGUUID(pod.UID)
+		require.NoError(t, err)
+		require.NoError(t, queries.UpsertPodUsedCPU(context.TODO(), []UpsertPodUsedCPUParams{
+			{PodUid: uid, Timestamp: pgtype.Timestamptz{Time: hour, Valid: true}, CpuCores: 1},
+		}))
+	}
+	createPod(2)
+	// the pod without a limit would halve the ratio if its request was counted
+	createPod(0)
+
+	result, err := queries.WorkloadAgg(context.TODO(), WorkloadAggRequest{
+		Cols:    []string{"namespace", "cpu_overcommit_ratio"},
+		OrderBy: "namespace",
+		Start:   hour,
+		End:     hour.Add(time.Hour),
+	})
+	require.NoError(t, err)
+	for _, row := range result.Rows {
+		if row[0] == namespace {
+			assert.Equal(t, "2", row[1])
+			return
+		}
+	}
+	t.Fatalf("namespace %s is missing from the result", namespace)
+}
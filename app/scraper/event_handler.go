@@ -0,0 +1,92 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/r2k1/pgkube/app/queries"
+)
+
+// EventHandler writes kubernetes events into the event table
+// events expire in the cluster (1h by default), deleted events are kept
+type EventHandler struct {
+	queries *queries.Queries
+}
+
+func NewEventHandler(queries *queries.Queries) *EventHandler {
+	return &EventHandler{queries: queries}
+}
+
+func (h *EventHandler) OnAdd(obj interface{}, isInInitialList bool) {
+	h.Upsert(obj)
+}
+
+func (h *EventHandler) OnUpdate(oldObj, newObj interface{}) {
+	h.Upsert(newObj)
+}
+
+func (h *EventHandler) OnDelete(obj interface{}) {}
+
+func (h *EventHandler) Upsert(obj interface{}) {
+	event, ok := obj.(*v1.Event)
+	if !ok {
+		slog.Error("upserting event", "error", fmt.Errorf("unexpected object type: %T", obj))
+		return
+	}
+	params, err := eventParams(event)
+	if err != nil {
+		slog.Error("upserting event", "error", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.queries.UpsertEvent(ctx, params); err != nil {
+		slog.Error("upserting event", "error", err)
+	}
+}
+
+func eventParams(event *v1.Event) (queries.UpsertEventParams, error) {
+	uid, err := parsePGUUID(event.UID)
+	if err != nil {
+		return queries.UpsertEventParams{}, fmt.Errorf("parsing event uid: %w", err)
+	}
+	// events.k8s.io clients fill eventTime and series instead of timestamps and count
+	first := event.FirstTimestamp.Time
+	if first.IsZero() {
+		first = event.EventTime.Time
+	}
+	if first.IsZero() {
+		first = event.CreationTimestamp.Time
+	}
+	last := event.LastTimestamp.Time
+	count := event.Count
+	if event.Series != nil {
+		last = event.Series.LastObservedTime.Time
+		count = event.Series.Count
+	}
+	if last.IsZero() {
+		last = first
+	}
+	if count < 1 {
+		count = 1
+	}
+	return queries.UpsertEventParams{
+		Uid:            uid,
+		Namespace:      event.Namespace,
+		Name:           event.Name,
+		InvolvedKind:   event.InvolvedObject.Kind,
+		InvolvedUid:    string(event.InvolvedObject.UID),
+		InvolvedName:   event.InvolvedObject.Name,
+		Type:           event.Type,
+		Reason:         event.Reason,
+		Message:        event.Message,
+		Count:          count,
+		FirstTimestamp: pgtype.Timestamptz{Time: first, Valid: true},
+		LastTimestamp:  pgtype.Timestamptz{Time: last, Valid: true},
+	}, nil
+}
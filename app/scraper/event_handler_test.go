@@ -0,0 +1,56 @@
+package scraper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEventParams(t *testing.T) {
+	first := time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC)
+	event := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			UID:       "2b2d1a43-4d0e-4d8a-9d8e-5e7f3a0c1d11",
+			Namespace: "default",
+			Name:      "app.17a",
+		},
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Name: "app", UID: "3c2d1a43-4d0e-4d8a-9d8e-5e7f3a0c1d11"},
+		Reason:         "Evicted",
+		Type:           v1.EventTypeWarning,
+		Count:          2,
+		FirstTimestamp: metav1.NewTime(first),
+		LastTimestamp:  metav1.NewTime(first.Add(time.Minute)),
+	}
+
+	params, err := eventParams(event)
+	require.NoError(t, err)
+	assert.Equal(t, "3c2d1a43-4d0e-4d8a-9d8e-5e7f3a0c1d11", params.InvolvedUid)
+	assert.Equal(t, int32(2), params.Count)
+	assert.Equal(t, first, params.FirstTimestamp.Time)
+	assert.Equal(t, first.Add(time.Minute), params.LastTimestamp.Time)
+
+	t.Run("events.k8s.io series", func(t *testing.T) {
+		event := event.DeepCopy()
+		event.Count = 0
+		event.FirstTimestamp = metav1.Time{}
+		event.LastTimestamp = metav1.Time{}
+		event.EventTime = metav1.NewMicroTime(first)
+		event.Series = &v1.EventSeries{Count: 5, LastObservedTime: metav1.NewMicroTime(first.Add(time.Hour))}
+		params, err := eventParams(event)
+		require.NoError(t, err)
+		assert.Equal(t, int32(5), params.Count)
+		assert.Equal(t, first, params.FirstTimestamp.Time)
+		assert.Equal(t, first.Add(time.Hour), params.LastTimestamp.Time)
+	})
+
+	t.Run("invalid uid", func(t *testing.T) {
+		event := event.DeepCopy()
+		event.UID = "invalid"
+		_, err := eventParams(event)
+		require.Error(t, err)
+	})
+}
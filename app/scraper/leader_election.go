@@ -0,0 +1,109 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	leaseDuration = 15 * time.Second
+	renewDeadline = 10 * time.Second
+	retryPeriod   = 2 * time.Second
+)
+
+type LeaderElectionOptions struct {
+	Enabled bool
+	// Namespace and Name of the Lease object
+	Namespace string
+	Name      string
+	// Identity of this replica, must be unique (e.g. pod name)
+	Identity string
+}
+
+// startLeaderElection runs start every time this replica becomes the leader
+// the context passed to start is cancelled when the leadership is lost, the replica then rejoins the election
+// when ctx is done the running term is stopped before the lease is released, buffered readings of the leader are
+// written before another replica takes over. A lease which failed to renew is released first, the elector cancels the
+// term only then
+func startLeaderElection(ctx context.Context, clientSet kubernetes.Interface, opts LeaderElectionOptions, start func(ctx context.Context) (stop func(), err error)) error {
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      opts.Name,
+			Namespace: opts.Namespace,
+		},
+		Client: clientSet.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: opts.Identity,
+		},
+	}
+	// the elector releases the lease as soon as its context is done, it's cancelled after the term is stopped
+	electionCtx, cancelElection := context.WithCancel(context.WithoutCancel(ctx))
+	var termMu sync.Mutex
+	var termDone chan struct{}
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   leaseDuration,
+		RenewDeadline:   renewDeadline,
+		RetryPeriod:     retryPeriod,
+		ReleaseOnCancel: true,
+		Name:            opts.Name,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leaderCtx context.Context) {
+				done := make(chan struct{})
+				defer close(done)
+				termMu.Lock()
+				termDone = done
+				termMu.Unlock()
+
+				termCtx, cancel := context.WithCancel(leaderCtx)
+				defer cancel()
+				stopAfter := context.AfterFunc(ctx, cancel)
+				defer stopAfter()
+
+				slog.Info("acquired leadership, starting scraper", "identity", opts.Identity)
+				stop, err := start(termCtx)
+				if err != nil {
+					slog.Error("starting scraper", "error", err)
+					return
+				}
+				<-termCtx.Done()
+				stop()
+			},
+			OnStoppedLeading: func() {
+				slog.Info("leadership lost, scraper stopped", "identity", opts.Identity)
+			},
+			OnNewLeader: func(identity string) {
+				slog.Info("new leader elected", "leader", identity)
+			},
+		},
+	})
+	if err != nil {
+		cancelElection()
+		return fmt.Errorf("creating leader elector: %w", err)
+	}
+	go func() {
+		<-ctx.Done()
+		termMu.Lock()
+		done := termDone
+		termMu.Unlock()
+		if done != nil {
+			<-done
+		}
+		cancelElection()
+	}()
+	go func() {
+		// Run returns when the leadership is lost, keep participating until the context is done
+		for ctx.Err() == nil {
+			elector.Run(electionCtx)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,99 @@
+package scraper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestStartLeaderElection(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	clientSet := fake.NewSimpleClientset()
+
+	started := make(chan string, 2)
+	elect := func(identity string) {
+		err := startLeaderElection(ctx, clientSet, LeaderElectionOptions{
+			Enabled:   true,
+			Namespace: "pgkube",
+			Name:      "pgkube",
+			Identity:  identity,
+		}, func(ctx context.Context) (func(), error) {
+			started <- identity
+			return func() {}, nil
+		})
+		require.NoError(t, err)
+	}
+	elect("replica-1")
+
+	select {
+	case identity := <-started:
+		assert.Equal(t, "replica-1", identity)
+	case <-time.After(10 * time.Second):
+		t.Fatal("leader was not elected")
+	}
+
+	elect("replica-2")
+	select {
+	case identity := <-started:
+		t.Fatalf("%s started while replica-1 holds the lease", identity)
+	case <-time.After(2 * retryPeriod):
+	}
+
+	lease, err := clientSet.CoordinationV1().Leases("pgkube").Get(ctx, "pgkube", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.NotNil(t, lease.Spec.HolderIdentity)
+	assert.Equal(t, "replica-1", *lease.Spec.HolderIdentity)
+}
+
+func TestStartLeaderElection_StopsBeforeRelease(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	clientSet := fake.NewSimpleClientset()
+	holder := func() string {
+		lease, err := clientSet.CoordinationV1().Leases("pgkube").Get(context.Background(), "pgkube", metav1.GetOptions{})
+		require.NoError(t, err)
+		if lease.Spec.HolderIdentity == nil {
+			return ""
+		}
+		return *lease.Spec.HolderIdentity
+	}
+
+	started := make(chan struct{})
+	stoppedBy := make(chan string, 1)
+	err := startLeaderElection(ctx, clientSet, LeaderElectionOptions{
+		Enabled:   true,
+		Namespace: "pgkube",
+		Name:      "pgkube",
+		Identity:  "replica-1",
+	}, func(ctx context.Context) (func(), error) {
+		close(started)
+		return func() {
+			// e.g. the write buffer is flushed while the lease is still held
+			time.Sleep(100 * time.Millisecond)
+			stoppedBy <- holder()
+		}, nil
+	})
+	require.NoError(t, err)
+	select {
+	case <-started:
+	case <-time.After(10 * time.Second):
+		t.Fatal("leader was not elected")
+	}
+
+	cancel()
+	select {
+	case identity := <-stoppedBy:
+		assert.Equal(t, "replica-1", identity, "the lease is released before the term is stopped")
+	case <-time.After(10 * time.Second):
+		t.Fatal("term was not stopped")
+	}
+	assert.Eventually(t, func() bool {
+		return holder() == ""
+	}, 10*time.Second, 10*time.Millisecond)
+}
@@ -4,15 +4,35 @@ import (
 	"context"
 	"log/slog"
 	"math/rand"
+	"sort"
 	"sync"
 	"time"
 )
 
+// maxBackoff limits the delay between scrapes of a failing target
+const maxBackoff = 10 * time.Minute
+
 type ScrapeFunc func(ctx context.Context) error
 
 type targetInfo struct {
 	scrapeFunc ScrapeFunc
 	cancel     context.CancelFunc
+	status     TargetStatus
+}
+
+// TargetStatus is the health of a single scraping target
+type TargetStatus struct {
+	ID                  string    `json:"id"`
+	LastScrape          time.Time `json:"lastScrape"`
+	LastSuccess         time.Time `json:"lastSuccess"`
+	LastError           string    `json:"lastError"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	LastDurationSeconds float64   `json:"lastDurationSeconds"`
+	NextScrape          time.Time `json:"nextScrape"`
+}
+
+func (s TargetStatus) Healthy() bool {
+	return s.ConsecutiveFailures == 0
 }
 
 type Manager struct {
@@ -41,30 +61,25 @@ func (m *Manager) AddTarget(id string, scrapeFunc ScrapeFunc, interval time.Dura
 
 	scrapeCtx, cancel := context.WithCancel(m.ctx)
 
-	m.targets[id] = &targetInfo{
+	target := &targetInfo{
 		scrapeFunc: scrapeFunc,
 		cancel:     cancel,
+		status:     TargetStatus{ID: id},
 	}
+	m.targets[id] = target
 
 	go func() {
 		if !m.disableScrapingDelay {
 			randomDelay(scrapeCtx, interval)
 		}
 
-		ticker := time.NewTicker(interval)
-		defer ticker.Stop()
 		// start first scrape immediately
-		if err := scrapeFunc(scrapeCtx); err != nil {
-			slog.Error("scraping target", "id", id, "error", err)
-		}
-
+		timer := time.NewTimer(0)
+		defer timer.Stop()
 		for {
 			select {
-			case <-ticker.C:
-				// Perform the scrape
-				if err := scrapeFunc(scrapeCtx); err != nil {
-					slog.Error("scraping target", "id", id, "error", err)
-				}
+			case <-timer.C:
+				timer.Reset(m.scrape(scrapeCtx, target, interval))
 			case <-scrapeCtx.Done():
 				slog.Info("target scraper cancelled", "id", id)
 				return
@@ -75,6 +90,72 @@ func (m *Manager) AddTarget(id string, scrapeFunc ScrapeFunc, interval time.Dura
 	slog.Info("new scraping target added", "id", id)
 }
 
+// scrape runs scrapeFunc, records the result and returns the delay until the next scrape
+func (m *Manager) scrape(ctx context.Context, target *targetInfo, interval time.Duration) time.Duration {
+	start := time.Now()
+	err := target.scrapeFunc(ctx)
+	duration := time.Since(start)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	status := &target.status
+	id := status.ID
+	status.LastScrape = start
+	status.LastDurationSeconds = duration.Seconds()
+	if err != nil {
+		status.ConsecutiveFailures++
+		status.LastError = err.Error()
+		slog.Error("scraping target", "id", id, "error", err, "consecutiveFailures", status.ConsecutiveFailures)
+	} else {
+		status.ConsecutiveFailures = 0
+		status.LastError = ""
+		status.LastSuccess = start
+	}
+	delay := backoff(interval, status.ConsecutiveFailures)
+	status.NextScrape = time.Now().Add(delay)
+	return delay
+}
+
+// backoff doubles the interval for every consecutive failure, up to maxBackoff
+func backoff(interval time.Duration, failures int) time.Duration {
+	if failures == 0 || interval >= maxBackoff {
+		return interval
+	}
+	delay := interval
+	for i := 0; i < failures && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		return maxBackoff
+	}
+	return delay
+}
+
+// Status returns the state of all targets sorted by id
+func (m *Manager) Status() []TargetStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make([]TargetStatus, 0, len(m.targets))
+	for _, target := range m.targets {
+		result = append(result, target.status)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// RemoveAll stops scraping all targets
+func (m *Manager) RemoveAll() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, target := range m.targets {
+		target.cancel()
+		delete(m.targets, id)
+	}
+	slog.Info("all scraping targets removed")
+}
+
 func randomDelay(ctx context.Context, maxInterval time.Duration) {
 	// nolint:gosec
 	initialDelay := time.Duration(rand.Int63n(int64(maxInterval)))
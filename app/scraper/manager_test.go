@@ -2,6 +2,7 @@ package scraper
 
 import (
 	"context"
+	"errors"
 	"github.com/stretchr/testify/require"
 	"testing"
 	"time"
@@ -88,6 +89,45 @@ func TestManager_MultipleTargets(t *testing.T) {
 	assert.Eventually(t, func() bool { return <-ch3 }, time.Millisecond*120, time.Millisecond*10)
 }
 
+func TestManager_Status(t *testing.T) {
+	ctx := Context(t)
+	m := NewManager(ctx, true)
+	ch := make(chan bool, 10)
+	m.AddTarget("failing", func(ctx context.Context) error {
+		ch <- true
+		return errors.New("connection refused")
+	}, time.Millisecond*20)
+	m.AddTarget("healthy", mockScrapeFunc(ch), time.Millisecond*20)
+
+	assert.Eventually(t, func() bool {
+		status := m.Status()
+		return len(status) == 2 && !status[0].LastScrape.IsZero() && !status[1].LastSuccess.IsZero()
+	}, time.Second, time.Millisecond*10)
+
+	status := m.Status()
+	assert.Equal(t, "failing", status[0].ID)
+	assert.False(t, status[0].Healthy())
+	assert.Equal(t, "connection refused", status[0].LastError)
+	assert.True(t, status[0].LastSuccess.IsZero())
+	assert.GreaterOrEqual(t, status[0].ConsecutiveFailures, 1)
+
+	assert.Equal(t, "healthy", status[1].ID)
+	assert.True(t, status[1].Healthy())
+	assert.Empty(t, status[1].LastError)
+
+	m.RemoveAll()
+	assert.Empty(t, m.Status())
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, time.Minute, backoff(time.Minute, 0))
+	assert.Equal(t, 2*time.Minute, backoff(time.Minute, 1))
+	assert.Equal(t, 8*time.Minute, backoff(time.Minute, 3))
+	assert.Equal(t, maxBackoff, backoff(time.Minute, 4))
+	assert.Equal(t, maxBackoff, backoff(time.Minute, 1000))
+	assert.Equal(t, time.Hour, backoff(time.Hour, 2))
+}
+
 func CreateDB(t *testing.T) *pgx.Conn {
 	return test.CreateTestDB(t, "../migrations")
 }
@@ -9,6 +9,8 @@ import (
 
 	"github.com/jackc/pgx/v5/pgtype"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
 
 	listerv1 "k8s.io/client-go/listers/core/v1"
 
@@ -21,49 +23,101 @@ type PodLister listerv1.PodLister
 type PodNamespaceLister listerv1.PodNamespaceLister
 
 type PodCache interface {
-	Get(namespace, name string) (*v1.Pod, error)
+	Get(namespace, name string) (*Pod, error)
 }
 
-// PodCacheK8s is a wrapper around listerv1.PodLister
-// original client is hard to mock
+// PodCacheK8s reads pods from the cache of the pod informer, they are converted by transformPod
+// original lister is hard to mock
 // wrapper provides an easier to use interface
 type PodCacheK8s struct {
-	lister listerv1.PodLister
+	indexer cache.Indexer
 }
 
-func NewPodCacheK8s(lister listerv1.PodLister) *PodCacheK8s {
+var _ k8s.PodLister = &PodCacheK8s{}
+
+func NewPodCacheK8s(indexer cache.Indexer) *PodCacheK8s {
 	return &PodCacheK8s{
-		lister: lister,
+		indexer: indexer,
 	}
 }
 
-func (p *PodCacheK8s) Get(namespace, name string) (*v1.Pod, error) {
-	pod, err := p.lister.Pods(namespace).Get(name)
+func (p *PodCacheK8s) Get(namespace, name string) (*Pod, error) {
+	obj, exists, err := p.indexer.GetByKey(namespace + "/" + name)
 	if err != nil {
 		return nil, fmt.Errorf("getting pod from cache: %w", err)
 	}
+	if !exists {
+		return nil, fmt.Errorf("getting pod from cache: pod %s/%s not found", namespace, name)
+	}
+	pod, ok := obj.(*Pod)
+	if !ok {
+		return nil, fmt.Errorf("getting pod from cache: unexpected object type: %T", obj)
+	}
 	return pod, nil
 }
 
+// List returns pods matching the label selector
+func (p *PodCacheK8s) List(selector labels.Selector) ([]*v1.Pod, error) {
+	var result []*v1.Pod
+	for _, obj := range p.indexer.List() {
+		pod, ok := obj.(*Pod)
+		if ok && selector.Matches(labels.Set(pod.Labels)) {
+			result = append(result, pod.Pod)
+		}
+	}
+	return result, nil
+}
+
+type PVCCache interface {
+	Get(namespace, name string) (*v1.PersistentVolumeClaim, error)
+}
+
+type PVCCacheK8s struct {
+	lister listerv1.PersistentVolumeClaimLister
+}
+
+func NewPVCCacheK8s(lister listerv1.PersistentVolumeClaimLister) *PVCCacheK8s {
+	return &PVCCacheK8s{
+		lister: lister,
+	}
+}
+
+func (p *PVCCacheK8s) Get(namespace, name string) (*v1.PersistentVolumeClaim, error) {
+	pvc, err := p.lister.PersistentVolumeClaims(namespace).Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("getting pvc from cache: %w", err)
+	}
+	return pvc, nil
+}
+
 type NodeScraper struct {
-	nodeName            string
-	k8sClients          k8s.ClientInterface
-	queries             *queries.Queries
-	prevCPUSecondsTotal k8s.PodMetric
-	prevCores           k8s.PodMetric
-	mutex               sync.Mutex
-	cache               PodCache
+	nodeName                     string
+	k8sClients                   k8s.ClientInterface
+	usageWriter                  PodUsageWriter
+	prevCPUSecondsTotal          k8s.PodMetric
+	prevCores                    k8s.PodMetric
+	prevContainerCPUSecondsTotal k8s.ContainerMetric
+	prevContainerCores           k8s.ContainerMetric
+	prevCadvisor                 k8s.CadvisorMetrics
+	mutex                        sync.Mutex
+	cache                        PodCache
+	pvcCache                     PVCCache
+	opts                         Options
 }
 
-func NewNodeScrapper(name string, k8sClients k8s.ClientInterface, queries *queries.Queries, cache PodCache) *NodeScraper {
+func NewNodeScrapper(name string, k8sClients k8s.ClientInterface, usageWriter PodUsageWriter, cache PodCache, pvcCache PVCCache, opts Options) *NodeScraper {
 	return &NodeScraper{
-		nodeName:            name,
-		k8sClients:          k8sClients,
-		queries:             queries,
-		prevCPUSecondsTotal: make(k8s.PodMetric),
-		prevCores:           make(k8s.PodMetric),
-		mutex:               sync.Mutex{},
-		cache:               cache,
+		nodeName:                     name,
+		k8sClients:                   k8sClients,
+		usageWriter:                  usageWriter,
+		prevCPUSecondsTotal:          make(k8s.PodMetric),
+		prevCores:                    make(k8s.PodMetric),
+		prevContainerCPUSecondsTotal: make(k8s.ContainerMetric),
+		prevContainerCores:           make(k8s.ContainerMetric),
+		mutex:                        sync.Mutex{},
+		cache:                        cache,
+		pvcCache:                     pvcCache,
+		opts:                         opts,
 	}
 }
 
@@ -72,9 +126,9 @@ func (s *NodeScraper) Scrape(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	cpuData := s.cpuData(metrics.PodCPUUsageSecondsTotal)
+	cpuData := s.cpuData(metrics)
 	if len(cpuData) > 0 {
-		if err := s.queries.UpsertPodUsedCPU(ctx, cpuData); err != nil {
+		if err := s.usageWriter.UpsertPodUsedCPU(ctx, cpuData); err != nil {
 			return fmt.Errorf("upserting pod used cpu: %w", err)
 		}
 		slog.Debug("updated pod CPU usage", "node", s.nodeName, "count", len(cpuData))
@@ -82,119 +136,420 @@ func (s *NodeScraper) Scrape(ctx context.Context) error {
 
 	memoryData := s.memoryData(metrics.PodMemoryWorkingSetBytes)
 	if len(memoryData) > 0 {
-		if err := s.queries.UpsertPodUsedMemory(ctx, memoryData); err != nil {
+		if err := s.usageWriter.UpsertPodUsedMemory(ctx, memoryData); err != nil {
 			return fmt.Errorf("upserting pod used memory: %w", err)
 		}
 		slog.Debug("updated pod memory usage", "node", s.nodeName, "count", len(memoryData))
 	}
 
+	requestData := s.requestData(metrics.PodMemoryWorkingSetBytes)
+	if len(requestData) > 0 {
+		if err := s.usageWriter.UpsertPodRequests(ctx, requestData); err != nil {
+			return fmt.Errorf("upserting pod requests: %w", err)
+		}
+		slog.Debug("updated pod requests", "node", s.nodeName, "count", len(requestData))
+	}
+
+	containerCPUData := s.containerCPUData(metrics)
+	if len(containerCPUData) > 0 {
+		if err := s.usageWriter.UpsertContainerUsedCPU(ctx, containerCPUData); err != nil {
+			return fmt.Errorf("upserting container used cpu: %w", err)
+		}
+		slog.Debug("updated container CPU usage", "node", s.nodeName, "count", len(containerCPUData))
+	}
+
+	containerMemoryData := s.containerMemoryData(metrics.ContainerMemoryWorkingSetBytes)
+	if len(containerMemoryData) > 0 {
+		if err := s.usageWriter.UpsertContainerUsedMemory(ctx, containerMemoryData); err != nil {
+			return fmt.Errorf("upserting container used memory: %w", err)
+		}
+		slog.Debug("updated container memory usage", "node", s.nodeName, "count", len(containerMemoryData))
+	}
+
+	// cadvisor metrics and volume stats are optional, usage is already written and the scrape doesn't fail without them
+	if s.opts.ScrapeCadvisor {
+		if err := s.ScrapeCadvisor(ctx); err != nil {
+			slog.Error("scraping cadvisor metrics", "node", s.nodeName, "error", err)
+		}
+	}
+	if s.opts.ScrapeVolumeStats {
+		if err := s.ScrapeVolumeStats(ctx); err != nil {
+			slog.Error("scraping volume stats", "node", s.nodeName, "error", err)
+		}
+	}
 	return nil
 }
 
-func (s *NodeScraper) cpuData(currentCPUSecondsTotal k8s.PodMetric) []queries.UpsertPodUsedCPUParams {
+// ScrapeVolumeStats collects used and capacity bytes of persistent volume claims and ephemeral storage used by pods
+// from the kubelet stats summary
+func (s *NodeScraper) ScrapeVolumeStats(ctx context.Context) error {
+	stats, err := s.k8sClients.NodeVolumeStats(ctx, s.nodeName)
+	if err != nil {
+		return err
+	}
+	pvcData := make([]queries.UpsertPVCUsageParams, 0, len(stats.PVCs))
+	for key, value := range stats.PVCs {
+		pvc, err := s.pvcCache.Get(key.Namespace, key.Name)
+		if err != nil {
+			slog.Error("could not find pvc in cache", "namespace", key.Namespace, "name", key.Name)
+			continue
+		}
+		pgUUID, err := parsePGUUID(pvc.UID)
+		if err != nil {
+			slog.Error("parsing uuid", "error", err)
+			continue
+		}
+		pvcData = append(pvcData, queries.UpsertPVCUsageParams{
+			Timestamp:     hourTimestamp(value.TimestampMs),
+			PvcUid:        pgUUID,
+			UsedBytes:     value.UsedBytes,
+			CapacityBytes: value.CapacityBytes,
+		})
+	}
+	if len(pvcData) > 0 {
+		if err := s.usageWriter.UpsertPVCUsage(ctx, pvcData); err != nil {
+			return fmt.Errorf("upserting pvc usage: %w", err)
+		}
+		slog.Debug("updated pvc usage", "node", s.nodeName, "count", len(pvcData))
+	}
+
+	ephemeralData := s.ephemeralStorageData(stats.PodEphemeralStorageBytes)
+	if len(ephemeralData) > 0 {
+		if err := s.usageWriter.UpsertPodEphemeralStorage(ctx, ephemeralData); err != nil {
+			return fmt.Errorf("upserting pod ephemeral storage: %w", err)
+		}
+		slog.Debug("updated pod ephemeral storage usage", "node", s.nodeName, "count", len(ephemeralData))
+	}
+	return nil
+}
+
+func (s *NodeScraper) ephemeralStorageData(current k8s.PodMetric) []queries.UpsertPodEphemeralStorageParams {
+	result := make([]queries.UpsertPodEphemeralStorageParams, 0, len(current))
+	for key, value := range current {
+		pgUUID, ok := s.podUID(key)
+		if !ok {
+			continue
+		}
+		result = append(result, queries.UpsertPodEphemeralStorageParams{
+			Timestamp: hourTimestamp(value.TimestampMs),
+			PodUid:    pgUUID,
+			UsedBytes: value.Value,
+		})
+	}
+	return result
+}
+
+// ScrapeCadvisor collects network, filesystem and CPU throttling metrics from the kubelet cAdvisor endpoint
+func (s *NodeScraper) ScrapeCadvisor(ctx context.Context) error {
+	metrics, err := s.k8sClients.NodeCadvisorMetrics(ctx, s.nodeName)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	networkData := s.networkData(metrics)
+	throttlingData := s.cpuThrottlingData(metrics)
+	s.prevCadvisor = metrics
+	s.mutex.Unlock()
+
+	if len(networkData) > 0 {
+		if err := s.usageWriter.UpsertPodNetwork(ctx, networkData); err != nil {
+			return fmt.Errorf("upserting pod network: %w", err)
+		}
+		slog.Debug("updated pod network usage", "node", s.nodeName, "count", len(networkData))
+	}
+
+	if len(throttlingData) > 0 {
+		if err := s.usageWriter.UpsertPodCPUThrottling(ctx, throttlingData); err != nil {
+			return fmt.Errorf("upserting pod cpu throttling: %w", err)
+		}
+		slog.Debug("updated pod CPU throttling", "node", s.nodeName, "count", len(throttlingData))
+	}
+
+	fsData := s.fsUsageData(metrics.PodFsUsageBytes)
+	if len(fsData) > 0 {
+		if err := s.usageWriter.UpsertPodFsUsage(ctx, fsData); err != nil {
+			return fmt.Errorf("upserting pod filesystem usage: %w", err)
+		}
+		slog.Debug("updated pod filesystem usage", "node", s.nodeName, "count", len(fsData))
+	}
+	return nil
+}
+
+func (s *NodeScraper) networkData(current k8s.CadvisorMetrics) []queries.UpsertPodNetworkParams {
+	received := counterDelta(s.prevCadvisor.PodNetworkReceiveBytesTotal, current.PodNetworkReceiveBytesTotal)
+	transmitted := counterDelta(s.prevCadvisor.PodNetworkTransmitBytesTotal, current.PodNetworkTransmitBytesTotal)
+	result := make([]queries.UpsertPodNetworkParams, 0, len(received))
+	for key := range mergeKeys(received, transmitted) {
+		pgUUID, ok := s.podUID(key)
+		if !ok {
+			continue
+		}
+		result = append(result, queries.UpsertPodNetworkParams{
+			Timestamp:            hourTimestamp(max(received[key].TimestampMs, transmitted[key].TimestampMs)),
+			PodUid:               pgUUID,
+			NetworkReceiveBytes:  received[key].Value,
+			NetworkTransmitBytes: transmitted[key].Value,
+		})
+	}
+	return result
+}
+
+func (s *NodeScraper) cpuThrottlingData(current k8s.CadvisorMetrics) []queries.UpsertPodCPUThrottlingParams {
+	periods := counterDelta(s.prevCadvisor.PodCPUCFSPeriodsTotal, current.PodCPUCFSPeriodsTotal)
+	throttled := counterDelta(s.prevCadvisor.PodCPUCFSThrottledPeriodsTotal, current.PodCPUCFSThrottledPeriodsTotal)
+	result := make([]queries.UpsertPodCPUThrottlingParams, 0, len(periods))
+	for key := range mergeKeys(periods, throttled) {
+		pgUUID, ok := s.podUID(key)
+		if !ok {
+			continue
+		}
+		result = append(result, queries.UpsertPodCPUThrottlingParams{
+			Timestamp:              hourTimestamp(max(periods[key].TimestampMs, throttled[key].TimestampMs)),
+			PodUid:                 pgUUID,
+			CpuCfsPeriods:          periods[key].Value,
+			CpuCfsThrottledPeriods: throttled[key].Value,
+		})
+	}
+	return result
+}
+
+func (s *NodeScraper) fsUsageData(current k8s.PodMetric) []queries.UpsertPodFsUsageParams {
+	result := make([]queries.UpsertPodFsUsageParams, 0, len(current))
+	for key, value := range current {
+		pgUUID, ok := s.podUID(key)
+		if !ok {
+			continue
+		}
+		result = append(result, queries.UpsertPodFsUsageParams{
+			Timestamp:    hourTimestamp(value.TimestampMs),
+			PodUid:       pgUUID,
+			FsUsageBytes: value.Value,
+		})
+	}
+	return result
+}
+
+// podUID resolves pod uid using the pod cache, errors are logged
+func (s *NodeScraper) podUID(key k8s.PodKey) (pgtype.UUID, bool) {
+	pod, err := s.cache.Get(key.Namespace, key.Name)
+	if err != nil {
+		slog.Error("could not find pod in cache", "namespace", key.Namespace, "name", key.Name)
+		return pgtype.UUID{}, false
+	}
+	pgUUID, err := parsePGUUID(pod.UID)
+	if err != nil {
+		slog.Error("parsing uuid", "error", err)
+		return pgtype.UUID{}, false
+	}
+	return pgUUID, true
+}
+
+func hourTimestamp(timestampMs int64) pgtype.Timestamptz {
+	return pgtype.Timestamptz{
+		Time:  truncateToHour(time.UnixMilli(timestampMs)).UTC(),
+		Valid: true,
+	}
+}
+
+// sampleTimestamp keeps the time of the reading, pod usage is truncated to the hour when it's stored in hourly rows
+// and kept as is in raw samples
+func sampleTimestamp(timestampMs int64) pgtype.Timestamptz {
+	return pgtype.Timestamptz{
+		Time:  time.UnixMilli(timestampMs).UTC(),
+		Valid: true,
+	}
+}
+
+// counterDelta returns the increase of counters since the previous scrape
+// counters seen for the first time or reset since the previous scrape are skipped
+func counterDelta(prev, current k8s.PodMetric) k8s.PodMetric {
+	result := make(k8s.PodMetric, len(current))
+	for key, value := range current {
+		prevValue, ok := prev[key]
+		if !ok || value.Value < prevValue.Value {
+			continue
+		}
+		result[key] = k8s.MetricValue{
+			Value:       value.Value - prevValue.Value,
+			TimestampMs: value.TimestampMs,
+		}
+	}
+	return result
+}
+
+func mergeKeys(metrics ...k8s.PodMetric) map[k8s.PodKey]struct{} {
+	result := make(map[k8s.PodKey]struct{})
+	for _, metric := range metrics {
+		for key := range metric {
+			result[key] = struct{}{}
+		}
+	}
+	return result
+}
+
+func (s *NodeScraper) cpuData(metrics k8s.NodeMetrics) []queries.UpsertPodUsedCPUParams {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	podCores := metrics.PodCPUUsageCores
+	if podCores == nil {
+		podCores = cpuCores(s.prevCPUSecondsTotal, s.prevCores, metrics.PodCPUUsageSecondsTotal)
+		s.prevCPUSecondsTotal = metrics.PodCPUUsageSecondsTotal
+		s.prevCores = podCores
+	}
+
+	result := make([]queries.UpsertPodUsedCPUParams, 0, len(podCores))
+	for key, value := range podCores {
+		pgUUID, ok := s.podUID(key)
+		if !ok {
+			continue
+		}
+
+		result = append(result, queries.UpsertPodUsedCPUParams{
+			Timestamp: sampleTimestamp(value.TimestampMs),
+			PodUid:    pgUUID,
+			CpuCores:  value.Value,
+		})
+	}
+	return result
+}
+
+func (s *NodeScraper) containerCPUData(metrics k8s.NodeMetrics) []queries.UpsertContainerUsedCPUParams {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	containerCores := metrics.ContainerCPUUsageCores
+	if containerCores == nil {
+		containerCores = cpuCores(s.prevContainerCPUSecondsTotal, s.prevContainerCores, metrics.ContainerCPUUsageSecondsTotal)
+		s.prevContainerCPUSecondsTotal = metrics.ContainerCPUUsageSecondsTotal
+		s.prevContainerCores = containerCores
+	}
+
+	result := make([]queries.UpsertContainerUsedCPUParams, 0, len(containerCores))
+	for key, value := range containerCores {
+		pgUUID, ok := s.podUID(key.Pod)
+		if !ok {
+			continue
+		}
+
+		result = append(result, queries.UpsertContainerUsedCPUParams{
+			Timestamp:     hourTimestamp(value.TimestampMs),
+			PodUid:        pgUUID,
+			ContainerName: key.Container,
+			CpuCores:      value.Value,
+		})
+	}
+	return result
+}
+
+// cpuCores converts cumulative cpu seconds into average cores used since the previous scrape
+func cpuCores[K comparable](prevCPUSecondsTotal, prevCores, currentCPUSecondsTotal map[K]k8s.MetricValue) map[K]k8s.MetricValue {
 	// cpu usage is reported in total seconds consumed by the pod
 	// in order to calculate avg core/sec we need to calculate the difference between current and previous value
 	// cpu usage is calculated as (current - previous) / (current timestamp - previous timestamp)
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-	podCores := make(k8s.PodMetric, len(currentCPUSecondsTotal))
+	cores := make(map[K]k8s.MetricValue, len(currentCPUSecondsTotal))
 	for key, value := range currentCPUSecondsTotal {
-		prevValue, ok := s.prevCPUSecondsTotal[key]
+		prevValue, ok := prevCPUSecondsTotal[key]
 		if !ok {
 			continue
 		}
-		var cores float64
 		if prevValue.TimestampMs != value.TimestampMs {
-			cores = (value.Value - prevValue.Value) / float64((value.TimestampMs-prevValue.TimestampMs)/1000)
-			podCores[key] = k8s.MetricValue{
-				Value:       cores,
+			cores[key] = k8s.MetricValue{
+				Value:       (value.Value - prevValue.Value) / float64((value.TimestampMs-prevValue.TimestampMs)/1000),
 				TimestampMs: value.TimestampMs,
 			}
 		} else {
 			// if previous timestamp is the same as current it means metrics server hasn't updated the usage yet
 			// so we don't know the current usage yet, use previous value
-			value, ok = s.prevCores[key]
+			value, ok = prevCores[key]
 			if !ok {
 				continue
 			}
-			podCores[key] = value
+			cores[key] = value
 		}
 	}
+	return cores
+}
 
-	result := make([]queries.UpsertPodUsedCPUParams, 0, len(podCores))
-	for key, value := range podCores {
-		pod, err := s.cache.Get(key.Namespace, key.Name)
-		if err != nil {
-			slog.Error("could not find pod in cache", "namespace", key.Namespace, "name", key.Name)
-			continue
-		}
-
-		pgUUID, err := parsePGUUID(pod.UID)
-		if err != nil {
-			slog.Error("parsing uuid", "error", err)
+func (s *NodeScraper) memoryData(currentPodMemoryUsed k8s.PodMetric) []queries.UpsertPodUsedMemoryParams {
+	result := make([]queries.UpsertPodUsedMemoryParams, 0, len(currentPodMemoryUsed))
+	for key, value := range currentPodMemoryUsed {
+		pgUUID, ok := s.podUID(key)
+		if !ok {
 			continue
 		}
-
-		result = append(result, queries.UpsertPodUsedCPUParams{
-			Timestamp: pgtype.Timestamptz{
-				Time:  truncateToHour(time.UnixMilli(value.TimestampMs)).UTC(),
-				Valid: true,
-			},
-			PodUid:   pgUUID,
-			CpuCores: value.Value,
+		result = append(result, queries.UpsertPodUsedMemoryParams{
+			Timestamp:   sampleTimestamp(value.TimestampMs),
+			PodUid:      pgUUID,
+			MemoryBytes: value.Value,
 		})
 	}
-	s.prevCPUSecondsTotal = currentCPUSecondsTotal
-	s.prevCores = podCores
 	return result
 }
 
-func (s *NodeScraper) memoryData(currentPodMemoryUsed k8s.PodMetric) []queries.UpsertPodUsedMemoryParams {
-	result := make([]queries.UpsertPodUsedMemoryParams, 0, len(currentPodMemoryUsed))
+// requestData records requests of pods with usage, requests can change during the pod lifetime with in-place resize
+func (s *NodeScraper) requestData(currentPodMemoryUsed k8s.PodMetric) []queries.UpsertPodRequestParams {
+	result := make([]queries.UpsertPodRequestParams, 0, len(currentPodMemoryUsed))
 	for key, value := range currentPodMemoryUsed {
+		// missing pods are logged by memoryData
 		pod, err := s.cache.Get(key.Namespace, key.Name)
 		if err != nil {
-			slog.Error("could not find pod in cache", "namespace", key.Namespace, "name", key.Name)
 			continue
 		}
 		pgUUID, err := parsePGUUID(pod.UID)
 		if err != nil {
-			slog.Error("parsing uuid", "error", err)
 			continue
 		}
-		result = append(result, queries.UpsertPodUsedMemoryParams{
-			Timestamp: pgtype.Timestamptz{
-				Time:  truncateToHour(time.UnixMilli(value.TimestampMs)).UTC(),
-				Valid: true,
-			},
+		cpuCores, memoryBytes := podRequests(pod)
+		result = append(result, queries.UpsertPodRequestParams{
+			Timestamp:   hourTimestamp(value.TimestampMs),
 			PodUid:      pgUUID,
-			MemoryBytes: value.Value,
+			CpuCores:    cpuCores,
+			MemoryBytes: memoryBytes,
+		})
+	}
+	return result
+}
+
+func (s *NodeScraper) containerMemoryData(currentContainerMemoryUsed k8s.ContainerMetric) []queries.UpsertContainerUsedMemoryParams {
+	result := make([]queries.UpsertContainerUsedMemoryParams, 0, len(currentContainerMemoryUsed))
+	for key, value := range currentContainerMemoryUsed {
+		pgUUID, ok := s.podUID(key.Pod)
+		if !ok {
+			continue
+		}
+		result = append(result, queries.UpsertContainerUsedMemoryParams{
+			Timestamp:     hourTimestamp(value.TimestampMs),
+			PodUid:        pgUUID,
+			ContainerName: key.Container,
+			MemoryBytes:   value.Value,
 		})
 	}
 	return result
 }
 
 type NodeEventHandler struct {
-	manager   *Manager
-	k8sClient k8s.ClientInterface
-	queries   *queries.Queries
-	interval  time.Duration
-	cache     PodCache
+	manager     *Manager
+	k8sClient   k8s.ClientInterface
+	usageWriter PodUsageWriter
+	cache       PodCache
+	pvcCache    PVCCache
+	opts        Options
 }
 
 func NewNodeEventHandler(
 	manager *Manager,
 	k8sClient k8s.ClientInterface,
-	queries *queries.Queries,
-	interval time.Duration,
+	usageWriter PodUsageWriter,
 	cache PodCache,
+	pvcCache PVCCache,
+	opts Options,
 ) *NodeEventHandler {
 	return &NodeEventHandler{
-		manager:   manager,
-		k8sClient: k8sClient,
-		queries:   queries,
-		interval:  interval,
-		cache:     cache,
+		manager:     manager,
+		k8sClient:   k8sClient,
+		usageWriter: usageWriter,
+		cache:       cache,
+		pvcCache:    pvcCache,
+		opts:        opts,
 	}
 }
 
@@ -208,9 +563,9 @@ func (h *NodeEventHandler) OnAdd(obj interface{}, isInInitialList bool) {
 		slog.Error("node name is empty")
 		return
 	}
-	nodeScraper := NewNodeScrapper(node.Name, h.k8sClient, h.queries, h.cache)
+	nodeScraper := NewNodeScrapper(node.Name, h.k8sClient, h.usageWriter, h.cache, h.pvcCache, h.opts)
 	targetID := "node/" + node.Name
-	h.manager.AddTarget(targetID, nodeScraper.Scrape, h.interval)
+	h.manager.AddTarget(targetID, nodeScraper.Scrape, h.opts.Interval)
 }
 
 func (h *NodeEventHandler) OnUpdate(oldObj, obj interface{}) {}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/stretchr/testify/assert"
@@ -14,9 +15,11 @@ import (
 	"k8s.io/apimachinery/pkg/util/uuid"
 
 	"github.com/r2k1/pgkube/app/k8s"
+	"github.com/r2k1/pgkube/app/queries"
 )
 
 //go:generate moq -out pod_cache_moq_test.go . PodCache
+//go:generate moq -out pvc_cache_moq_test.go . PVCCache
 
 func TestNodeScraper_Scrape(t *testing.T) {
 	ctx := Context(t)
@@ -29,7 +32,7 @@ func TestNodeScraper_Scrape(t *testing.T) {
 			},
 		}
 		queries := Queries(t)
-		scraper := NewNodeScrapper("test-node", client, queries, &PodCacheMock{})
+		scraper := NewNodeScrapper("test-node", client, queries, &PodCacheMock{}, nil, Options{})
 
 		err := scraper.Scrape(ctx)
 		require.Error(t, err)
@@ -55,17 +58,17 @@ func TestNodeScraper_Scrape(t *testing.T) {
 		queries := Queries(t)
 		k8suid, pguuid := RandomUUID(t)
 		cache := &PodCacheMock{
-			GetFunc: func(namespace string, name string) (*v1.Pod, error) {
-				return &v1.Pod{
+			GetFunc: func(namespace string, name string) (*Pod, error) {
+				return &Pod{Pod: &v1.Pod{
 					ObjectMeta: metav1.ObjectMeta{
 						UID:       k8suid,
 						Namespace: "test-namespace",
 						Name:      "test-pod",
 					},
-				}, nil
+				}}, nil
 			},
 		}
-		scraper := NewNodeScrapper("test-node", client, queries, cache)
+		scraper := NewNodeScrapper("test-node", client, queries, cache, nil, Options{})
 
 		err := scraper.Scrape(ctx)
 		require.NoError(t, err)
@@ -125,11 +128,11 @@ func TestNodeScraper_Scrape(t *testing.T) {
 		}
 		queries := Queries(t)
 		cache := &PodCacheMock{
-			GetFunc: func(namespace string, name string) (*v1.Pod, error) {
+			GetFunc: func(namespace string, name string) (*Pod, error) {
 				return nil, errors.New("pod not found")
 			},
 		}
-		scraper := NewNodeScrapper("test-node", client, queries, cache)
+		scraper := NewNodeScrapper("test-node", client, queries, cache, nil, Options{})
 
 		err := scraper.Scrape(ctx)
 		require.NoError(t, err)
@@ -160,18 +163,18 @@ func TestNodeScraper_Scrape(t *testing.T) {
 		queries := Queries(t)
 		k8suid, pguuid := RandomUUID(t)
 		cache := &PodCacheMock{
-			GetFunc: func(namespace string, name string) (*v1.Pod, error) {
-				return &v1.Pod{
+			GetFunc: func(namespace string, name string) (*Pod, error) {
+				return &Pod{Pod: &v1.Pod{
 					ObjectMeta: metav1.ObjectMeta{
 						UID:       k8suid,
 						Namespace: "test-namespace",
 						Name:      "test-pod",
 					},
-				}, nil
+				}}, nil
 			},
 		}
 
-		scraper := NewNodeScrapper("test-node", client, queries, cache)
+		scraper := NewNodeScrapper("test-node", client, queries, cache, nil, Options{})
 
 		err := scraper.Scrape(ctx)
 		require.NoError(t, err)
@@ -198,6 +201,310 @@ func TestNodeScraper_Scrape(t *testing.T) {
 		scrapeAndAssertCPU(10.0, 20.0, float64(20+20+10)/3)
 		scrapeAndAssertCPU(10.0, 20.0, float64(20+20+10+10)/4)
 	})
+
+	t.Run("raw samples keep the minute of the reading", func(t *testing.T) {
+		t.Parallel()
+		key := k8s.PodKey{Name: "test-pod", Namespace: "test-namespace"}
+		now := time.Now().Truncate(time.Minute)
+		readings := []time.Time{now.Add(-5*time.Minute + 10*time.Second), now.Add(-3*time.Minute + 10*time.Second)}
+		callindex := -1
+		client := &k8s.ClientMock{
+			NodeMetricsFunc: func(ctx context.Context, nodeName string) (k8s.NodeMetrics, error) {
+				callindex++
+				return k8s.NodeMetrics{
+					PodMemoryWorkingSetBytes: k8s.PodMetric{key: {Value: 100, TimestampMs: readings[callindex].UnixMilli()}},
+				}, nil
+			},
+		}
+		q := Queries(t)
+		pod := &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{UID: uuid.NewUUID(), Namespace: "test-namespace", Name: "test-pod"},
+			Status:     v1.PodStatus{StartTime: &metav1.Time{Time: now.Add(-time.Hour)}},
+		}
+		require.NoError(t, q.UpsertObject(ctx, "Pod", pod))
+		cache := &PodCacheMock{
+			GetFunc: func(namespace string, name string) (*Pod, error) {
+				return &Pod{Pod: pod}, nil
+			},
+		}
+		scraper := NewNodeScrapper("test-node", client, NewRawUsageWriter(q), cache, nil, Options{})
+
+		require.NoError(t, scraper.Scrape(ctx))
+		require.NoError(t, scraper.Scrape(ctx))
+
+		result, err := q.WorkloadAgg(ctx, queries.WorkloadAggRequest{
+			Cols:        []string{"timestamp", "name", "hours"},
+			OrderBy:     "timestamp",
+			Start:       now.Add(-time.Hour),
+			End:         now,
+			Granularity: queries.GranularityMinute,
+		})
+		require.NoError(t, err)
+		// each sample is billed for its own minute
+		require.Len(t, result.Rows, 2)
+		assert.Equal(t, "0.02", result.Rows[0][2])
+		assert.Equal(t, "0.02", result.Rows[1][2])
+	})
+
+	t.Run("update container usage", func(t *testing.T) {
+		t.Parallel()
+		key := k8s.ContainerKey{Pod: k8s.PodKey{Name: "test-pod", Namespace: "test-namespace"}, Container: "sidecar"}
+		data := []k8s.ContainerMetric{
+			{key: k8s.MetricValue{Value: 10, TimestampMs: 0}},
+			{key: k8s.MetricValue{Value: 15, TimestampMs: 10000}}, // 0.5 cores/sec
+		}
+		callindex := -1
+		client := &k8s.ClientMock{
+			NodeMetricsFunc: func(ctx context.Context, nodeName string) (k8s.NodeMetrics, error) {
+				callindex++
+				return k8s.NodeMetrics{
+					ContainerCPUUsageSecondsTotal:  data[callindex],
+					ContainerMemoryWorkingSetBytes: k8s.ContainerMetric{key: k8s.MetricValue{Value: 100, TimestampMs: 10000}},
+				}, nil
+			},
+		}
+		queries := Queries(t)
+		k8suid, pguuid := RandomUUID(t)
+		cache := &PodCacheMock{
+			GetFunc: func(namespace string, name string) (*Pod, error) {
+				return &Pod{Pod: &v1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						UID:       k8suid,
+						Namespace: "test-namespace",
+						Name:      "test-pod",
+					},
+				}}, nil
+			},
+		}
+		scraper := NewNodeScrapper("test-node", client, queries, cache, nil, Options{})
+
+		require.NoError(t, scraper.Scrape(ctx))
+		require.NoError(t, scraper.Scrape(ctx))
+
+		query, err := queries.ListContainerUsageHourly(ctx)
+		require.NoError(t, err)
+		require.Len(t, query, 1)
+		assert.Equal(t, pguuid, query[0].PodUid)
+		assert.Equal(t, "sidecar", query[0].ContainerName)
+		assert.InDelta(t, 0.5, query[0].CpuCoresAvg, 0.0001)
+		assert.InDelta(t, 100.0, query[0].MemoryBytesAvg, 0.0001)
+
+		pods, err := queries.ListPodUsageHourly(ctx)
+		require.NoError(t, err)
+		require.Empty(t, pods)
+	})
+
+	t.Run("update cpu usage from rates", func(t *testing.T) {
+		t.Parallel()
+		key := k8s.PodKey{Name: "test-pod", Namespace: "test-namespace"}
+		containerKey := k8s.ContainerKey{Pod: key, Container: "main"}
+		client := &k8s.ClientMock{
+			NodeMetricsFunc: func(ctx context.Context, nodeName string) (k8s.NodeMetrics, error) {
+				return k8s.NodeMetrics{
+					PodCPUUsageCores:               k8s.PodMetric{key: {Value: 0.25, TimestampMs: 0}},
+					PodMemoryWorkingSetBytes:       k8s.PodMetric{key: {Value: 100, TimestampMs: 0}},
+					ContainerCPUUsageCores:         k8s.ContainerMetric{containerKey: {Value: 0.25, TimestampMs: 0}},
+					ContainerMemoryWorkingSetBytes: k8s.ContainerMetric{containerKey: {Value: 100, TimestampMs: 0}},
+				}, nil
+			},
+		}
+		queries := Queries(t)
+		k8suid, pguuid := RandomUUID(t)
+		cache := &PodCacheMock{
+			GetFunc: func(namespace string, name string) (*Pod, error) {
+				return &Pod{Pod: &v1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						UID:       k8suid,
+						Namespace: "test-namespace",
+						Name:      "test-pod",
+					},
+				}}, nil
+			},
+		}
+		scraper := NewNodeScrapper("test-node", client, queries, cache, nil, Options{})
+
+		// rates are written on the first scrape, no previous reading is needed
+		require.NoError(t, scraper.Scrape(ctx))
+
+		pods, err := queries.ListPodUsageHourly(ctx)
+		require.NoError(t, err)
+		require.Len(t, pods, 1)
+		assert.Equal(t, pguuid, pods[0].PodUid)
+		assert.InDelta(t, 0.25, pods[0].CpuCoresAvg, 0.0001)
+		assert.InDelta(t, 100.0, pods[0].MemoryBytesAvg, 0.0001)
+
+		containers, err := queries.ListContainerUsageHourly(ctx)
+		require.NoError(t, err)
+		require.Len(t, containers, 1)
+		assert.InDelta(t, 0.25, containers[0].CpuCoresAvg, 0.0001)
+	})
+
+	t.Run("update cadvisor usage", func(t *testing.T) {
+		t.Parallel()
+		key := k8s.PodKey{Name: "test-pod", Namespace: "test-namespace"}
+		data := []k8s.CadvisorMetrics{
+			{
+				PodNetworkReceiveBytesTotal:    k8s.PodMetric{key: {Value: 1000, TimestampMs: 0}},
+				PodNetworkTransmitBytesTotal:   k8s.PodMetric{key: {Value: 100, TimestampMs: 0}},
+				PodCPUCFSPeriodsTotal:          k8s.PodMetric{key: {Value: 50, TimestampMs: 0}},
+				PodCPUCFSThrottledPeriodsTotal: k8s.PodMetric{key: {Value: 5, TimestampMs: 0}},
+				PodFsUsageBytes:                k8s.PodMetric{key: {Value: 4096, TimestampMs: 0}},
+			},
+			{
+				PodNetworkReceiveBytesTotal:    k8s.PodMetric{key: {Value: 3000, TimestampMs: 1000}},
+				PodNetworkTransmitBytesTotal:   k8s.PodMetric{key: {Value: 400, TimestampMs: 1000}},
+				PodCPUCFSPeriodsTotal:          k8s.PodMetric{key: {Value: 150, TimestampMs: 1000}},
+				PodCPUCFSThrottledPeriodsTotal: k8s.PodMetric{key: {Value: 30, TimestampMs: 1000}},
+				PodFsUsageBytes:                k8s.PodMetric{key: {Value: 8192, TimestampMs: 1000}},
+			},
+		}
+		callindex := -1
+		client := &k8s.ClientMock{
+			NodeMetricsFunc: func(ctx context.Context, nodeName string) (k8s.NodeMetrics, error) {
+				return k8s.NodeMetrics{}, nil
+			},
+			NodeCadvisorMetricsFunc: func(ctx context.Context, nodeName string) (k8s.CadvisorMetrics, error) {
+				callindex++
+				return data[callindex], nil
+			},
+		}
+		queries := Queries(t)
+		k8suid, pguuid := RandomUUID(t)
+		cache := &PodCacheMock{
+			GetFunc: func(namespace string, name string) (*Pod, error) {
+				return &Pod{Pod: &v1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						UID:       k8suid,
+						Namespace: "test-namespace",
+						Name:      "test-pod",
+					},
+				}}, nil
+			},
+		}
+		scraper := NewNodeScrapper("test-node", client, queries, cache, nil, Options{ScrapeCadvisor: true})
+
+		require.NoError(t, scraper.Scrape(ctx))
+		require.NoError(t, scraper.Scrape(ctx))
+
+		query, err := queries.ListPodCadvisorHourly(ctx)
+		require.NoError(t, err)
+		require.Len(t, query, 1)
+		assert.Equal(t, pguuid, query[0].PodUid)
+		// counters are stored as increase between scrapes
+		assert.InDelta(t, 2000.0, query[0].NetworkReceiveBytes, 0.0001)
+		assert.InDelta(t, 300.0, query[0].NetworkTransmitBytes, 0.0001)
+		assert.InDelta(t, 100.0, query[0].CpuCfsPeriods, 0.0001)
+		assert.InDelta(t, 25.0, query[0].CpuCfsThrottledPeriods, 0.0001)
+		assert.InDelta(t, 6144.0, query[0].FsUsageBytesAvg, 0.0001)
+	})
+
+	t.Run("update volume usage", func(t *testing.T) {
+		t.Parallel()
+		client := &k8s.ClientMock{
+			NodeMetricsFunc: func(ctx context.Context, nodeName string) (k8s.NodeMetrics, error) {
+				return k8s.NodeMetrics{}, nil
+			},
+			NodeVolumeStatsFunc: func(ctx context.Context, nodeName string) (k8s.VolumeStats, error) {
+				return k8s.VolumeStats{PVCs: k8s.PVCMetric{
+					{Name: "test-pvc", Namespace: "test-namespace"}: {UsedBytes: 100, CapacityBytes: 1000, TimestampMs: 1},
+				}}, nil
+			},
+		}
+		queries := Queries(t)
+		k8suid, pguuid := RandomUUID(t)
+		pvcCache := &PVCCacheMock{
+			GetFunc: func(namespace string, name string) (*v1.PersistentVolumeClaim, error) {
+				return &v1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{
+						UID:       k8suid,
+						Namespace: "test-namespace",
+						Name:      "test-pvc",
+					},
+				}, nil
+			},
+		}
+		scraper := NewNodeScrapper("test-node", client, queries, &PodCacheMock{}, pvcCache, Options{ScrapeVolumeStats: true})
+
+		require.NoError(t, scraper.Scrape(ctx))
+
+		query, err := queries.ListPVCUsageHourly(ctx)
+		require.NoError(t, err)
+		require.Len(t, query, 1)
+		assert.Equal(t, pguuid, query[0].PvcUid)
+		assert.InDelta(t, 100.0, query[0].UsedBytesAvg, 0.0001)
+		assert.InDelta(t, 1000.0, query[0].CapacityBytes, 0.0001)
+	})
+
+	t.Run("cadvisor errors don't fail the scrape", func(t *testing.T) {
+		t.Parallel()
+		client := &k8s.ClientMock{
+			NodeMetricsFunc: func(ctx context.Context, nodeName string) (k8s.NodeMetrics, error) {
+				return k8s.NodeMetrics{}, nil
+			},
+			NodeCadvisorMetricsFunc: func(ctx context.Context, nodeName string) (k8s.CadvisorMetrics, error) {
+				return k8s.CadvisorMetrics{}, errors.New("forbidden")
+			},
+		}
+		scraper := NewNodeScrapper("test-node", client, nil, &PodCacheMock{}, nil, Options{ScrapeCadvisor: true})
+
+		require.NoError(t, scraper.Scrape(ctx))
+		assert.Len(t, client.NodeCadvisorMetricsCalls(), 1)
+	})
+
+	t.Run("volume stats errors don't fail the scrape", func(t *testing.T) {
+		t.Parallel()
+		client := &k8s.ClientMock{
+			NodeMetricsFunc: func(ctx context.Context, nodeName string) (k8s.NodeMetrics, error) {
+				return k8s.NodeMetrics{}, nil
+			},
+			NodeVolumeStatsFunc: func(ctx context.Context, nodeName string) (k8s.VolumeStats, error) {
+				return k8s.VolumeStats{}, errors.New("forbidden")
+			},
+		}
+		scraper := NewNodeScrapper("test-node", client, nil, &PodCacheMock{}, &PVCCacheMock{}, Options{ScrapeVolumeStats: true})
+
+		require.NoError(t, scraper.Scrape(ctx))
+		assert.Len(t, client.NodeVolumeStatsCalls(), 1)
+	})
+
+	t.Run("update ephemeral storage usage", func(t *testing.T) {
+		t.Parallel()
+		key := k8s.PodKey{Name: "test-pod", Namespace: "test-namespace"}
+		used := 100.0
+		client := &k8s.ClientMock{
+			NodeMetricsFunc: func(ctx context.Context, nodeName string) (k8s.NodeMetrics, error) {
+				return k8s.NodeMetrics{}, nil
+			},
+			NodeVolumeStatsFunc: func(ctx context.Context, nodeName string) (k8s.VolumeStats, error) {
+				return k8s.VolumeStats{PodEphemeralStorageBytes: k8s.PodMetric{key: {Value: used, TimestampMs: 1}}}, nil
+			},
+		}
+		queries := Queries(t)
+		k8suid, pguuid := RandomUUID(t)
+		cache := &PodCacheMock{
+			GetFunc: func(namespace string, name string) (*Pod, error) {
+				return &Pod{Pod: &v1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						UID:       k8suid,
+						Namespace: "test-namespace",
+						Name:      "test-pod",
+					},
+				}}, nil
+			},
+		}
+		scraper := NewNodeScrapper("test-node", client, queries, cache, &PVCCacheMock{}, Options{ScrapeVolumeStats: true})
+
+		require.NoError(t, scraper.Scrape(ctx))
+		used = 300
+		require.NoError(t, scraper.Scrape(ctx))
+
+		query, err := queries.ListPodEphemeralStorageHourly(ctx)
+		require.NoError(t, err)
+		require.Len(t, query, 1)
+		assert.Equal(t, pguuid, query[0].PodUid)
+		assert.InDelta(t, 200.0, query[0].UsedBytesAvg, 0.0001)
+		assert.InDelta(t, 300.0, query[0].UsedBytesMax, 0.0001)
+	})
 }
 
 func RandomUUID(t *testing.T) (types.UID, pgtype.UUID) {
@@ -2,10 +2,18 @@ package scraper
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"log/slog"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgtype"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 
 	"github.com/r2k1/pgkube/app/queries"
@@ -14,12 +22,18 @@ import (
 type PersistObjectHandler struct {
 	queries *queries.Queries
 	kind    string
+	scrub   ScrubOptions
+	// hashes of the last written content by uid, unchanged objects (e.g. on resync) aren't written again
+	hashes   map[types.UID]uint64
+	hashesMu sync.Mutex
 }
 
-func NewPersistObjectHandler(queries *queries.Queries, kind string) *PersistObjectHandler {
+func NewPersistObjectHandler(queries *queries.Queries, kind string, scrub ScrubOptions) *PersistObjectHandler {
 	return &PersistObjectHandler{
 		queries: queries,
 		kind:    kind,
+		scrub:   scrub,
+		hashes:  make(map[types.UID]uint64),
 	}
 }
 
@@ -40,6 +54,9 @@ func (h *PersistObjectHandler) OnDelete(obj interface{}) {
 		slog.Error("deleting object", "error", fmt.Errorf("unexpected object type: %T", obj))
 		return
 	}
+	h.hashesMu.Lock()
+	delete(h.hashes, uidGetter.GetUID())
+	h.hashesMu.Unlock()
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := h.queries.DeleteObject(ctx, string(uidGetter.GetUID())); err != nil {
@@ -48,10 +65,191 @@ func (h *PersistObjectHandler) OnDelete(obj interface{}) {
 }
 
 func (h *PersistObjectHandler) Upsert(obj interface{}) {
+	object, ok := obj.(metav1.Object)
+	if !ok {
+		slog.Error("upserting object", "error", fmt.Errorf("unexpected object type: %T", obj))
+		return
+	}
+	data, err := scrubObject(h.kind, obj, h.scrub)
+	if err != nil {
+		slog.Error("upserting object", "error", err)
+		return
+	}
+	derived := deriveObjectData(obj)
+	sum, err := objectHash(data, derived)
+	if err != nil {
+		slog.Error("upserting object", "error", err)
+		return
+	}
+	h.hashesMu.Lock()
+	unchanged := h.hashes[object.GetUID()] == sum
+	h.hashesMu.Unlock()
+	if unchanged {
+		return
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	if err := h.queries.UpsertObject(ctx, h.kind, obj); err != nil {
-		slog.Error("upserting object", "error", err)
+	if err := h.write(ctx, object, data, derived); err != nil {
+		slog.Error("upserting object", "error", err, "kind", h.kind)
 		return
 	}
+	// the object is written again by the next resync if any of the writes failed
+	h.hashesMu.Lock()
+	h.hashes[object.GetUID()] = sum
+	h.hashesMu.Unlock()
+}
+
+// derivedObjectData is computed from the full object, it's part of the hash so changed columns are written even when
+// the scrubbed content is the same
+type derivedObjectData struct {
+	Pod               queries.PodColumns
+	ContainerStatuses []queries.UpsertPodContainerStatusParams
+	ExtendedResources []queries.ExtendedResource
+	// ReplaceExtendedResources replaces stored extended resources of the object with ExtendedResources
+	ReplaceExtendedResources bool
+}
+
+func deriveObjectData(obj interface{}) derivedObjectData {
+	switch obj := obj.(type) {
+	case *Pod:
+		resources := podExtendedResources(obj)
+		return derivedObjectData{
+			Pod:               podColumns(obj),
+			ContainerStatuses: containerStatusParams(obj.Pod),
+			ExtendedResources: resources,
+			// extended resources can't be resized, pods without them have nothing to replace
+			ReplaceExtendedResources: len(resources) > 0,
+		}
+	case *v1.Node:
+		// device plugins can stop advertising a resource, it's removed then
+		return derivedObjectData{ExtendedResources: nodeExtendedResources(obj), ReplaceExtendedResources: true}
+	default:
+		return derivedObjectData{}
+	}
+}
+
+func objectHash(data []byte, derived derivedObjectData) (uint64, error) {
+	derivedData, err := json.Marshal(derived)
+	if err != nil {
+		return 0, fmt.Errorf("marshalling derived object data: %w", err)
+	}
+	hash := fnv.New64a()
+	_, _ = hash.Write(data)
+	_, _ = hash.Write(derivedData)
+	return hash.Sum64(), nil
+}
+
+func (h *PersistObjectHandler) write(ctx context.Context, object metav1.Object, data []byte, derived derivedObjectData) error {
+	if err := h.queries.UpsertObjectData(ctx, h.kind, object, data, derived.Pod); err != nil {
+		return err
+	}
+	if len(derived.ContainerStatuses) > 0 {
+		if err := h.queries.UpsertPodContainerStatus(ctx, derived.ContainerStatuses); err != nil {
+			return fmt.Errorf("upserting container status: %w", err)
+		}
+	}
+	if derived.ReplaceExtendedResources {
+		if err := h.queries.ReplaceExtendedResources(ctx, string(object.GetUID()), derived.ExtendedResources); err != nil {
+			return fmt.Errorf("upserting extended resources: %w", err)
+		}
+	}
+	return nil
+}
+
+func podColumns(pod *Pod) queries.PodColumns {
+	requests := podResourceRequests(pod)
+	limits := podResourceLimits(pod)
+	scheduledAt, finishedAt := podRunTime(pod.Pod)
+	return queries.PodColumns{
+		RequestCpuCores:       pgtype.Float8{Float64: requests[v1.ResourceCPU], Valid: true},
+		RequestMemoryBytes:    pgtype.Float8{Float64: requests[v1.ResourceMemory], Valid: true},
+		RequestEphemeralBytes: pgtype.Float8{Float64: requests[v1.ResourceEphemeralStorage], Valid: true},
+		LimitCpuCores:         pgtype.Float8{Float64: limits[v1.ResourceCPU], Valid: true},
+		LimitMemoryBytes:      pgtype.Float8{Float64: limits[v1.ResourceMemory], Valid: true},
+		ScheduledAt:           scheduledAt,
+		FinishedAt:            finishedAt,
+	}
+}
+
+// podExtendedResources lists extended resources requested by the pod, e.g. nvidia.com/gpu
+func podExtendedResources(pod *Pod) []queries.ExtendedResource {
+	var result []queries.ExtendedResource
+	for name, request := range podResourceRequests(pod).extended() {
+		result = append(result, queries.ExtendedResource{Resource: string(name), Request: request})
+	}
+	slices.SortFunc(result, compareExtendedResources)
+	return result
+}
+
+// nodeExtendedResources lists extended resources the node provides
+func nodeExtendedResources(node *v1.Node) []queries.ExtendedResource {
+	allocatable := newResources(node.Status.Allocatable).extended()
+	capacity := newResources(node.Status.Capacity).extended()
+	result := make([]queries.ExtendedResource, 0, len(capacity))
+	for name, value := range capacity.max(allocatable) {
+		result = append(result, queries.ExtendedResource{Resource: string(name), Allocatable: allocatable[name], Capacity: value})
+	}
+	slices.SortFunc(result, compareExtendedResources)
+	return result
+}
+
+func compareExtendedResources(a, b queries.ExtendedResource) int {
+	return strings.Compare(a.Resource, b.Resource)
+}
+
+// podRunTime returns when the pod was scheduled and when containers of a completed pod exited
+// a pod holds node resources in between, pending pods and completed pods which aren't deleted yet don't
+func podRunTime(pod *v1.Pod) (scheduledAt pgtype.Timestamptz, finishedAt pgtype.Timestamptz) {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodScheduled && condition.Status == v1.ConditionTrue && !condition.LastTransitionTime.IsZero() {
+			scheduledAt = pgtype.Timestamptz{Time: condition.LastTransitionTime.Time, Valid: true}
+		}
+	}
+	if !scheduledAt.Valid && pod.Status.StartTime != nil {
+		scheduledAt = pgtype.Timestamptz{Time: pod.Status.StartTime.Time, Valid: true}
+	}
+	if pod.Status.Phase != v1.PodSucceeded && pod.Status.Phase != v1.PodFailed {
+		return scheduledAt, finishedAt
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		terminated := status.State.Terminated
+		if terminated == nil || terminated.FinishedAt.IsZero() {
+			continue
+		}
+		if !finishedAt.Valid || terminated.FinishedAt.Time.After(finishedAt.Time) {
+			finishedAt = pgtype.Timestamptz{Time: terminated.FinishedAt.Time, Valid: true}
+		}
+	}
+	return scheduledAt, finishedAt
+}
+
+// containerStatusParams extracts restart counts and the latest termination of every (init) container
+func containerStatusParams(pod *v1.Pod) []queries.UpsertPodContainerStatusParams {
+	uid, err := parsePGUUID(pod.UID)
+	if err != nil {
+		slog.Error("parsing pod uuid", "error", err)
+		return nil
+	}
+	statuses := make([]v1.ContainerStatus, 0, len(pod.Status.InitContainerStatuses)+len(pod.Status.ContainerStatuses))
+	statuses = append(statuses, pod.Status.InitContainerStatuses...)
+	statuses = append(statuses, pod.Status.ContainerStatuses...)
+	result := make([]queries.UpsertPodContainerStatusParams, 0, len(statuses))
+	for _, status := range statuses {
+		params := queries.UpsertPodContainerStatusParams{
+			PodUid:        uid,
+			ContainerName: status.Name,
+			RestartCount:  status.RestartCount,
+		}
+		// a container which is terminated right now (e.g. restartPolicy: Never) has no restart yet
+		terminated := status.State.Terminated
+		if terminated == nil {
+			terminated = status.LastTerminationState.Terminated
+		}
+		if terminated != nil && !terminated.FinishedAt.IsZero() {
+			params.TerminatedAt = pgtype.Timestamptz{Time: terminated.FinishedAt.Time, Valid: true}
+			params.Reason = terminated.Reason
+		}
+		result = append(result, params)
+	}
+	return result
 }
@@ -0,0 +1,131 @@
+package scraper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/r2k1/pgkube/app/queries"
+)
+
+func TestContainerStatusParams(t *testing.T) {
+	finished := time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC)
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{UID: "3c2d1a43-4d0e-4d8a-9d8e-5e7f3a0c1d11"},
+		Status: v1.PodStatus{
+			InitContainerStatuses: []v1.ContainerStatus{{
+				Name:  "init",
+				State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "Completed", FinishedAt: metav1.NewTime(finished)}},
+			}},
+			ContainerStatuses: []v1.ContainerStatus{
+				{
+					Name:                 "app",
+					RestartCount:         2,
+					State:                v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+					LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "OOMKilled", FinishedAt: metav1.NewTime(finished.Add(time.Hour))}},
+				},
+				{
+					Name:  "sidecar",
+					State: v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+				},
+			},
+		},
+	}
+
+	params := containerStatusParams(pod)
+	require.Len(t, params, 3)
+	assert.Equal(t, "init", params[0].ContainerName)
+	assert.Equal(t, "Completed", params[0].Reason)
+	assert.Equal(t, finished, params[0].TerminatedAt.Time)
+	assert.Equal(t, "app", params[1].ContainerName)
+	assert.Equal(t, int32(2), params[1].RestartCount)
+	assert.Equal(t, "OOMKilled", params[1].Reason)
+	assert.Equal(t, finished.Add(time.Hour), params[1].TerminatedAt.Time)
+	assert.False(t, params[2].TerminatedAt.Valid)
+	assert.Empty(t, params[2].Reason)
+}
+
+func TestPodRunTime(t *testing.T) {
+	created := time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)
+	scheduled := created.Add(time.Hour)
+	pod := &v1.Pod{
+		Status: v1.PodStatus{
+			Phase:     v1.PodRunning,
+			StartTime: &metav1.Time{Time: scheduled.Add(time.Minute)},
+			Conditions: []v1.PodCondition{
+				{Type: v1.PodReady, Status: v1.ConditionFalse, LastTransitionTime: metav1.NewTime(created)},
+				{Type: v1.PodScheduled, Status: v1.ConditionTrue, LastTransitionTime: metav1.NewTime(scheduled)},
+			},
+			ContainerStatuses: []v1.ContainerStatus{
+				{Name: "app", State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{FinishedAt: metav1.NewTime(scheduled.Add(time.Hour))}}},
+				{Name: "sidecar", State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{FinishedAt: metav1.NewTime(scheduled.Add(2 * time.Hour))}}},
+			},
+		},
+	}
+
+	scheduledAt, finishedAt := podRunTime(pod)
+	assert.Equal(t, scheduled, scheduledAt.Time)
+	assert.False(t, finishedAt.Valid, "running pod keeps accruing")
+
+	pod.Status.Phase = v1.PodSucceeded
+	_, finishedAt = podRunTime(pod)
+	assert.Equal(t, scheduled.Add(2*time.Hour), finishedAt.Time)
+
+	pending := &v1.Pod{Status: v1.PodStatus{
+		Phase:      v1.PodPending,
+		Conditions: []v1.PodCondition{{Type: v1.PodScheduled, Status: v1.ConditionFalse, LastTransitionTime: metav1.NewTime(created)}},
+	}}
+	scheduledAt, finishedAt = podRunTime(pending)
+	assert.False(t, scheduledAt.Valid)
+	assert.False(t, finishedAt.Valid)
+}
+
+func TestNodeExtendedResources(t *testing.T) {
+	node := &v1.Node{
+		Status: v1.NodeStatus{
+			Capacity: v1.ResourceList{
+				v1.ResourceCPU:                      resource.MustParse("8"),
+				v1.ResourceName("nvidia.com/gpu"):   resource.MustParse("4"),
+				v1.ResourceName("example.com/fpga"): resource.MustParse("1"),
+			},
+			Allocatable: v1.ResourceList{
+				v1.ResourceCPU:                    resource.MustParse("7"),
+				v1.ResourceName("nvidia.com/gpu"): resource.MustParse("3"),
+			},
+		},
+	}
+	assert.Equal(t, []queries.ExtendedResource{
+		{Resource: "example.com/fpga", Allocatable: 0, Capacity: 1},
+		{Resource: "nvidia.com/gpu", Allocatable: 3, Capacity: 4},
+	}, nodeExtendedResources(node))
+}
+
+func TestObjectHash(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{UID: "3c2d1a43-4d0e-4d8a-9d8e-5e7f3a0c1d11", Name: "pod"},
+		Status:     v1.PodStatus{Phase: v1.PodRunning},
+	}
+	finished := pod.DeepCopy()
+	finished.Status.Phase = v1.PodSucceeded
+	finished.Status.ContainerStatuses = []v1.ContainerStatus{{
+		Name:  "app",
+		State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: "Completed", FinishedAt: metav1.NewTime(time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC))}},
+	}}
+	// status isn't stored, the hash still changes with the columns computed from it
+	opts := ScrubOptions{KeepPaths: map[string][]string{"Pod": {"spec.nodeName"}}}
+
+	hash := func(pod *v1.Pod) uint64 {
+		data, err := scrubObject("Pod", &Pod{Pod: pod}, opts)
+		require.NoError(t, err)
+		sum, err := objectHash(data, deriveObjectData(&Pod{Pod: pod}))
+		require.NoError(t, err)
+		return sum
+	}
+	assert.Equal(t, hash(pod), hash(pod.DeepCopy()))
+	assert.NotEqual(t, hash(pod), hash(finished))
+}
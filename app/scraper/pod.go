@@ -0,0 +1,68 @@
+package scraper
+
+import (
+	"encoding/json"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var podResource = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+// Pod is a pod with its pod-level resources (spec.resources), k8s.io/api v0.28 has no field for them and drops them
+// when decoding, pods are watched unstructured and the field is read before the conversion
+type Pod struct {
+	*v1.Pod
+	// Resources are shared by the containers of the pod, empty when only containers set resources
+	Resources v1.ResourceRequirements
+}
+
+// MarshalJSON encodes the pod with its pod-level resources, the same as the API server returns it
+func (p Pod) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(p.Pod)
+	if err != nil || (len(p.Resources.Requests) == 0 && len(p.Resources.Limits) == 0) {
+		return data, err
+	}
+	var content map[string]interface{}
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, err
+	}
+	spec, ok := content["spec"].(map[string]interface{})
+	if !ok {
+		spec = make(map[string]interface{})
+		content["spec"] = spec
+	}
+	spec["resources"] = p.Resources
+	return json.Marshal(content)
+}
+
+func podFromUnstructured(content map[string]interface{}) (*Pod, error) {
+	pod := &v1.Pod{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, pod); err != nil {
+		return nil, fmt.Errorf("converting pod: %w", err)
+	}
+	result := &Pod{Pod: pod}
+	resources, ok, err := unstructured.NestedMap(content, "spec", "resources")
+	if err != nil {
+		return nil, fmt.Errorf("reading pod resources: %w", err)
+	}
+	if ok {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(resources, &result.Resources); err != nil {
+			return nil, fmt.Errorf("converting pod resources: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// transformPod converts pods of the informer once, before they are stored in its cache
+// other objects (e.g. cache.DeletedFinalStateUnknown) are passed as is
+func transformPod(obj interface{}) (interface{}, error) {
+	content, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return obj, nil
+	}
+	return podFromUnstructured(content.Object)
+}
@@ -4,7 +4,6 @@
 package scraper
 
 import (
-	v1 "k8s.io/api/core/v1"
 	"sync"
 )
 
@@ -18,7 +17,7 @@ var _ PodCache = &PodCacheMock{}
 //
 //		// make and configure a mocked PodCache
 //		mockedPodCache := &PodCacheMock{
-//			GetFunc: func(namespace string, name string) (*v1.Pod, error) {
+//			GetFunc: func(namespace string, name string) (*Pod, error) {
 //				panic("mock out the Get method")
 //			},
 //		}
@@ -29,7 +28,7 @@ var _ PodCache = &PodCacheMock{}
 //	}
 type PodCacheMock struct {
 	// GetFunc mocks the Get method.
-	GetFunc func(namespace string, name string) (*v1.Pod, error)
+	GetFunc func(namespace string, name string) (*Pod, error)
 
 	// calls tracks calls to the methods.
 	calls struct {
@@ -45,7 +44,7 @@ type PodCacheMock struct {
 }
 
 // Get calls GetFunc.
-func (mock *PodCacheMock) Get(namespace string, name string) (*v1.Pod, error) {
+func (mock *PodCacheMock) Get(namespace string, name string) (*Pod, error) {
 	if mock.GetFunc == nil {
 		panic("PodCacheMock.GetFunc: method is nil but PodCache.Get was just called")
 	}
@@ -0,0 +1,132 @@
+package scraper
+
+import (
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// podLevelResourceNames can be set in pod-level spec.resources, the scheduler ignores other resources there
+var podLevelResourceNames = []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory}
+
+// podRequests computes cpu and memory requests of the pod the same way the scheduler does, see podResourceRequests
+func podRequests(pod *Pod) (cpuCores float64, memoryBytes float64) {
+	requests := podResourceRequests(pod)
+	return requests[v1.ResourceCPU], requests[v1.ResourceMemory]
+}
+
+// podResourceRequests computes requests of every resource of the pod the same way the scheduler does (resourcehelper.PodRequests):
+//   - containers are summed, containers resized in place report the requests applied by the kubelet in status,
+//     status only lists resizable resources (cpu, memory), the rest comes from the spec
+//   - init containers run one by one, the pod needs the largest of them next to sidecars started before it
+//   - sidecars (init containers with restartPolicy: Always) keep running next to containers
+//   - pod-level spec.resources (cpu, memory) take precedence over the containers
+//   - spec.overhead (RuntimeClass) is added on top
+func podResourceRequests(pod *Pod) resources {
+	requests := func(r *v1.ResourceRequirements) v1.ResourceList { return r.Requests }
+	return podResources(pod.Pod, requests).override(newResources(pod.Resources.Requests)).add(newResources(pod.Spec.Overhead))
+}
+
+// podResourceLimits computes limits of the pod with the same rules as requests (resourcehelper.PodLimits)
+// containers without a limit don't add to it, overhead is only added to resources with a limit
+func podResourceLimits(pod *Pod) resources {
+	limits := podResources(pod.Pod, func(r *v1.ResourceRequirements) v1.ResourceList { return r.Limits }).
+		override(newResources(pod.Resources.Limits))
+	for name, value := range newResources(pod.Spec.Overhead) {
+		if limits[name] > 0 {
+			limits[name] += value
+		}
+	}
+	return limits
+}
+
+func podResources(pod *v1.Pod, list func(*v1.ResourceRequirements) v1.ResourceList) resources {
+	statuses := make(map[string]v1.ContainerStatus, len(pod.Status.ContainerStatuses))
+	for _, status := range pod.Status.ContainerStatuses {
+		statuses[status.Name] = status
+	}
+	var containers, sidecars, initMax resources
+	for _, container := range pod.Spec.Containers {
+		values := newResources(list(&container.Resources))
+		if status, ok := statuses[container.Name]; ok && status.Resources != nil {
+			for name, value := range newResources(list(status.Resources)) {
+				values[name] = value
+			}
+		}
+		containers = containers.add(values)
+	}
+	for _, container := range pod.Spec.InitContainers {
+		values := newResources(list(&container.Resources))
+		if container.RestartPolicy != nil && *container.RestartPolicy == v1.ContainerRestartPolicyAlways {
+			sidecars = sidecars.add(values)
+			initMax = initMax.max(sidecars)
+			continue
+		}
+		initMax = initMax.max(sidecars.add(values))
+	}
+	return containers.add(sidecars).max(initMax)
+}
+
+// resources are quantities by resource name, e.g. cores for cpu and bytes for memory
+type resources map[v1.ResourceName]float64
+
+func newResources(list v1.ResourceList) resources {
+	result := make(resources, len(list))
+	for name, quantity := range list {
+		result[name] = quantity.AsApproximateFloat64()
+	}
+	return result
+}
+
+func (r resources) add(other resources) resources {
+	result := make(resources, len(r)+len(other))
+	for name, value := range r {
+		result[name] = value
+	}
+	for name, value := range other {
+		result[name] += value
+	}
+	return result
+}
+
+// override replaces values of resources which can be set at the pod level with the pod-level ones
+func (r resources) override(podLevel resources) resources {
+	result := r.add(nil)
+	for _, name := range podLevelResourceNames {
+		if value, ok := podLevel[name]; ok {
+			result[name] = value
+		}
+	}
+	return result
+}
+
+// max is computed per resource
+func (r resources) max(other resources) resources {
+	result := make(resources, len(r)+len(other))
+	for name, value := range r {
+		result[name] = value
+	}
+	for name, value := range other {
+		result[name] = max(result[name], value)
+	}
+	return result
+}
+
+// extended returns extended resources (e.g. nvidia.com/gpu), resources of the kubernetes.io domain and unprefixed ones are native
+func (r resources) extended() resources {
+	result := make(resources)
+	for name, value := range r {
+		if isExtendedResource(name) {
+			result[name] = value
+		}
+	}
+	return result
+}
+
+func isExtendedResource(name v1.ResourceName) bool {
+	domain, _, ok := strings.Cut(string(name), "/")
+	if !ok || strings.HasPrefix(string(name), v1.DefaultResourceRequestsPrefix) {
+		return false
+	}
+	return domain != "kubernetes.io" && !strings.HasSuffix(domain, ".kubernetes.io")
+}
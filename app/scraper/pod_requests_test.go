@@ -0,0 +1,210 @@
+package scraper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/r2k1/pgkube/app/queries"
+)
+
+func TestPodRequests(t *testing.T) {
+	requests := func(cpu, memory string) v1.ResourceList {
+		return v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu), v1.ResourceMemory: resource.MustParse(memory)}
+	}
+	pod := &v1.Pod{
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{Name: "app", Resources: v1.ResourceRequirements{Requests: requests("500m", "1Gi")}},
+				{Name: "sidecar", Resources: v1.ResourceRequirements{Requests: requests("100m", "128Mi")}},
+				{Name: "no-requests"},
+			},
+		},
+	}
+	cpu, memory := podRequests(&Pod{Pod: pod})
+	assert.InDelta(t, 0.6, cpu, 0.0001)
+	assert.Equal(t, float64(1024+128)*1024*1024, memory)
+
+	// app was resized in place, the kubelet reports applied requests in status
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{
+		{Name: "app", Resources: &v1.ResourceRequirements{Requests: requests("2", "2Gi")}},
+		{Name: "sidecar"},
+	}
+	cpu, memory = podRequests(&Pod{Pod: pod})
+	assert.InDelta(t, 2.1, cpu, 0.0001)
+	assert.Equal(t, float64(2048+128)*1024*1024, memory)
+}
+
+func TestPodRequests_InitContainers(t *testing.T) {
+	requests := func(cpu, memory string) v1.ResourceRequirements {
+		return v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu), v1.ResourceMemory: resource.MustParse(memory)}}
+	}
+	always := v1.ContainerRestartPolicyAlways
+	testCases := []struct {
+		name   string
+		spec   v1.PodSpec
+		cpu    float64
+		memory float64
+	}{
+		{
+			name: "LargestInitContainer",
+			spec: v1.PodSpec{
+				InitContainers: []v1.Container{
+					{Name: "migrate", Resources: requests("2", "100")},
+					{Name: "warmup", Resources: requests("1", "300")},
+				},
+				Containers: []v1.Container{{Name: "app", Resources: requests("1", "200")}},
+			},
+			cpu:    2,
+			memory: 300,
+		},
+		{
+			name: "Sidecars",
+			spec: v1.PodSpec{
+				InitContainers: []v1.Container{
+					{Name: "proxy", RestartPolicy: &always, Resources: requests("500m", "100")},
+					{Name: "migrate", Resources: requests("2", "100")},
+				},
+				Containers: []v1.Container{{Name: "app", Resources: requests("1", "200")}},
+			},
+			// the init container runs next to the proxy, the proxy runs next to the app
+			cpu:    2.5,
+			memory: 300,
+		},
+		{
+			name: "Overhead",
+			spec: v1.PodSpec{
+				Containers: []v1.Container{{Name: "app", Resources: requests("1", "200")}},
+				Overhead:   requests("250m", "50").Requests,
+			},
+			cpu:    1.25,
+			memory: 250,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cpu, memory := podRequests(&Pod{Pod: &v1.Pod{Spec: tc.spec}})
+			assert.InDelta(t, tc.cpu, cpu, 0.0001)
+			assert.InDelta(t, tc.memory, memory, 0.0001)
+		})
+	}
+}
+
+func TestPodRequests_PodLevelResources(t *testing.T) {
+	// k8s.io/api v0.28 has no field for spec.resources, it's read from the unstructured pod
+	pod, err := transformPod(&unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata":   map[string]interface{}{"name": "app", "namespace": "default"},
+		"spec": map[string]interface{}{
+			"resources": map[string]interface{}{
+				"requests": map[string]interface{}{"cpu": "2", "memory": "1Gi"},
+				"limits":   map[string]interface{}{"cpu": "4", "memory": "2Gi"},
+			},
+			"containers": []interface{}{
+				map[string]interface{}{"name": "app", "resources": map[string]interface{}{
+					"requests": map[string]interface{}{"cpu": "500m", "ephemeral-storage": "1Gi"},
+				}},
+				map[string]interface{}{"name": "sidecar"},
+			},
+			"overhead": map[string]interface{}{"cpu": "250m"},
+		},
+	}})
+	require.NoError(t, err)
+	require.IsType(t, &Pod{}, pod)
+
+	// pod-level values take precedence over containers, overhead is added on top
+	requests := podResourceRequests(pod.(*Pod))
+	assert.InDelta(t, 2.25, requests[v1.ResourceCPU], 0.0001)
+	assert.Equal(t, float64(1024*1024*1024), requests[v1.ResourceMemory])
+	// only cpu and memory can be set at the pod level
+	assert.Equal(t, float64(1024*1024*1024), requests[v1.ResourceEphemeralStorage])
+	limits := podResourceLimits(pod.(*Pod))
+	assert.InDelta(t, 4.25, limits[v1.ResourceCPU], 0.0001)
+	assert.Equal(t, float64(2*1024*1024*1024), limits[v1.ResourceMemory])
+
+	// the stored object keeps the field
+	data, err := scrubObject("Pod", pod, ScrubOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"resources":{"limits":{"cpu":"4","memory":"2Gi"},"requests":{"cpu":"2","memory":"1Gi"}}`)
+}
+
+func TestPodResourceRequests_EphemeralStorage(t *testing.T) {
+	pod := &v1.Pod{
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{Name: "app", Resources: v1.ResourceRequirements{Requests: v1.ResourceList{
+					v1.ResourceCPU:              resource.MustParse("1"),
+					v1.ResourceEphemeralStorage: resource.MustParse("2Gi"),
+				}}},
+				{Name: "logs", Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceEphemeralStorage: resource.MustParse("1Gi")}}},
+			},
+		},
+		// resized containers report only cpu and memory in status
+		Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{
+			{Name: "app", Resources: &v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("2")}}},
+		}},
+	}
+	requests := podResourceRequests(&Pod{Pod: pod})
+	assert.InDelta(t, 2.0, requests[v1.ResourceCPU], 0.0001)
+	assert.Equal(t, float64(3*1024*1024*1024), requests[v1.ResourceEphemeralStorage])
+}
+
+func TestPodResourceLimits(t *testing.T) {
+	limits := func(cpu, memory string) v1.ResourceRequirements {
+		return v1.ResourceRequirements{Limits: v1.ResourceList{v1.ResourceCPU: resource.MustParse(cpu), v1.ResourceMemory: resource.MustParse(memory)}}
+	}
+	pod := &v1.Pod{
+		Spec: v1.PodSpec{
+			InitContainers: []v1.Container{{Name: "migrate", Resources: limits("4", "100")}},
+			Containers: []v1.Container{
+				{Name: "app", Resources: limits("1", "200")},
+				{Name: "sidecar", Resources: v1.ResourceRequirements{Limits: v1.ResourceList{v1.ResourceMemory: resource.MustParse("50")}}},
+				{Name: "no-limits"},
+			},
+			Overhead: v1.ResourceList{v1.ResourceMemory: resource.MustParse("10"), v1.ResourceEphemeralStorage: resource.MustParse("1Gi")},
+		},
+	}
+	result := podResourceLimits(&Pod{Pod: pod})
+	// the init container has the largest cpu limit, overhead is only added to limited resources
+	assert.InDelta(t, 4.0, result[v1.ResourceCPU], 0.0001)
+	assert.InDelta(t, 260.0, result[v1.ResourceMemory], 0.0001)
+	assert.Zero(t, result[v1.ResourceEphemeralStorage])
+
+	// app was resized in place, the kubelet reports applied limits in status
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{{Name: "app", Resources: &v1.ResourceRequirements{Limits: v1.ResourceList{v1.ResourceMemory: resource.MustParse("500")}}}}
+	assert.InDelta(t, 560.0, podResourceLimits(&Pod{Pod: pod})[v1.ResourceMemory], 0.0001)
+}
+
+func TestPodExtendedResources(t *testing.T) {
+	gpu := v1.ResourceName("nvidia.com/gpu")
+	pod := &v1.Pod{
+		Spec: v1.PodSpec{
+			InitContainers: []v1.Container{{Name: "warmup", Resources: v1.ResourceRequirements{Requests: v1.ResourceList{gpu: resource.MustParse("2")}}}},
+			Containers: []v1.Container{
+				{Name: "train", Resources: v1.ResourceRequirements{Requests: v1.ResourceList{
+					v1.ResourceCPU:                   resource.MustParse("1"),
+					v1.ResourceName("hugepages-2Mi"): resource.MustParse("2Mi"),
+					gpu:                              resource.MustParse("1"),
+				}}},
+				{Name: "infer", Resources: v1.ResourceRequirements{Requests: v1.ResourceList{gpu: resource.MustParse("1")}}},
+			},
+		},
+	}
+	assert.Equal(t, []queries.ExtendedResource{{Resource: "nvidia.com/gpu", Request: 2}}, podExtendedResources(&Pod{Pod: pod}))
+	assert.Empty(t, podExtendedResources(&Pod{Pod: &v1.Pod{}}))
+}
+
+func TestIsExtendedResource(t *testing.T) {
+	assert.True(t, isExtendedResource("nvidia.com/gpu"))
+	assert.True(t, isExtendedResource("example.com/dongle"))
+	assert.False(t, isExtendedResource(v1.ResourceCPU))
+	assert.False(t, isExtendedResource("hugepages-1Gi"))
+	assert.False(t, isExtendedResource("kubernetes.io/batch-cpu"))
+	assert.False(t, isExtendedResource("node.kubernetes.io/foo"))
+	assert.False(t, isExtendedResource("requests.nvidia.com/gpu"))
+}
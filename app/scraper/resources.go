@@ -0,0 +1,73 @@
+package scraper
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ParseResources parses group/version/resource entries, e.g. argoproj.io/v1alpha1/rollouts
+// resources of the core group are written as version/resource
+func ParseResources(values []string) ([]schema.GroupVersionResource, error) {
+	result := make([]schema.GroupVersionResource, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		parts := strings.Split(value, "/")
+		var gvr schema.GroupVersionResource
+		switch len(parts) {
+		case 2:
+			gvr = schema.GroupVersionResource{Version: parts[0], Resource: parts[1]}
+		case 3:
+			gvr = schema.GroupVersionResource{Group: parts[0], Version: parts[1], Resource: parts[2]}
+		default:
+			return nil, fmt.Errorf("invalid resource %q, expected group/version/resource", value)
+		}
+		for _, part := range parts {
+			if part == "" {
+				return nil, fmt.Errorf("invalid resource %q, expected group/version/resource", value)
+			}
+		}
+		result = append(result, gvr)
+	}
+	return result, nil
+}
+
+// resourceKind looks up the kind of resource, it's stored in object.kind and referenced by owner references
+func resourceKind(client discovery.DiscoveryInterface, gvr schema.GroupVersionResource) (string, error) {
+	resources, err := client.ServerResourcesForGroupVersion(gvr.GroupVersion().String())
+	if err != nil {
+		return "", fmt.Errorf("discovering %s: %w", gvr.GroupVersion(), err)
+	}
+	for _, resource := range resources.APIResources {
+		if resource.Name == gvr.Resource {
+			return resource.Kind, nil
+		}
+	}
+	return "", fmt.Errorf("resource %s not found in %s", gvr.Resource, gvr.GroupVersion())
+}
+
+// newDynamicInformers creates informers of the configured resources, keyed by kind
+func newDynamicInformers(client dynamic.Interface, discoveryClient discovery.DiscoveryInterface, resources []schema.GroupVersionResource, resync time.Duration) (dynamicinformer.DynamicSharedInformerFactory, map[string]cache.SharedInformer, error) {
+	factory := dynamicinformer.NewDynamicSharedInformerFactory(client, resync)
+	result := make(map[string]cache.SharedInformer, len(resources))
+	for _, gvr := range resources {
+		kind, err := resourceKind(discoveryClient, gvr)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, ok := result[kind]; ok {
+			return nil, nil, fmt.Errorf("duplicate kind %s", kind)
+		}
+		result[kind] = factory.ForResource(gvr).Informer()
+	}
+	return factory, result, nil
+}
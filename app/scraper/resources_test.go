@@ -0,0 +1,51 @@
+package scraper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseResources(t *testing.T) {
+	resources, err := ParseResources([]string{"argoproj.io/v1alpha1/rollouts", " v1/configmaps ", ""})
+	require.NoError(t, err)
+	assert.Equal(t, []schema.GroupVersionResource{
+		{Group: "argoproj.io", Version: "v1alpha1", Resource: "rollouts"},
+		{Version: "v1", Resource: "configmaps"},
+	}, resources)
+
+	for _, value := range []string{"rollouts", "argoproj.io//rollouts", "a/b/c/d"} {
+		_, err := ParseResources([]string{value})
+		assert.Error(t, err, value)
+	}
+}
+
+func TestNewDynamicInformers(t *testing.T) {
+	discovery := fake.NewSimpleClientset().Discovery().(*fakediscovery.FakeDiscovery)
+	discovery.Resources = []*metav1.APIResourceList{{
+		GroupVersion: "argoproj.io/v1alpha1",
+		APIResources: []metav1.APIResource{{Name: "rollouts", Kind: "Rollout"}},
+	}}
+	rollouts := schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha1", Resource: "rollouts"}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		rollouts: "RolloutList",
+	})
+
+	_, informers, err := newDynamicInformers(client, discovery, []schema.GroupVersionResource{rollouts}, time.Hour)
+	require.NoError(t, err)
+	assert.Contains(t, informers, "Rollout")
+
+	_, _, err = newDynamicInformers(client, discovery, []schema.GroupVersionResource{{Group: "argoproj.io", Version: "v1alpha1", Resource: "analysisruns"}}, time.Hour)
+	require.ErrorContains(t, err, "not found")
+
+	_, _, err = newDynamicInformers(client, discovery, []schema.GroupVersionResource{rollouts, rollouts}, time.Hour)
+	require.ErrorContains(t, err, "duplicate kind")
+}
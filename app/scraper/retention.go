@@ -0,0 +1,109 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/r2k1/pgkube/app/queries"
+)
+
+const retentionInterval = time.Hour
+
+type RetentionOptions struct {
+	// HourlyRetention keeps hourly usage for the duration, older usage is rolled up into days. 0 keeps hourly usage forever
+	HourlyRetention time.Duration
+	// DailyRetention keeps daily usage for the duration. 0 keeps daily usage forever
+	DailyRetention time.Duration
+	// DryRun only logs what would be removed
+	DryRun bool
+}
+
+func (o RetentionOptions) Enabled() bool {
+	return o.HourlyRetention > 0 || o.DailyRetention > 0
+}
+
+func (o RetentionOptions) Validate() error {
+	if o.HourlyRetention < 0 || o.DailyRetention < 0 {
+		return fmt.Errorf("retention can't be negative")
+	}
+	if o.HourlyRetention > 0 && o.HourlyRetention < 24*time.Hour {
+		return fmt.Errorf("hourly retention must be at least 24h, got %s", o.HourlyRetention)
+	}
+	if o.DailyRetention > 0 && o.DailyRetention < o.HourlyRetention {
+		return fmt.Errorf("daily retention (%s) must be longer than hourly retention (%s)", o.DailyRetention, o.HourlyRetention)
+	}
+	return nil
+}
+
+// Params converts retention windows into cutoffs, only whole days are rolled up and deleted
+// objects are purged only when all usage data is bounded, otherwise old usage could lose its pod, controller or node
+func (o RetentionOptions) Params(now time.Time) queries.RetentionParams {
+	params := queries.RetentionParams{DryRun: o.DryRun}
+	if o.HourlyRetention > 0 {
+		params.HourlyBefore = truncateDay(now.Add(-o.HourlyRetention))
+	}
+	if o.DailyRetention > 0 {
+		params.DailyBefore = truncateDay(now.Add(-o.DailyRetention))
+	}
+	if o.HourlyRetention > 0 && o.DailyRetention > 0 {
+		params.ObjectsBefore = params.DailyBefore
+	}
+	return params
+}
+
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func StartRetention(ctx context.Context, queries *queries.Queries, opts RetentionOptions) {
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := ApplyRetention(ctx, queries, opts, time.Now()); err != nil {
+			slog.Error("applying retention", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func ApplyRetention(ctx context.Context, queries *queries.Queries, opts RetentionOptions, now time.Time) error {
+	start := time.Now()
+	params := opts.Params(now)
+	result, err := queries.ApplyRetention(ctx, params)
+	if err != nil {
+		return err
+	}
+	msg := "applied retention"
+	if opts.DryRun {
+		msg = "retention dry run, nothing was removed"
+	}
+	slog.Info(msg,
+		"hourlyBefore", params.HourlyBefore,
+		"dailyBefore", params.DailyBefore,
+		"objectsBefore", params.ObjectsBefore,
+		"rolledUpDailyRows", result.RolledUpDailyRows,
+		"deletedPodUsageHourly", result.DeletedPodUsageHourly,
+		"deletedContainerUsageHourly", result.DeletedContainerUsageHourly,
+		"deletedPodCadvisorHourly", result.DeletedPodCadvisorHourly,
+		"deletedPVCUsageHourly", result.DeletedPVCUsageHourly,
+		"deletedPodEphemeralStorage", result.DeletedPodEphemeralStorage,
+		"deletedEvents", result.DeletedEvents,
+		"deletedPodRequestHourly", result.DeletedPodRequestHourly,
+		"deletedPodRestartHourly", result.DeletedPodRestartHourly,
+		"deletedPodContainerStates", result.DeletedPodContainerStates,
+		"deletedPodUsageDaily", result.DeletedPodUsageDaily,
+		"deletedObjects", result.DeletedObjects,
+		"deletedObjectRevisions", result.DeletedObjectRevisions,
+		"deletedExtendedResources", result.DeletedExtendedResources,
+		"duration", time.Since(start),
+	)
+	return nil
+}
@@ -0,0 +1,42 @@
+package scraper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/r2k1/pgkube/app/queries"
+)
+
+func TestRetentionOptions_Validate(t *testing.T) {
+	require.NoError(t, RetentionOptions{}.Validate())
+	require.NoError(t, RetentionOptions{HourlyRetention: 720 * time.Hour}.Validate())
+	require.NoError(t, RetentionOptions{HourlyRetention: 720 * time.Hour, DailyRetention: 8760 * time.Hour}.Validate())
+	require.Error(t, RetentionOptions{HourlyRetention: time.Hour}.Validate())
+	require.Error(t, RetentionOptions{HourlyRetention: 720 * time.Hour, DailyRetention: 24 * time.Hour}.Validate())
+	require.Error(t, RetentionOptions{DailyRetention: -time.Hour}.Validate())
+}
+
+func TestRetentionOptions_Params(t *testing.T) {
+	now := time.Date(2023, 12, 31, 15, 30, 0, 0, time.UTC)
+	day := func(d int) time.Time {
+		return time.Date(2023, 12, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	assert.Equal(t, queries.RetentionParams{
+		HourlyBefore: day(1),
+		DryRun:       true,
+	}, RetentionOptions{HourlyRetention: 30 * 24 * time.Hour, DryRun: true}.Params(now))
+
+	assert.Equal(t, queries.RetentionParams{
+		HourlyBefore:  day(24),
+		DailyBefore:   day(1),
+		ObjectsBefore: day(1),
+	}, RetentionOptions{HourlyRetention: 7 * 24 * time.Hour, DailyRetention: 30 * 24 * time.Hour}.Params(now))
+
+	assert.Equal(t, queries.RetentionParams{
+		DailyBefore: day(1),
+	}, RetentionOptions{DailyRetention: 30 * 24 * time.Hour}.Params(now), "objects are kept while hourly usage is kept forever")
+}
@@ -0,0 +1,44 @@
+package scraper
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/r2k1/pgkube/app/queries"
+)
+
+const rollupInterval = time.Minute
+
+// StartRawRollup adds new raw samples to pod_usage_hourly and deletes samples older than retention
+// samples written while pgkube (or this replica) wasn't running are rolled up by the first run
+func StartRawRollup(ctx context.Context, queries *queries.Queries, retention time.Duration) {
+	ticker := time.NewTicker(rollupInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := RollupRaw(ctx, queries, time.Now().Add(-retention)); err != nil {
+			slog.Error("rolling up raw samples", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func RollupRaw(ctx context.Context, queries *queries.Queries, deleteBefore time.Time) error {
+	start := time.Now()
+	rows, err := queries.RollupPodUsageRaw(ctx)
+	if err != nil {
+		return err
+	}
+	// samples which aren't rolled up yet are kept
+	deleted, err := queries.DeletePodUsageRaw(ctx, deleteBefore)
+	if err != nil {
+		return err
+	}
+	slog.Debug("rolled up raw samples", "hourlyRows", rows, "deletedSamples", deleted, "duration", time.Since(start))
+	return nil
+}
@@ -4,15 +4,19 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
-	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
+	metricsv1beta1 "k8s.io/metrics/pkg/client/clientset/versioned/typed/metrics/v1beta1"
 
 	"github.com/r2k1/pgkube/app/k8s"
 	"github.com/r2k1/pgkube/app/queries"
@@ -21,12 +25,167 @@ import (
 const resyncInterval = time.Hour
 const gcInterval = time.Hour
 
-func StartScraper(ctx context.Context, queries *queries.Queries, clientSet *kubernetes.Clientset, interval time.Duration, disableScrapingDelay bool) error {
+const (
+	// MetricsSourceKubelet reads usage from the kubelet endpoints through the nodes/proxy subresource
+	MetricsSourceKubelet = "kubelet"
+	// MetricsSourceMetricsServer reads usage from the metrics.k8s.io API
+	MetricsSourceMetricsServer = "metrics-server"
+)
+
+type Options struct {
+	// Interval between scrapes of the same node
+	Interval time.Duration
+	// DisableScrapingDelay starts scraping a new node immediately instead of after a random delay
+	DisableScrapingDelay bool
+	// ScrapeCadvisor enables collection of network, filesystem and CPU throttling metrics from /metrics/cadvisor
+	ScrapeCadvisor bool
+	// ScrapeVolumeStats enables collection of persistent volume claim usage from /stats/summary
+	ScrapeVolumeStats bool
+	// MetricsSource is either MetricsSourceKubelet (default) or MetricsSourceMetricsServer
+	MetricsSource string
+	// LeaderElection allows running multiple replicas, only the leader scrapes and collects garbage
+	LeaderElection LeaderElectionOptions
+	// WriteBufferFlushInterval enables buffering of usage samples and request readings of all nodes, they are written in bulk every interval
+	// zero writes samples of every node scrape directly
+	WriteBufferFlushInterval time.Duration
+	// WriteBufferMaxSamples is the number of buffered samples after which node scrapes wait for a flush
+	WriteBufferMaxSamples int
+	// RawSampleRetention enables storing individual pod usage samples for minute resolution queries
+	// pod_usage_hourly is then derived from raw samples by a background job, zero disables raw samples
+	RawSampleRetention time.Duration
+	// Retention rolls up and deletes old usage data, it runs next to the garbage collector
+	Retention RetentionOptions
+	// ExtraResources are watched through the dynamic client in addition to built-in kinds (e.g. CRDs owning pods)
+	ExtraResources []schema.GroupVersionResource
+	// Scrub removes noisy fields from objects before they are stored
+	Scrub ScrubOptions
+}
+
+// minRawSampleRetention keeps raw samples of at least the last full hour for minute resolution queries
+const minRawSampleRetention = 2 * time.Hour
+
+// Status is the state of scraping targets and the write buffer
+type Status struct {
+	Targets     []TargetStatus    `json:"targets"`
+	WriteBuffer *WriteBufferStats `json:"writeBuffer,omitempty"`
+}
+
+// Scraper is a handle to the started scraper
+// with leader election the manager and the write buffer are created for every leadership term
+type Scraper struct {
+	mu      sync.Mutex
+	manager *Manager
+	buffer  *WriteBuffer
+}
+
+func (s *Scraper) Status() Status {
+	s.mu.Lock()
+	manager, buffer := s.manager, s.buffer
+	s.mu.Unlock()
+	status := Status{Targets: []TargetStatus{}}
+	if manager != nil {
+		status.Targets = manager.Status()
+	}
+	if buffer != nil {
+		stats := buffer.Stats()
+		status.WriteBuffer = &stats
+	}
+	return status
+}
+
+// StartScraper starts informers, node scrapers, the garbage collector and the retention job
+// with leader election enabled they are started once the lease is acquired and stopped when it's lost
+// buffered readings of a leadership term are written before the lease is released on shutdown
+func StartScraper(ctx context.Context, queries *queries.Queries, config *rest.Config, opts Options) (*Scraper, error) {
+	clientSet, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("creating k8s clientset: %w", err)
+	}
+	if opts.MetricsSource == MetricsSourceMetricsServer {
+		if opts.ScrapeCadvisor {
+			return nil, fmt.Errorf("cadvisor scraping is not supported with %s metrics source", MetricsSourceMetricsServer)
+		}
+		if opts.ScrapeVolumeStats {
+			slog.Warn("volume stats are not available from metrics-server, disabling volume stats scraping")
+			opts.ScrapeVolumeStats = false
+		}
+	}
+	if opts.RawSampleRetention > 0 && opts.RawSampleRetention < minRawSampleRetention {
+		return nil, fmt.Errorf("raw sample retention must be at least %s", minRawSampleRetention)
+	}
+	if err := opts.Retention.Validate(); err != nil {
+		return nil, err
+	}
+	if err := validateKeepPaths(clientSet.Discovery(), opts.Scrub.KeepPaths, opts.ExtraResources); err != nil {
+		return nil, err
+	}
+	if opts.RawSampleRetention > 0 && opts.Retention.HourlyRetention > 0 && opts.Retention.HourlyRetention <= opts.RawSampleRetention {
+		// raw samples rolled up late would add usage to hours which are already rolled up into days
+		return nil, fmt.Errorf("hourly retention must be longer than raw sample retention")
+	}
+	scraper := &Scraper{}
+	start := func(ctx context.Context) (func(), error) {
+		return scraper.start(ctx, queries, config, clientSet, opts)
+	}
+	if opts.LeaderElection.Enabled {
+		return scraper, startLeaderElection(ctx, clientSet, opts.LeaderElection, start)
+	}
+	_, err = start(ctx)
+	return scraper, err
+}
+
+// start runs the scraper until ctx is done, targets and buffered readings belong to a single run
+// the returned stop waits until the buffer is flushed, it must be called after ctx is done
+func (s *Scraper) start(ctx context.Context, queries *queries.Queries, config *rest.Config, clientSet *kubernetes.Clientset, opts Options) (func(), error) {
+	manager := NewManager(ctx, opts.DisableScrapingDelay)
+	var usageWriter PodUsageWriter = queries
+	write := queries.MergePodUsageSamples
+	if opts.RawSampleRetention > 0 {
+		usageWriter = NewRawUsageWriter(queries)
+		write = queries.InsertPodUsageRaw
+	}
+	var buffer *WriteBuffer
+	flushed := make(chan struct{})
+	if opts.WriteBufferFlushInterval > 0 {
+		buffer = NewWriteBuffer(write, queries, opts.WriteBufferFlushInterval, opts.WriteBufferMaxSamples)
+		usageWriter = buffer
+		go func() {
+			defer close(flushed)
+			buffer.Run(ctx)
+		}()
+	} else {
+		close(flushed)
+	}
+	s.mu.Lock()
+	s.manager, s.buffer = manager, buffer
+	s.mu.Unlock()
+	stop := func() {
+		<-flushed
+	}
+	return stop, startScraper(ctx, queries, usageWriter, config, clientSet, manager, opts)
+}
+
+func startScraper(ctx context.Context, queries *queries.Queries, usageWriter PodUsageWriter, config *rest.Config, clientSet *kubernetes.Clientset, manager *Manager, opts Options) error {
 	factory := informers.NewSharedInformerFactory(clientSet, resyncInterval)
 
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("creating dynamic client: %w", err)
+	}
+	dynamicFactory, dynamicInformers, err := newDynamicInformers(dynamicClient, clientSet.Discovery(), opts.ExtraResources, resyncInterval)
+	if err != nil {
+		return err
+	}
+	// pods are watched unstructured, k8s.io/api v0.28 would drop pod-level resources
+	podInformer := dynamicFactory.ForResource(podResource).Informer()
+	if err := podInformer.SetTransform(transformPod); err != nil {
+		return fmt.Errorf("setting pod transform: %w", err)
+	}
+
 	informers := map[string]cache.SharedInformer{
-		"Pod":                   factory.Core().V1().Pods().Informer(),
+		"Pod":                   podInformer,
 		"Node":                  factory.Core().V1().Nodes().Informer(),
+		"Namespace":             factory.Core().V1().Namespaces().Informer(),
 		"PersistentVolume":      factory.Core().V1().PersistentVolumes().Informer(),
 		"PersistentVolumeClaim": factory.Core().V1().PersistentVolumeClaims().Informer(),
 		"ReplicaSet":            factory.Apps().V1().ReplicaSets().Informer(),
@@ -36,25 +195,67 @@ func StartScraper(ctx context.Context, queries *queries.Queries, clientSet *kube
 		"Job":                   factory.Batch().V1().Jobs().Informer(),
 		"CronJob":               factory.Batch().V1().CronJobs().Informer(),
 	}
+	for kind, informer := range dynamicInformers {
+		if _, ok := informers[kind]; ok {
+			return fmt.Errorf("kind %s is already watched", kind)
+		}
+		informers[kind] = informer
+	}
 	for kind, informer := range informers {
-		eventHandler := NewPersistObjectHandler(queries, kind)
+		eventHandler := NewPersistObjectHandler(queries, kind, opts.Scrub)
 		if _, err := informer.AddEventHandlerWithResyncPeriod(eventHandler, resyncInterval); err != nil {
 			return fmt.Errorf("adding %s persist event handler: %w", kind, err)
 		}
 	}
-	manager := NewManager(ctx, disableScrapingDelay)
-	cache := NewPodCacheK8s(factory.Core().V1().Pods().Lister())
-	nodeScrapeHandler := NewNodeEventHandler(manager, k8s.NewClient(clientSet), queries, interval, cache)
+	// events are stored in their own table and aren't garbage collected with objects
+	if _, err := factory.Core().V1().Events().Informer().AddEventHandlerWithResyncPeriod(NewEventHandler(queries), resyncInterval); err != nil {
+		return fmt.Errorf("adding event handler: %w", err)
+	}
+	go func() {
+		// targets are added by the node informer which stops with the context
+		<-ctx.Done()
+		manager.RemoveAll()
+	}()
+	cache := NewPodCacheK8s(podInformer.GetIndexer())
+	pvcCache := NewPVCCacheK8s(factory.Core().V1().PersistentVolumeClaims().Lister())
+	client, err := metricsClient(config, clientSet, cache, opts)
+	if err != nil {
+		return err
+	}
+	nodeScrapeHandler := NewNodeEventHandler(manager, client, usageWriter, cache, pvcCache, opts)
 	if _, err := factory.Core().V1().Nodes().Informer().AddEventHandlerWithResyncPeriod(nodeScrapeHandler, resyncInterval); err != nil {
 		return fmt.Errorf("adding node event handler: %w", err)
 	}
 	slog.Info("starting scraper")
 	factory.Start(ctx.Done())
+	dynamicFactory.Start(ctx.Done())
 	factory.WaitForCacheSync(ctx.Done())
-	go StartGarbageCollector(ctx, queries, factory)
+	dynamicFactory.WaitForCacheSync(ctx.Done())
+	go StartGarbageCollector(ctx, queries, informers)
+	if opts.RawSampleRetention > 0 {
+		go StartRawRollup(ctx, queries, opts.RawSampleRetention)
+	}
+	if opts.Retention.Enabled() {
+		go StartRetention(ctx, queries, opts.Retention)
+	}
 	return nil
 }
 
+func metricsClient(config *rest.Config, clientSet *kubernetes.Clientset, pods k8s.PodLister, opts Options) (k8s.ClientInterface, error) {
+	switch opts.MetricsSource {
+	case "", MetricsSourceKubelet:
+		return k8s.NewClient(clientSet), nil
+	case MetricsSourceMetricsServer:
+		metricsClientSet, err := metricsv1beta1.NewForConfig(config)
+		if err != nil {
+			return nil, fmt.Errorf("creating metrics clientset: %w", err)
+		}
+		return k8s.NewMetricsServerClient(metricsClientSet, pods), nil
+	default:
+		return nil, fmt.Errorf("unknown metrics source: %s", opts.MetricsSource)
+	}
+}
+
 func truncateToHour(t time.Time) time.Time {
 	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
 }
@@ -79,13 +280,19 @@ func WithTransaction(ctx context.Context, conn *pgx.Conn, f func(tx pgx.Tx) erro
 	return nil
 }
 
-func StartGarbageCollector(ctx context.Context, queries *queries.Queries, factory informers.SharedInformerFactory) {
+func StartGarbageCollector(ctx context.Context, queries *queries.Queries, informers map[string]cache.SharedInformer) {
 	ticker := time.NewTicker(gcInterval)
 	defer ticker.Stop()
 
-	factory.WaitForCacheSync(ctx.Done())
+	hasSynced := make([]cache.InformerSynced, 0, len(informers))
+	for _, informer := range informers {
+		hasSynced = append(hasSynced, informer.HasSynced)
+	}
+	if !cache.WaitForCacheSync(ctx.Done(), hasSynced...) {
+		return
+	}
 	// Trigger the first tick immediately
-	if err := CollectGarbage(ctx, queries, factory); err != nil {
+	if err := CollectGarbage(ctx, queries, informers); err != nil {
 		slog.Error("cleaning up objects", "error", err)
 	}
 
@@ -94,52 +301,48 @@ func StartGarbageCollector(ctx context.Context, queries *queries.Queries, factor
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			if err := CollectGarbage(ctx, queries, factory); err != nil {
+			if err := CollectGarbage(ctx, queries, informers); err != nil {
 				slog.Error("cleaning up objects", "error", err)
 			}
 		}
 	}
 }
 
-func CollectGarbage(ctx context.Context, queries *queries.Queries, factory informers.SharedInformerFactory) error {
-	listAndDelete(ctx, "Pod", queries, factory.Core().V1().Pods().Lister().List)
-	listAndDelete(ctx, "Nodes", queries, factory.Core().V1().Nodes().Lister().List)
-	listAndDelete(ctx, "PersistentVolume", queries, factory.Core().V1().PersistentVolumes().Lister().List)
-	listAndDelete(ctx, "PersistentVolumeClaim", queries, factory.Core().V1().PersistentVolumeClaims().Lister().List)
-	listAndDelete(ctx, "ReplicaSet", queries, factory.Apps().V1().ReplicaSets().Lister().List)
-	listAndDelete(ctx, "Deployment", queries, factory.Apps().V1().Deployments().Lister().List)
-	listAndDelete(ctx, "Job", queries, factory.Batch().V1().Jobs().Lister().List)
-	listAndDelete(ctx, "CronJob", queries, factory.Batch().V1().CronJobs().Lister().List)
+// CollectGarbage marks objects of every watched kind as deleted if they are no longer in the informer cache
+func CollectGarbage(ctx context.Context, queries *queries.Queries, informers map[string]cache.SharedInformer) error {
+	for kind, informer := range informers {
+		if !informer.HasSynced() {
+			// an empty cache would mark all objects as deleted
+			slog.Warn("skipping garbage collection of not synced informer", "kind", kind)
+			continue
+		}
+		if err := deleteObjects(ctx, queries, kind, informer.GetStore().List()); err != nil {
+			slog.Error("deleting objects", "error", err, "kind", kind)
+		}
+	}
 	slog.Debug("garbage collection completed")
 	return nil
 }
 
-func listAndDelete[T v1.Object](ctx context.Context, kind string, queries *queries.Queries, listFunc func(selector labels.Selector) (ret []T, err error)) {
-	objects, err := listFunc(labels.Everything())
-	if err != nil {
-		slog.Error("listing objects", "error", err, "kind", kind)
-		return
-	}
-	if err := deleteObjects(ctx, queries, kind, objects); err != nil {
-		slog.Error("deleting objects", "error", err, "kind", kind)
-		return
-	}
-}
-
-func deleteObjects[T v1.Object](ctx context.Context, queries *queries.Queries, kind string, objectsToIgnore []T) error {
+func deleteObjects(ctx context.Context, queries *queries.Queries, kind string, objectsToIgnore []interface{}) error {
 	uids := make([]pgtype.UUID, 0, len(objectsToIgnore))
-	for _, pod := range objectsToIgnore {
-		uid, err := parsePGUUID(pod.GetUID())
+	for _, obj := range objectsToIgnore {
+		object, err := meta.Accessor(obj)
 		if err != nil {
-			slog.Error("parsing pod uuid", "error", err)
+			slog.Error("accessing object metadata", "error", err, "kind", kind)
+			continue
+		}
+		uid, err := parsePGUUID(object.GetUID())
+		if err != nil {
+			slog.Error("parsing object uuid", "error", err, "kind", kind)
 			continue
 		}
 		uids = append(uids, uid)
 	}
 	count, err := queries.DeleteObjects(ctx, kind, uids)
 	if err != nil {
-		return fmt.Errorf("listing pods: %w", err)
+		return fmt.Errorf("deleting objects: %w", err)
 	}
-	slog.Info("cleaned objects", kind, count)
-	return err
+	slog.Info("cleaned objects", "kind", kind, "count", count)
+	return nil
 }
@@ -0,0 +1,148 @@
+package scraper
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+// ScrubOptions removes noisy fields from objects before they are stored
+type ScrubOptions struct {
+	// DropManagedFields removes metadata.managedFields
+	DropManagedFields bool
+	// DropAnnotations removes the annotations, e.g. kubectl.kubernetes.io/last-applied-configuration
+	DropAnnotations []string
+	// KeepPaths stores only the listed dot separated paths of a kind, e.g. "spec.replicas"
+	// metadata is always kept, views depend on it. Kinds without paths are stored in full
+	KeepPaths map[string][]string
+}
+
+// derivedKinds are always stored in full, views and columns derived from the objects read their spec and status
+var derivedKinds = map[string]bool{
+	"Pod":                   true,
+	"Node":                  true,
+	"PersistentVolumeClaim": true,
+	"ReplicaSet":            true,
+	"Deployment":            true,
+	"DaemonSet":             true,
+	"StatefulSet":           true,
+	"Job":                   true,
+	"CronJob":               true,
+}
+
+// ParseKeepPaths parses kind=path entries, e.g. PersistentVolume=spec.capacity
+func ParseKeepPaths(values []string) (map[string][]string, error) {
+	result := make(map[string][]string)
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		kind, path, ok := strings.Cut(value, "=")
+		if !ok || kind == "" || path == "" || hasEmptySegment(path) {
+			return nil, fmt.Errorf("invalid keep path %q, expected kind=path", value)
+		}
+		if derivedKinds[kind] {
+			return nil, fmt.Errorf("invalid keep path %q, %s objects are stored in full", value, kind)
+		}
+		result[kind] = append(result[kind], path)
+	}
+	return result, nil
+}
+
+// validateKeepPaths checks that kinds with keep paths are watched, either built-in kinds which aren't derived or
+// extra resources. It runs before the leader election, a misconfigured replica fails to start instead of holding the lease
+func validateKeepPaths(discoveryClient discovery.DiscoveryInterface, keepPaths map[string][]string, extraResources []schema.GroupVersionResource) error {
+	if len(keepPaths) == 0 {
+		return nil
+	}
+	watched := map[string]bool{"Namespace": true, "PersistentVolume": true}
+	for _, gvr := range extraResources {
+		kind, err := resourceKind(discoveryClient, gvr)
+		if err != nil {
+			return err
+		}
+		watched[kind] = true
+	}
+	for kind := range keepPaths {
+		if !watched[kind] {
+			return fmt.Errorf("keep paths of %s, the kind isn't watched", kind)
+		}
+	}
+	return nil
+}
+
+func hasEmptySegment(path string) bool {
+	for _, segment := range strings.Split(path, ".") {
+		if segment == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// scrubObject serializes the object without the configured fields, the object itself isn't modified
+// it's shared with the informer cache
+func scrubObject(kind string, obj interface{}, opts ScrubOptions) ([]byte, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling object: %w", err)
+	}
+	var content map[string]interface{}
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, fmt.Errorf("unmarshalling object: %w", err)
+	}
+	if metadata, ok := content["metadata"].(map[string]interface{}); ok {
+		if opts.DropManagedFields {
+			delete(metadata, "managedFields")
+		}
+		if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+			for _, annotation := range opts.DropAnnotations {
+				delete(annotations, annotation)
+			}
+			if len(annotations) == 0 {
+				delete(metadata, "annotations")
+			}
+		}
+	}
+	if paths := opts.KeepPaths[kind]; len(paths) > 0 {
+		content = keepPaths(content, append([]string{"metadata"}, paths...))
+	}
+	// map keys are sorted, equal content results in equal bytes
+	data, err = json.Marshal(content)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling scrubbed object: %w", err)
+	}
+	return data, nil
+}
+
+// keepPaths copies the values of the paths into a new object, missing paths are ignored
+func keepPaths(content map[string]interface{}, paths []string) map[string]interface{} {
+	result := make(map[string]interface{})
+	for _, path := range paths {
+		segments := strings.Split(path, ".")
+		src, dst := content, result
+		for i, segment := range segments {
+			value, ok := src[segment]
+			if !ok {
+				break
+			}
+			if i == len(segments)-1 {
+				dst[segment] = value
+				break
+			}
+			next, ok := value.(map[string]interface{})
+			if !ok {
+				break
+			}
+			if _, ok := dst[segment].(map[string]interface{}); !ok {
+				dst[segment] = make(map[string]interface{})
+			}
+			src, dst = next, dst[segment].(map[string]interface{})
+		}
+	}
+	return result
+}
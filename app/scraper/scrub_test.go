@@ -0,0 +1,80 @@
+package scraper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestScrubObject(t *testing.T) {
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "config",
+			Annotations: map[string]string{
+				"kubectl.kubernetes.io/last-applied-configuration": "{}",
+				"owner.example.com/team":                           "platform",
+			},
+			ManagedFields: []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+		},
+		Data: map[string]string{"version": "1", "large": "..."},
+	}
+	opts := ScrubOptions{
+		DropManagedFields: true,
+		DropAnnotations:   []string{"kubectl.kubernetes.io/last-applied-configuration"},
+	}
+
+	data, err := scrubObject("ConfigMap", configMap, opts)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"metadata": {"name": "config", "creationTimestamp": null, "annotations": {"owner.example.com/team": "platform"}},
+		"data": {"version": "1", "large": "..."}
+	}`, string(data))
+	// the informer cache object isn't modified
+	assert.Len(t, configMap.Annotations, 2)
+	assert.Len(t, configMap.ManagedFields, 1)
+
+	opts.KeepPaths = map[string][]string{"ConfigMap": {"data.version", "data.missing", "spec.missing"}}
+	data, err = scrubObject("ConfigMap", configMap, opts)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"metadata": {"name": "config", "creationTimestamp": null, "annotations": {"owner.example.com/team": "platform"}},
+		"data": {"version": "1"}
+	}`, string(data))
+
+	data, err = scrubObject("Secret", &v1.Secret{Type: v1.SecretTypeOpaque}, opts)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"metadata": {"creationTimestamp": null}, "type": "Opaque"}`, string(data))
+}
+
+func TestParseKeepPaths(t *testing.T) {
+	paths, err := ParseKeepPaths([]string{"PersistentVolume=spec.capacity", " PersistentVolume=metadata ", "Rollout=spec.replicas", ""})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"PersistentVolume": {"spec.capacity", "metadata"},
+		"Rollout":          {"spec.replicas"},
+	}, paths)
+
+	for _, value := range []string{"PersistentVolume", "=spec", "PersistentVolume=", "PersistentVolume=spec..capacity", "Pod=spec.nodeName", "Deployment=spec.replicas"} {
+		_, err := ParseKeepPaths([]string{value})
+		assert.Error(t, err, value)
+	}
+}
+
+func TestValidateKeepPaths(t *testing.T) {
+	discovery := fake.NewSimpleClientset().Discovery().(*fakediscovery.FakeDiscovery)
+	discovery.Resources = []*metav1.APIResourceList{{
+		GroupVersion: "argoproj.io/v1alpha1",
+		APIResources: []metav1.APIResource{{Name: "rollouts", Kind: "Rollout"}},
+	}}
+	rollouts := []schema.GroupVersionResource{{Group: "argoproj.io", Version: "v1alpha1", Resource: "rollouts"}}
+
+	require.NoError(t, validateKeepPaths(discovery, map[string][]string{"PersistentVolume": {"spec.capacity"}, "Rollout": {"spec.replicas"}}, rollouts))
+	require.ErrorContains(t, validateKeepPaths(discovery, map[string][]string{"Rollout": {"spec.replicas"}}, nil), "isn't watched")
+	require.ErrorContains(t, validateKeepPaths(discovery, map[string][]string{"ConfigMap": {"data.version"}}, rollouts), "isn't watched")
+}
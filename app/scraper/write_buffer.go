@@ -0,0 +1,386 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/r2k1/pgkube/app/queries"
+)
+
+// flushTimeout limits the final flush after the buffer is stopped
+const flushTimeout = 30 * time.Second
+
+// PodUsageWriter persists usage and request readings of node scrapes, implemented by queries.Queries (direct writes),
+// RawUsageWriter and WriteBuffer
+type PodUsageWriter interface {
+	UpsertPodUsedCPU(ctx context.Context, arg []queries.UpsertPodUsedCPUParams) error
+	UpsertPodUsedMemory(ctx context.Context, arg []queries.UpsertPodUsedMemoryParams) error
+	UpsertPodRequests(ctx context.Context, arg []queries.UpsertPodRequestParams) error
+	UpsertContainerUsedCPU(ctx context.Context, arg []queries.UpsertContainerUsedCPUParams) error
+	UpsertContainerUsedMemory(ctx context.Context, arg []queries.UpsertContainerUsedMemoryParams) error
+	UpsertPVCUsage(ctx context.Context, arg []queries.UpsertPVCUsageParams) error
+	UpsertPodEphemeralStorage(ctx context.Context, arg []queries.UpsertPodEphemeralStorageParams) error
+	UpsertPodNetwork(ctx context.Context, arg []queries.UpsertPodNetworkParams) error
+	UpsertPodCPUThrottling(ctx context.Context, arg []queries.UpsertPodCPUThrottlingParams) error
+	UpsertPodFsUsage(ctx context.Context, arg []queries.UpsertPodFsUsageParams) error
+}
+
+var _ PodUsageWriter = &queries.Queries{}
+
+// SampleWriteFunc writes a batch of samples, e.g. queries.MergePodUsageSamples or queries.InsertPodUsageRaw
+type SampleWriteFunc func(ctx context.Context, samples []queries.PodUsageSample) error
+
+// BulkWriter writes buffered readings other than pod usage samples in bulk, implemented by queries.Queries
+type BulkWriter interface {
+	UpsertPodRequests(ctx context.Context, arg []queries.UpsertPodRequestParams) error
+	MergeContainerUsageSamples(ctx context.Context, samples []queries.ContainerUsageSample) error
+	MergePVCUsage(ctx context.Context, arg []queries.UpsertPVCUsageParams) error
+	MergePodEphemeralStorage(ctx context.Context, arg []queries.UpsertPodEphemeralStorageParams) error
+	MergePodCadvisorSamples(ctx context.Context, samples []queries.PodCadvisorSample) error
+}
+
+var _ BulkWriter = &queries.Queries{}
+
+// RawUsageWriter writes pod usage directly into the raw sample table, other readings are written hourly
+type RawUsageWriter struct {
+	*queries.Queries
+}
+
+var _ PodUsageWriter = &RawUsageWriter{}
+
+func NewRawUsageWriter(queries *queries.Queries) *RawUsageWriter {
+	return &RawUsageWriter{Queries: queries}
+}
+
+func (w *RawUsageWriter) UpsertPodUsedCPU(ctx context.Context, arg []queries.UpsertPodUsedCPUParams) error {
+	return w.InsertPodUsageRaw(ctx, cpuSamples(arg))
+}
+
+func (w *RawUsageWriter) UpsertPodUsedMemory(ctx context.Context, arg []queries.UpsertPodUsedMemoryParams) error {
+	return w.InsertPodUsageRaw(ctx, memorySamples(arg))
+}
+
+func cpuSamples(arg []queries.UpsertPodUsedCPUParams) []queries.PodUsageSample {
+	samples := make([]queries.PodUsageSample, 0, len(arg))
+	for _, item := range arg {
+		samples = append(samples, queries.PodUsageSample{
+			PodUid:    item.PodUid,
+			Timestamp: item.Timestamp,
+			CpuCores:  pgtype.Float8{Float64: item.CpuCores, Valid: true},
+		})
+	}
+	return samples
+}
+
+func memorySamples(arg []queries.UpsertPodUsedMemoryParams) []queries.PodUsageSample {
+	samples := make([]queries.PodUsageSample, 0, len(arg))
+	for _, item := range arg {
+		samples = append(samples, queries.PodUsageSample{
+			PodUid:      item.PodUid,
+			Timestamp:   item.Timestamp,
+			MemoryBytes: pgtype.Float8{Float64: item.MemoryBytes, Valid: true},
+		})
+	}
+	return samples
+}
+
+func containerCPUSamples(arg []queries.UpsertContainerUsedCPUParams) []queries.ContainerUsageSample {
+	samples := make([]queries.ContainerUsageSample, 0, len(arg))
+	for _, item := range arg {
+		samples = append(samples, queries.ContainerUsageSample{
+			PodUid:        item.PodUid,
+			ContainerName: item.ContainerName,
+			Timestamp:     item.Timestamp,
+			CpuCores:      pgtype.Float8{Float64: item.CpuCores, Valid: true},
+		})
+	}
+	return samples
+}
+
+func containerMemorySamples(arg []queries.UpsertContainerUsedMemoryParams) []queries.ContainerUsageSample {
+	samples := make([]queries.ContainerUsageSample, 0, len(arg))
+	for _, item := range arg {
+		samples = append(samples, queries.ContainerUsageSample{
+			PodUid:        item.PodUid,
+			ContainerName: item.ContainerName,
+			Timestamp:     item.Timestamp,
+			MemoryBytes:   pgtype.Float8{Float64: item.MemoryBytes, Valid: true},
+		})
+	}
+	return samples
+}
+
+func networkSamples(arg []queries.UpsertPodNetworkParams) []queries.PodCadvisorSample {
+	samples := make([]queries.PodCadvisorSample, 0, len(arg))
+	for _, item := range arg {
+		samples = append(samples, queries.PodCadvisorSample{
+			PodUid:               item.PodUid,
+			Timestamp:            item.Timestamp,
+			NetworkReceiveBytes:  pgtype.Float8{Float64: item.NetworkReceiveBytes, Valid: true},
+			NetworkTransmitBytes: pgtype.Float8{Float64: item.NetworkTransmitBytes, Valid: true},
+		})
+	}
+	return samples
+}
+
+func cpuThrottlingSamples(arg []queries.UpsertPodCPUThrottlingParams) []queries.PodCadvisorSample {
+	samples := make([]queries.PodCadvisorSample, 0, len(arg))
+	for _, item := range arg {
+		samples = append(samples, queries.PodCadvisorSample{
+			PodUid:                 item.PodUid,
+			Timestamp:              item.Timestamp,
+			CpuCfsPeriods:          pgtype.Float8{Float64: item.CpuCfsPeriods, Valid: true},
+			CpuCfsThrottledPeriods: pgtype.Float8{Float64: item.CpuCfsThrottledPeriods, Valid: true},
+		})
+	}
+	return samples
+}
+
+func fsUsageSamples(arg []queries.UpsertPodFsUsageParams) []queries.PodCadvisorSample {
+	samples := make([]queries.PodCadvisorSample, 0, len(arg))
+	for _, item := range arg {
+		samples = append(samples, queries.PodCadvisorSample{
+			PodUid:       item.PodUid,
+			Timestamp:    item.Timestamp,
+			FsUsageBytes: pgtype.Float8{Float64: item.FsUsageBytes, Valid: true},
+		})
+	}
+	return samples
+}
+
+// bufferedWrites are readings waiting for the next flush, grouped by the way they are written
+type bufferedWrites struct {
+	podSamples       []queries.PodUsageSample
+	requests         []queries.UpsertPodRequestParams
+	containerSamples []queries.ContainerUsageSample
+	pvcUsage         []queries.UpsertPVCUsageParams
+	ephemeralStorage []queries.UpsertPodEphemeralStorageParams
+	cadvisorSamples  []queries.PodCadvisorSample
+}
+
+func (w bufferedWrites) len() int {
+	return len(w.podSamples) + len(w.requests) + len(w.containerSamples) + len(w.pvcUsage) + len(w.ephemeralStorage) +
+		len(w.cadvisorSamples)
+}
+
+// append returns readings of w followed by readings of other
+func (w bufferedWrites) append(other bufferedWrites) bufferedWrites {
+	return bufferedWrites{
+		podSamples:       append(w.podSamples, other.podSamples...),
+		requests:         append(w.requests, other.requests...),
+		containerSamples: append(w.containerSamples, other.containerSamples...),
+		pvcUsage:         append(w.pvcUsage, other.pvcUsage...),
+		ephemeralStorage: append(w.ephemeralStorage, other.ephemeralStorage...),
+		cadvisorSamples:  append(w.cadvisorSamples, other.cadvisorSamples...),
+	}
+}
+
+// flushWrites writes items in one call, the items are returned back when the write fails
+func flushWrites[T any](ctx context.Context, items []T, write func(context.Context, []T) error, errs []error) ([]T, []error) {
+	if len(items) == 0 {
+		return nil, errs
+	}
+	if err := write(ctx, items); err != nil {
+		return items, append(errs, err)
+	}
+	return nil, errs
+}
+
+// WriteBufferStats describes recent flushes of the write buffer
+type WriteBufferStats struct {
+	Pending              int       `json:"pending"`
+	MaxSamples           int       `json:"maxSamples"`
+	Flushes              int       `json:"flushes"`
+	FailedFlushes        int       `json:"failedFlushes"`
+	FlushedSamples       int       `json:"flushedSamples"`
+	LastFlush            time.Time `json:"lastFlush"`
+	LastFlushSize        int       `json:"lastFlushSize"`
+	LastFlushSeconds     float64   `json:"lastFlushSeconds"`
+	MaxFlushSeconds      float64   `json:"maxFlushSeconds"`
+	LastError            string    `json:"lastError"`
+	BlockedWrites        int       `json:"blockedWrites"`
+	FlushIntervalSeconds float64   `json:"flushIntervalSeconds"`
+}
+
+// WriteBuffer collects usage samples and request readings of all node targets and periodically writes them in bulk
+// when the database falls behind and the buffer is full, writers are blocked until the next successful flush
+type WriteBuffer struct {
+	writeSamples  SampleWriteFunc
+	writer        BulkWriter
+	flushInterval time.Duration
+	// maxSamples limits buffered readings of all kinds together
+	maxSamples int
+
+	mu      sync.Mutex
+	pending bufferedWrites
+	// flushed is closed and replaced after every successful flush
+	flushed  chan struct{}
+	flushNow chan struct{}
+	stats    WriteBufferStats
+}
+
+var _ PodUsageWriter = &WriteBuffer{}
+
+func NewWriteBuffer(writeSamples SampleWriteFunc, writer BulkWriter, flushInterval time.Duration, maxSamples int) *WriteBuffer {
+	return &WriteBuffer{
+		writeSamples:  writeSamples,
+		writer:        writer,
+		flushInterval: flushInterval,
+		maxSamples:    maxSamples,
+		flushed:       make(chan struct{}),
+		flushNow:      make(chan struct{}, 1),
+		stats: WriteBufferStats{
+			MaxSamples:           maxSamples,
+			FlushIntervalSeconds: flushInterval.Seconds(),
+		},
+	}
+}
+
+func (b *WriteBuffer) UpsertPodUsedCPU(ctx context.Context, arg []queries.UpsertPodUsedCPUParams) error {
+	return b.add(ctx, bufferedWrites{podSamples: cpuSamples(arg)})
+}
+
+func (b *WriteBuffer) UpsertPodUsedMemory(ctx context.Context, arg []queries.UpsertPodUsedMemoryParams) error {
+	return b.add(ctx, bufferedWrites{podSamples: memorySamples(arg)})
+}
+
+func (b *WriteBuffer) UpsertPodRequests(ctx context.Context, arg []queries.UpsertPodRequestParams) error {
+	return b.add(ctx, bufferedWrites{requests: arg})
+}
+
+func (b *WriteBuffer) UpsertContainerUsedCPU(ctx context.Context, arg []queries.UpsertContainerUsedCPUParams) error {
+	return b.add(ctx, bufferedWrites{containerSamples: containerCPUSamples(arg)})
+}
+
+func (b *WriteBuffer) UpsertContainerUsedMemory(ctx context.Context, arg []queries.UpsertContainerUsedMemoryParams) error {
+	return b.add(ctx, bufferedWrites{containerSamples: containerMemorySamples(arg)})
+}
+
+func (b *WriteBuffer) UpsertPVCUsage(ctx context.Context, arg []queries.UpsertPVCUsageParams) error {
+	return b.add(ctx, bufferedWrites{pvcUsage: arg})
+}
+
+func (b *WriteBuffer) UpsertPodEphemeralStorage(ctx context.Context, arg []queries.UpsertPodEphemeralStorageParams) error {
+	return b.add(ctx, bufferedWrites{ephemeralStorage: arg})
+}
+
+func (b *WriteBuffer) UpsertPodNetwork(ctx context.Context, arg []queries.UpsertPodNetworkParams) error {
+	return b.add(ctx, bufferedWrites{cadvisorSamples: networkSamples(arg)})
+}
+
+func (b *WriteBuffer) UpsertPodCPUThrottling(ctx context.Context, arg []queries.UpsertPodCPUThrottlingParams) error {
+	return b.add(ctx, bufferedWrites{cadvisorSamples: cpuThrottlingSamples(arg)})
+}
+
+func (b *WriteBuffer) UpsertPodFsUsage(ctx context.Context, arg []queries.UpsertPodFsUsageParams) error {
+	return b.add(ctx, bufferedWrites{cadvisorSamples: fsUsageSamples(arg)})
+}
+
+func (b *WriteBuffer) add(ctx context.Context, writes bufferedWrites) error {
+	blocked := false
+	for {
+		b.mu.Lock()
+		if b.pending.len() < b.maxSamples {
+			b.pending = b.pending.append(writes)
+			b.stats.Pending = b.pending.len()
+			b.mu.Unlock()
+			return nil
+		}
+		if !blocked {
+			blocked = true
+			b.stats.BlockedWrites++
+		}
+		flushed := b.flushed
+		b.mu.Unlock()
+
+		// don't wait for the next tick, the buffer is full
+		select {
+		case b.flushNow <- struct{}{}:
+		default:
+		}
+		select {
+		case <-flushed:
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for write buffer flush: %w", ctx.Err())
+		}
+	}
+}
+
+// Run flushes the buffer every flush interval until the context is done
+func (b *WriteBuffer) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			b.Flush(ctx)
+		case <-b.flushNow:
+			b.Flush(ctx)
+		case <-ctx.Done():
+			// nolint:contextcheck
+			flushCtx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+			b.Flush(flushCtx)
+			cancel()
+			return
+		}
+	}
+}
+
+// Flush writes all buffered readings, on failure the unwritten ones are kept for the next attempt
+func (b *WriteBuffer) Flush(ctx context.Context) {
+	b.mu.Lock()
+	pending := b.pending
+	b.pending = bufferedWrites{}
+	b.mu.Unlock()
+	size := pending.len()
+	if size == 0 {
+		return
+	}
+
+	start := time.Now()
+	var failed bufferedWrites
+	var errs []error
+	failed.podSamples, errs = flushWrites(ctx, pending.podSamples, b.writeSamples, errs)
+	failed.requests, errs = flushWrites(ctx, pending.requests, b.writer.UpsertPodRequests, errs)
+	failed.containerSamples, errs = flushWrites(ctx, pending.containerSamples, b.writer.MergeContainerUsageSamples, errs)
+	failed.pvcUsage, errs = flushWrites(ctx, pending.pvcUsage, b.writer.MergePVCUsage, errs)
+	failed.ephemeralStorage, errs = flushWrites(ctx, pending.ephemeralStorage, b.writer.MergePodEphemeralStorage, errs)
+	failed.cadvisorSamples, errs = flushWrites(ctx, pending.cadvisorSamples, b.writer.MergePodCadvisorSamples, errs)
+	duration := time.Since(start)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := errors.Join(errs...); err != nil {
+		b.pending = failed.append(b.pending)
+		b.stats.Pending = b.pending.len()
+		b.stats.FailedFlushes++
+		b.stats.LastError = err.Error()
+		slog.Error("flushing write buffer", "error", err, "unwritten", failed.len())
+		return
+	}
+	b.stats.Pending = b.pending.len()
+	b.stats.Flushes++
+	b.stats.FlushedSamples += size
+	b.stats.LastFlush = start
+	b.stats.LastFlushSize = size
+	b.stats.LastFlushSeconds = duration.Seconds()
+	if duration.Seconds() > b.stats.MaxFlushSeconds {
+		b.stats.MaxFlushSeconds = duration.Seconds()
+	}
+	b.stats.LastError = ""
+	close(b.flushed)
+	b.flushed = make(chan struct{})
+	slog.Debug("flushed write buffer", "samples", size, "duration", duration)
+}
+
+func (b *WriteBuffer) Stats() WriteBufferStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats
+}
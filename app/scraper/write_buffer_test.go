@@ -0,0 +1,177 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/r2k1/pgkube/app/queries"
+)
+
+func TestWriteBuffer_Flush(t *testing.T) {
+	ctx := Context(t)
+	q := Queries(t)
+	buffer := NewWriteBuffer(q.MergePodUsageSamples, q, time.Hour, 100)
+	_, podUID := RandomUUID(t)
+	timestamp := pgtype.Timestamptz{Time: time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC), Valid: true}
+
+	require.NoError(t, buffer.UpsertPodUsedCPU(ctx, []queries.UpsertPodUsedCPUParams{
+		{PodUid: podUID, Timestamp: timestamp, CpuCores: 1},
+		{PodUid: podUID, Timestamp: timestamp, CpuCores: 3},
+	}))
+	require.NoError(t, buffer.UpsertPodUsedMemory(ctx, []queries.UpsertPodUsedMemoryParams{
+		{PodUid: podUID, Timestamp: timestamp, MemoryBytes: 100},
+	}))
+	require.NoError(t, buffer.UpsertPodRequests(ctx, []queries.UpsertPodRequestParams{
+		{PodUid: podUID, Timestamp: timestamp, CpuCores: 0.5, MemoryBytes: 200},
+	}))
+	assert.Equal(t, 4, buffer.Stats().Pending)
+
+	buffer.Flush(ctx)
+	// the second flush merges into the existing row
+	require.NoError(t, buffer.UpsertPodUsedCPU(ctx, []queries.UpsertPodUsedCPUParams{
+		{PodUid: podUID, Timestamp: timestamp, CpuCores: 2},
+	}))
+	buffer.Flush(ctx)
+
+	stats := buffer.Stats()
+	assert.Equal(t, 0, stats.Pending)
+	assert.Equal(t, 2, stats.Flushes)
+	assert.Equal(t, 5, stats.FlushedSamples)
+	assert.Equal(t, 1, stats.LastFlushSize)
+	assert.Empty(t, stats.LastError)
+
+	usage, err := q.ListPodUsageHourly(ctx)
+	require.NoError(t, err)
+	require.Len(t, usage, 1)
+	assert.Equal(t, int32(3), usage[0].CpuCoresTotalReadings)
+	assert.InDelta(t, 1.0, usage[0].CpuCoresMin, 0.0001)
+	assert.InDelta(t, 3.0, usage[0].CpuCoresMax, 0.0001)
+	assert.InDelta(t, 2.0, usage[0].CpuCoresAvg, 0.0001)
+	assert.Equal(t, int32(1), usage[0].MemoryBytesTotalReadings)
+	assert.InDelta(t, 100.0, usage[0].MemoryBytesAvg, 0.0001)
+
+	requests, err := q.ListPodRequestHourly(ctx)
+	require.NoError(t, err)
+	require.Len(t, requests, 1)
+	assert.InDelta(t, 0.5, requests[0].CpuCoresAvg, 0.0001)
+	assert.InDelta(t, 200.0, requests[0].MemoryBytesAvg, 0.0001)
+}
+
+func TestWriteBuffer_FlushNodeReadings(t *testing.T) {
+	ctx := Context(t)
+	q := Queries(t)
+	buffer := NewWriteBuffer(q.MergePodUsageSamples, q, time.Hour, 100)
+	_, podUID := RandomUUID(t)
+	_, pvcUID := RandomUUID(t)
+	timestamp := pgtype.Timestamptz{Time: time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC), Valid: true}
+
+	require.NoError(t, buffer.UpsertContainerUsedCPU(ctx, []queries.UpsertContainerUsedCPUParams{
+		{PodUid: podUID, ContainerName: "app", Timestamp: timestamp, CpuCores: 1},
+		{PodUid: podUID, ContainerName: "app", Timestamp: timestamp, CpuCores: 3},
+	}))
+	require.NoError(t, buffer.UpsertContainerUsedMemory(ctx, []queries.UpsertContainerUsedMemoryParams{
+		{PodUid: podUID, ContainerName: "app", Timestamp: timestamp, MemoryBytes: 100},
+	}))
+	require.NoError(t, buffer.UpsertPVCUsage(ctx, []queries.UpsertPVCUsageParams{
+		{PvcUid: pvcUID, Timestamp: timestamp, UsedBytes: 10, CapacityBytes: 100},
+		{PvcUid: pvcUID, Timestamp: timestamp, UsedBytes: 20, CapacityBytes: 200},
+	}))
+	require.NoError(t, buffer.UpsertPodEphemeralStorage(ctx, []queries.UpsertPodEphemeralStorageParams{
+		{PodUid: podUID, Timestamp: timestamp, UsedBytes: 512},
+	}))
+	require.NoError(t, buffer.UpsertPodNetwork(ctx, []queries.UpsertPodNetworkParams{
+		{PodUid: podUID, Timestamp: timestamp, NetworkReceiveBytes: 10, NetworkTransmitBytes: 20},
+		{PodUid: podUID, Timestamp: timestamp, NetworkReceiveBytes: 30, NetworkTransmitBytes: 40},
+	}))
+	require.NoError(t, buffer.UpsertPodCPUThrottling(ctx, []queries.UpsertPodCPUThrottlingParams{
+		{PodUid: podUID, Timestamp: timestamp, CpuCfsPeriods: 100, CpuCfsThrottledPeriods: 5},
+	}))
+	require.NoError(t, buffer.UpsertPodFsUsage(ctx, []queries.UpsertPodFsUsageParams{
+		{PodUid: podUID, Timestamp: timestamp, FsUsageBytes: 1000},
+	}))
+	assert.Equal(t, 10, buffer.Stats().Pending)
+
+	buffer.Flush(ctx)
+	stats := buffer.Stats()
+	assert.Equal(t, 0, stats.Pending)
+	assert.Empty(t, stats.LastError)
+
+	containers, err := q.ListContainerUsageHourly(ctx)
+	require.NoError(t, err)
+	require.Len(t, containers, 1)
+	assert.Equal(t, int32(2), containers[0].CpuCoresTotalReadings)
+	assert.InDelta(t, 2.0, containers[0].CpuCoresAvg, 0.0001)
+	assert.InDelta(t, 100.0, containers[0].MemoryBytesAvg, 0.0001)
+
+	pvcs, err := q.ListPVCUsageHourly(ctx)
+	require.NoError(t, err)
+	require.Len(t, pvcs, 1)
+	assert.InDelta(t, 15.0, pvcs[0].UsedBytesAvg, 0.0001)
+	assert.InDelta(t, 10.0, pvcs[0].UsedBytesMin, 0.0001)
+	// the capacity of the last reading is kept
+	assert.InDelta(t, 200.0, pvcs[0].CapacityBytes, 0.0001)
+
+	ephemeral, err := q.ListPodEphemeralStorageHourly(ctx)
+	require.NoError(t, err)
+	require.Len(t, ephemeral, 1)
+	assert.InDelta(t, 512.0, ephemeral[0].UsedBytesAvg, 0.0001)
+
+	cadvisor, err := q.ListPodCadvisorHourly(ctx)
+	require.NoError(t, err)
+	require.Len(t, cadvisor, 1)
+	assert.InDelta(t, 40.0, cadvisor[0].NetworkReceiveBytes, 0.0001)
+	assert.InDelta(t, 60.0, cadvisor[0].NetworkTransmitBytes, 0.0001)
+	assert.InDelta(t, 100.0, cadvisor[0].CpuCfsPeriods, 0.0001)
+	assert.InDelta(t, 5.0, cadvisor[0].CpuCfsThrottledPeriods, 0.0001)
+	assert.Equal(t, int32(1), cadvisor[0].FsUsageBytesTotalReadings)
+	assert.InDelta(t, 1000.0, cadvisor[0].FsUsageBytesAvg, 0.0001)
+}
+
+func TestWriteBuffer_Backpressure(t *testing.T) {
+	buffer := NewWriteBuffer(nil, nil, time.Hour, 1)
+	sample := []queries.UpsertPodUsedCPUParams{{CpuCores: 1}}
+	require.NoError(t, buffer.UpsertPodUsedCPU(context.Background(), sample))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := buffer.UpsertPodUsedCPU(ctx, sample)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	stats := buffer.Stats()
+	assert.Equal(t, 1, stats.Pending)
+	assert.Equal(t, 1, stats.BlockedWrites)
+}
+
+// failingRequestsWriter fails to write requests, the test doesn't buffer other readings
+type failingRequestsWriter struct {
+	*queries.Queries
+}
+
+func (w failingRequestsWriter) UpsertPodRequests(ctx context.Context, arg []queries.UpsertPodRequestParams) error {
+	return fmt.Errorf("connection refused")
+}
+
+func TestWriteBuffer_FailedRequestsFlush(t *testing.T) {
+	var written int
+	write := func(ctx context.Context, samples []queries.PodUsageSample) error {
+		written += len(samples)
+		return nil
+	}
+	buffer := NewWriteBuffer(write, failingRequestsWriter{}, time.Hour, 100)
+	ctx := context.Background()
+	require.NoError(t, buffer.UpsertPodUsedCPU(ctx, []queries.UpsertPodUsedCPUParams{{CpuCores: 1}}))
+	require.NoError(t, buffer.UpsertPodRequests(ctx, []queries.UpsertPodRequestParams{{CpuCores: 1}}))
+
+	buffer.Flush(ctx)
+	stats := buffer.Stats()
+	assert.Equal(t, 1, written)
+	assert.Equal(t, 1, stats.Pending, "written samples aren't retried, requests are kept")
+	assert.Equal(t, 1, stats.FailedFlushes)
+	assert.Contains(t, stats.LastError, "connection refused")
+}
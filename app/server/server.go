@@ -11,15 +11,22 @@ import (
 	"github.com/Masterminds/sprig/v3"
 
 	"github.com/r2k1/pgkube/app/queries"
+	"github.com/r2k1/pgkube/app/scraper"
 )
 
+// ScrapeStatusProvider reports the state of the scraper, implemented by scraper.Scraper
+type ScrapeStatusProvider interface {
+	Status() scraper.Status
+}
+
 type Srv struct {
 	queries    *queries.Queries
+	status     ScrapeStatusProvider
 	renderFunc func(w http.ResponseWriter, name string, data interface{})
 	assetsPath string
 }
 
-func NewSrv(queries *queries.Queries, templatesPath string, assetsPath string, autoReload bool) *Srv {
+func NewSrv(queries *queries.Queries, status ScrapeStatusProvider, templatesPath string, assetsPath string, autoReload bool) *Srv {
 	var renderer func(w http.ResponseWriter, name string, data interface{})
 	if autoReload {
 		renderer = func(w http.ResponseWriter, name string, data interface{}) {
@@ -41,6 +48,7 @@ func NewSrv(queries *queries.Queries, templatesPath string, assetsPath string, a
 	return &Srv{
 		renderFunc: renderer,
 		queries:    queries,
+		status:     status,
 		assetsPath: assetsPath,
 	}
 }
@@ -61,6 +69,10 @@ func (s *Srv) Handler() http.Handler {
 	mux.Handle("/", http.RedirectHandler(DefaultRequest().Link(), http.StatusFound))
 	mux.HandleFunc("/workload", s.HandleWorkload)
 	mux.HandleFunc("/workload.csv", s.HandleWorkloadCSV)
+	mux.HandleFunc("/timeline", s.HandleTimeline)
+	mux.HandleFunc("/timeline.json", s.HandleTimelineJSON)
+	mux.HandleFunc("/status", s.HandleStatus)
+	mux.HandleFunc("/status.json", s.HandleStatusJSON)
 	return LoggingMiddleware(mux)
 }
 
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
@@ -14,6 +15,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/r2k1/pgkube/app/queries"
+	"github.com/r2k1/pgkube/app/scraper"
 	"github.com/r2k1/pgkube/app/test"
 )
 
@@ -25,7 +27,7 @@ func NewTestQueries(t *testing.T) *queries.Queries {
 }
 
 func TestServer_HandleWorkload(t *testing.T) {
-	handler := NewSrv(NewTestQueries(t), "../templates", "../assets", false).Handler()
+	handler := NewSrv(NewTestQueries(t), nil, "../templates", "../assets", false).Handler()
 	tests := []struct {
 		path       string
 		statusCode int
@@ -41,6 +43,18 @@ func TestServer_HandleWorkload(t *testing.T) {
 		{path: "/workload?col=namespace&order_by=namespace", statusCode: 200},
 		{path: "/workload.csv?col=namespace&start=2021-01-01T00:00:00Z&end=2021-01-02T00:00:00Z", statusCode: 200},
 		{path: "/workload?col=namespace&range=168h", statusCode: 200},
+		{path: "/workload?col=namespace&range=1h&granularity=minute", statusCode: 200},
+		{path: "/workload?col=namespace&range=168h&granularity=minute", statusCode: 500},
+		{path: "/workload?col=date&col=namespace&range=720h&granularity=day", statusCode: 200},
+		{path: "/workload?col=namespace&col=annotation_owner.example.com%2Fteam&orderby=annotation_owner.example.com%2Fteam+desc", statusCode: 200},
+		{path: "/workload.csv?col=namespace&col=annotation_owner.example.com%2Fteam&orderby=annotation_owner.example.com%2Fteam", statusCode: 200},
+		{path: "/workload?col=namespace&orderby=namespace%3B+drop+table+object", statusCode: 500},
+		{path: "/workload?col=namespace&col=request_nvidia.com%2Fgpu_hours&col=nvidia.com%2Fgpu_cost&col=extended_cost&orderby=nvidia.com%2Fgpu_cost+desc", statusCode: 200},
+		{path: "/workload?col=namespace&col=nvidia.com%2Fgpu')%3B+drop+table+object_cost", statusCode: 500},
+		{path: "/timeline", statusCode: 200},
+		{path: "/timeline?namespace=default&kind=Deployment&name=app", statusCode: 200},
+		{path: "/timeline.json?namespace=default&kind=Deployment&name=app&range=720h", statusCode: 200},
+		{path: "/timeline.json?namespace=default", statusCode: 500},
 		{path: "/workload?col=namespace&col=controller_kind&col=controller_name&col=pod_name&col=node_name&col=total_cost&order_by=namespace&range=168h", statusCode: 200},
 	}
 	for _, test := range tests {
@@ -53,6 +67,71 @@ func TestServer_HandleWorkload(t *testing.T) {
 	}
 }
 
+type statusProviderStub scraper.Status
+
+func (s statusProviderStub) Status() scraper.Status {
+	return scraper.Status(s)
+}
+
+func TestServer_HandleStatus(t *testing.T) {
+	status := statusProviderStub{
+		Targets: []scraper.TargetStatus{
+			{ID: "node-1", LastScrape: time.Now(), LastSuccess: time.Now()},
+			{ID: "node-2", LastScrape: time.Now(), LastError: "connection refused", ConsecutiveFailures: 3},
+		},
+		WriteBuffer: &scraper.WriteBufferStats{Pending: 42, MaxSamples: 1000, LastFlushSize: 17},
+	}
+	handler := NewSrv(nil, status, "../templates", "../assets", false).Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/status", nil)
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), "node-2")
+	assert.Contains(t, resp.Body.String(), "connection refused")
+	assert.Contains(t, resp.Body.String(), "42 / 1000")
+
+	req = httptest.NewRequest(http.MethodGet, "/status.json", nil)
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+	var result scraper.Status
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &result))
+	require.Len(t, result.Targets, 2)
+	assert.Equal(t, 3, result.Targets[1].ConsecutiveFailures)
+	require.NotNil(t, result.WriteBuffer)
+	assert.Equal(t, 17, result.WriteBuffer.LastFlushSize)
+}
+
+func TestTimelineRows(t *testing.T) {
+	hour := time.Date(2023, 12, 1, 10, 0, 0, 0, time.UTC)
+	ts := func(t time.Time) pgtype.Timestamptz {
+		return pgtype.Timestamptz{Time: t, Valid: true}
+	}
+	timeline := &queries.Timeline{
+		Costs: []queries.TimelineCost{
+			{Timestamp: ts(hour)},
+			{Timestamp: ts(hour.Add(time.Hour))},
+			{Timestamp: ts(hour.Add(3 * time.Hour))},
+		},
+		Revisions: []queries.ObjectRevision{
+			{ValidFrom: ts(hour.Add(-48 * time.Hour))},
+			{ValidFrom: ts(hour.Add(90 * time.Minute))},
+			{ValidFrom: ts(hour.Add(150 * time.Minute))},
+			{ValidFrom: ts(hour.Add(5 * time.Hour))},
+		},
+	}
+	rows := timelineRows(timeline)
+	require.Len(t, rows, 4)
+	assert.Nil(t, rows[0].Cost)
+	assert.Len(t, rows[0].Revisions, 1)
+	assert.Empty(t, rows[1].Revisions)
+	// the hour without cost is attributed to the previous hour
+	assert.Len(t, rows[2].Revisions, 2)
+	assert.Len(t, rows[3].Revisions, 1)
+}
+
 func TestHandleWorkloadCSV_ReturnsCSVWhenValidQuery(t *testing.T) {
 	q := NewTestQueries(t)
 	ctx := context.TODO()
@@ -79,7 +158,7 @@ func TestHandleWorkloadCSV_ReturnsCSVWhenValidQuery(t *testing.T) {
 		},
 	})
 	require.NoError(t, err)
-	srv := NewSrv(q, "../templates", "../assets", false)
+	srv := NewSrv(q, nil, "../templates", "../assets", false)
 	req := httptest.NewRequest(http.MethodGet, "/workload.csv?start=2021-01-15T00%3A00%3A00Z&end=2021-01-16T00%3A00%3A00Z&col=namespace", nil)
 	resp := httptest.NewRecorder()
 
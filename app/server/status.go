@@ -0,0 +1,26 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/r2k1/pgkube/app/scraper"
+)
+
+func (s *Srv) scrapeStatus() scraper.Status {
+	if s.status == nil {
+		return scraper.Status{Targets: []scraper.TargetStatus{}}
+	}
+	return s.status.Status()
+}
+
+func (s *Srv) HandleStatus(w http.ResponseWriter, r *http.Request) {
+	s.renderFunc(w, "status.gohtml", s.scrapeStatus())
+}
+
+func (s *Srv) HandleStatusJSON(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.scrapeStatus()); err != nil {
+		HTTPError(w, err)
+	}
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/r2k1/pgkube/app/queries"
+)
+
+type TimelineRequest struct {
+	Namespace string
+	Kind      string
+	Name      string
+	Range     string
+}
+
+func UnmarshalTimelineRequest(v url.Values) TimelineRequest {
+	result := TimelineRequest{
+		Namespace: v.Get("namespace"),
+		Kind:      v.Get("kind"),
+		Name:      v.Get("name"),
+		Range:     v.Get("range"),
+	}
+	if result.Range == "" {
+		result.Range = "168h"
+	}
+	return result
+}
+
+func (r TimelineRequest) ToQuery() (queries.TimelineRequest, error) {
+	if r.Namespace == "" || r.Kind == "" || r.Name == "" {
+		return queries.TimelineRequest{}, fmt.Errorf("namespace, kind and name are required")
+	}
+	start, end, err := rangeToStartEnd(r.Range, queries.GranularityHour)
+	if err != nil {
+		return queries.TimelineRequest{}, err
+	}
+	return queries.TimelineRequest{
+		Namespace: r.Namespace,
+		Kind:      r.Kind,
+		Name:      r.Name,
+		Start:     start,
+		// include the current hour
+		End: end.Add(time.Hour),
+	}, nil
+}
+
+func (r TimelineRequest) LinkJSON() string {
+	values := url.Values{}
+	values.Set("namespace", r.Namespace)
+	values.Set("kind", r.Kind)
+	values.Set("name", r.Name)
+	values.Set("range", r.Range)
+	u, _ := url.Parse("/timeline.json")
+	u.RawQuery = values.Encode()
+	return u.String()
+}
+
+// TimelineRow is an hour of cost with revisions which started within the hour
+// hours without cost (e.g. scaled to zero) are missing, their revisions are shown with the previous hour
+type TimelineRow struct {
+	Cost      *queries.TimelineCost
+	Revisions []queries.ObjectRevision
+}
+
+// timelineRows merges revisions into hourly cost rows, revisions started before the first hour get their own row
+func timelineRows(timeline *queries.Timeline) []TimelineRow {
+	rows := make([]TimelineRow, 0, len(timeline.Costs)+1)
+	revisions := timeline.Revisions
+	var initial []queries.ObjectRevision
+	for len(revisions) > 0 && (len(timeline.Costs) == 0 || revisions[0].ValidFrom.Time.Before(timeline.Costs[0].Timestamp.Time)) {
+		initial = append(initial, revisions[0])
+		revisions = revisions[1:]
+	}
+	if len(initial) > 0 {
+		rows = append(rows, TimelineRow{Revisions: initial})
+	}
+	for i := range timeline.Costs {
+		row := TimelineRow{Cost: &timeline.Costs[i]}
+		last := i+1 == len(timeline.Costs)
+		for len(revisions) > 0 && (last || revisions[0].ValidFrom.Time.Before(timeline.Costs[i+1].Timestamp.Time)) {
+			row.Revisions = append(row.Revisions, revisions[0])
+			revisions = revisions[1:]
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func (s *Srv) HandleTimeline(w http.ResponseWriter, r *http.Request) {
+	req := UnmarshalTimelineRequest(r.URL.Query())
+	data := struct {
+		Request          TimelineRequest
+		Rows             []TimelineRow
+		TimeRangeOptions []TimeRangeOptions
+	}{
+		Request: req,
+		TimeRangeOptions: []TimeRangeOptions{
+			{Label: "1d", Value: "24h"},
+			{Label: "7d", Value: "168h"},
+			{Label: "30d", Value: "720h"},
+		},
+	}
+	// an empty form is rendered until a controller is picked
+	if req.Namespace != "" || req.Kind != "" || req.Name != "" {
+		timeline, err := s.fetchTimeline(r, req)
+		if err != nil {
+			HTTPError(w, err)
+			return
+		}
+		data.Rows = timelineRows(timeline)
+	}
+	s.renderFunc(w, "timeline.gohtml", data)
+}
+
+func (s *Srv) HandleTimelineJSON(w http.ResponseWriter, r *http.Request) {
+	timeline, err := s.fetchTimeline(r, UnmarshalTimelineRequest(r.URL.Query()))
+	if err != nil {
+		HTTPError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(timeline); err != nil {
+		HTTPError(w, err)
+	}
+}
+
+func (s *Srv) fetchTimeline(r *http.Request, req TimelineRequest) (*queries.Timeline, error) {
+	query, err := req.ToQuery()
+	if err != nil {
+		return nil, err
+	}
+	return s.queries.ControllerTimeline(r.Context(), query)
+}
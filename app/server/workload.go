@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"encoding/csv"
 	"fmt"
 	"net/http"
@@ -19,7 +20,7 @@ type TimeRangeOptions struct {
 }
 
 func (t *TimeRangeOptions) StartDate() string {
-	start, _, _ := rangeToStartEnd(t.Value)
+	start, _, _ := rangeToStartEnd(t.Value, "")
 	if start.IsZero() {
 		return ""
 	}
@@ -27,7 +28,7 @@ func (t *TimeRangeOptions) StartDate() string {
 }
 
 func (t *TimeRangeOptions) EndDate() string {
-	_, end, _ := rangeToStartEnd(t.Value)
+	_, end, _ := rangeToStartEnd(t.Value, "")
 	if end.IsZero() {
 		return ""
 	}
@@ -35,11 +36,12 @@ func (t *TimeRangeOptions) EndDate() string {
 }
 
 type WorkloadRequest struct {
-	Cols   []string
-	OderBy string
-	Range  string
-	Start  time.Time
-	End    time.Time
+	Cols        []string
+	OderBy      string
+	Range       string
+	Start       time.Time
+	End         time.Time
+	Granularity string
 }
 
 func DefaultRequest() WorkloadRequest {
@@ -58,7 +60,7 @@ func (r WorkloadRequest) ToQuery() (queries.WorkloadAggRequest, error) {
 
 	if r.Start.IsZero() || r.End.IsZero() {
 		var err error
-		start, end, err = rangeToStartEnd(r.Range)
+		start, end, err = rangeToStartEnd(r.Range, r.Granularity)
 		if err != nil {
 			return queries.WorkloadAggRequest{}, err
 		}
@@ -67,14 +69,15 @@ func (r WorkloadRequest) ToQuery() (queries.WorkloadAggRequest, error) {
 		end = r.End
 	}
 	return queries.WorkloadAggRequest{
-		Cols:    r.Cols,
-		OrderBy: r.OderBy,
-		Start:   start,
-		End:     end,
+		Cols:        r.Cols,
+		OrderBy:     r.OderBy,
+		Start:       start,
+		End:         end,
+		Granularity: r.Granularity,
 	}, nil
 }
 
-func rangeToStartEnd(rangeStr string) (time.Time, time.Time, error) {
+func rangeToStartEnd(rangeStr string, granularity string) (time.Time, time.Time, error) {
 	if rangeStr == "" {
 		return time.Time{}, time.Time{}, nil
 	}
@@ -82,8 +85,11 @@ func rangeToStartEnd(rangeStr string) (time.Time, time.Time, error) {
 	if err != nil {
 		return time.Time{}, time.Time{}, fmt.Errorf("invalid range: %w", err)
 	}
-	now := time.Now().UTC()
-	end := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, time.UTC)
+	end := truncate(time.Now().UTC(), granularity)
+	if granularity == queries.GranularityDay {
+		// include the current day
+		end = end.AddDate(0, 0, 1)
+	}
 	start := end.Add(-duration)
 	return start, end, nil
 }
@@ -103,6 +109,29 @@ func (r WorkloadRequest) LinkRange(rangeValue string) string {
 	return r.Link()
 }
 
+func (r WorkloadRequest) LinkGranularity(granularity string) string {
+	r = r.Clone()
+	r.Granularity = granularity
+	if granularity != queries.GranularityHour {
+		// container level data is only available per hour
+		r.Cols = lo.Filter(r.Cols, func(item string, _ int) bool {
+			return item != "container_name"
+		})
+	}
+	return r.Link()
+}
+
+func (r WorkloadRequest) IsMinutely() bool {
+	return r.Granularity == queries.GranularityMinute
+}
+
+func (r WorkloadRequest) IsGranularity(granularity string) bool {
+	if r.Granularity == "" {
+		return granularity == queries.GranularityHour
+	}
+	return r.Granularity == granularity
+}
+
 func (r WorkloadRequest) LinkPrev() string {
 	start := r.StartDate()
 	end := r.EndDate()
@@ -117,6 +146,9 @@ func (r WorkloadRequest) LinkPrev() string {
 func (r WorkloadRequest) Duration() string {
 	start := r.StartDate()
 	end := r.EndDate()
+	if r.IsMinutely() && end.Sub(start) < time.Hour {
+		return fmt.Sprintf("%dm", int(end.Sub(start).Minutes()))
+	}
 	hours := int(end.Sub(start).Hours())
 	return fmt.Sprintf("%dh", hours)
 }
@@ -133,7 +165,7 @@ func (r WorkloadRequest) LinkNext() string {
 }
 
 func (r WorkloadRequest) LinkToggleOrder(col string) string {
-	if !queries.Contains(queries.Cols(), col) {
+	if !queries.Contains(queries.Cols(), col) && !((queries.IsLabelCol(col) || queries.IsExtendedResourceCol(col)) && r.IsColSelected(col)) {
 		return ""
 	}
 	r = r.Clone()
@@ -203,6 +235,9 @@ func (r WorkloadRequest) urlValues() url.Values {
 			values.Set("end", r.EndValue())
 		}
 	}
+	if r.Granularity != "" && r.Granularity != queries.GranularityHour {
+		values.Set("granularity", r.Granularity)
+	}
 	orderByCol := strings.TrimSuffix(strings.TrimSuffix(r.OderBy, " desc"), " asc")
 	if r.OderBy != "" && lo.Contains(r.Cols, orderByCol) {
 		values.Set("orderby", r.OderBy)
@@ -219,7 +254,7 @@ func (r WorkloadRequest) LinkCSV() string {
 
 func (r WorkloadRequest) StartDate() time.Time {
 	if r.Range != "" {
-		start, _, _ := rangeToStartEnd(r.Range)
+		start, _, _ := rangeToStartEnd(r.Range, r.Granularity)
 		return start
 	}
 	return r.Start
@@ -227,7 +262,7 @@ func (r WorkloadRequest) StartDate() time.Time {
 
 func (r WorkloadRequest) EndDate() time.Time {
 	if r.Range != "" {
-		_, end, _ := rangeToStartEnd(r.Range)
+		_, end, _ := rangeToStartEnd(r.Range, r.Granularity)
 		return end
 	}
 	return r.End
@@ -243,7 +278,7 @@ func (r WorkloadRequest) EndValue() string {
 
 func (r WorkloadRequest) Labels() []string {
 	return lo.Filter(r.Cols, func(item string, _ int) bool {
-		return strings.HasPrefix(item, "label_")
+		return queries.IsLabelCol(item)
 	})
 }
 
@@ -264,6 +299,11 @@ func (s *Srv) HandleWorkload(w http.ResponseWriter, r *http.Request) {
 		HTTPError(w, err)
 		return
 	}
+	cols, err := s.workloadCols(r.Context(), workloadReq)
+	if err != nil {
+		HTTPError(w, err)
+		return
+	}
 
 	w.Header().Set("HX-Replace-Url", workloadReq.Link())
 
@@ -275,7 +315,7 @@ func (s *Srv) HandleWorkload(w http.ResponseWriter, r *http.Request) {
 	}{
 		Request: workloadReq,
 		AggData: aggData,
-		Cols:    queries.Cols(),
+		Cols:    cols,
 		TimeRangeOptions: []TimeRangeOptions{
 			{Label: "1h", Value: "1h"},
 			{Label: "3h", Value: "3h"},
@@ -289,6 +329,25 @@ func (s *Srv) HandleWorkload(w http.ResponseWriter, r *http.Request) {
 	s.renderFunc(w, "workload.gohtml", data)
 }
 
+// workloadCols lists columns which can be selected, extended resource columns are offered for resources seen in the cluster
+func (s *Srv) workloadCols(ctx context.Context, req WorkloadRequest) ([]string, error) {
+	cols := queries.Cols()
+	resources, err := s.queries.ExtendedResourceNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, resource := range resources {
+		cols = append(cols, queries.ExtendedResourceCols(resource)...)
+	}
+	// selected columns of resources which aren't around anymore can still be unselected
+	for _, col := range req.Cols {
+		if queries.IsExtendedResourceCol(col) && !lo.Contains(cols, col) {
+			cols = append(cols, col)
+		}
+	}
+	return cols, nil
+}
+
 func (s *Srv) HandleWorkloadCSV(w http.ResponseWriter, r *http.Request) {
 	if len(r.URL.Query()) == 0 {
 		http.Redirect(w, r, DefaultRequest().Link(), http.StatusFound)
@@ -361,11 +420,33 @@ func UnmarshalWorkloadRequest(v url.Values) WorkloadRequest {
 	})
 	result.Cols = lo.Uniq(result.Cols)
 	result.OderBy = v.Get("orderby")
-	result.Start = TruncateHour(result.Start)
-	result.End = TruncateHour(result.End)
+	switch granularity := v.Get("granularity"); granularity {
+	case queries.GranularityMinute, queries.GranularityDay:
+		result.Granularity = granularity
+	}
+	result.Start = truncate(result.Start, result.Granularity)
+	result.End = truncate(result.End, result.Granularity)
 	return result
 }
 
 func TruncateHour(t time.Time) time.Time {
 	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
 }
+
+func TruncateMinute(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, t.Location())
+}
+
+func TruncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func truncate(t time.Time, granularity string) time.Time {
+	switch granularity {
+	case queries.GranularityMinute:
+		return TruncateMinute(t)
+	case queries.GranularityDay:
+		return TruncateDay(t)
+	}
+	return TruncateHour(t)
+}